@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/urfave/cli/v2"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+)
+
+const (
+	metadataConfigFile = "config-file"
+
+	timeoutConfigFile = 5 * time.Second
+)
+
+var (
+	errConfigFileInvalidS3URL = errors.New("invalid s3 url of the config file (must be 's3://bucket/key')")
+)
+
+// readConfigFile reads the config file either from local disk, from S3
+// (when source is 's3://bucket/key' URL), or from the Secrets Manager secret
+// (when source is ARN of the secret)
+func readConfigFile(source, key string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(source, "arn:aws:secretsmanager:"):
+		s, err := stringOrLoadFromSecretsmanager(source, key)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(s), nil
+
+	case strings.HasPrefix(source, "s3://"):
+		return readConfigFileFromS3(source)
+
+	default:
+		return os.ReadFile(source)
+	}
+}
+
+func readConfigFileFromS3(source string) ([]byte, error) {
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return nil, fmt.Errorf("%w: %s",
+			errConfigFileInvalidS3URL, source,
+		)
+	}
+
+	s, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutConfigFile)
+	defer cancel()
+
+	res, err := s3.New(s).GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(u.Host),
+		Key:    aws.String(strings.TrimPrefix(u.Path, "/")),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	return io.ReadAll(res.Body)
+}
+
+// applyConfigFile applies the previously read config file (if any) on top of
+// the config, while keeping the values of the flags (of the app, and of the
+// command) that were explicitly set via command-line or environment, so that
+// they take precedence.
+//
+// It must be called once, from the command's before-func: that is, after all
+// the flags are parsed (as parsing resets the destinations of the flags that
+// are not set to their defaults).
+func applyConfigFile(clictx *cli.Context, cfg *config.Config) error {
+	data, ok := clictx.App.Metadata[metadataConfigFile].([]byte)
+	if !ok {
+		return nil
+	}
+
+	restore := []func(){}
+	for _, flag := range slices.Concat(clictx.App.Flags, clictx.Command.Flags) {
+		if !clictx.IsSet(flag.Names()[0]) {
+			continue
+		}
+		if dst, ok := flagDestination(flag); ok {
+			value := reflect.New(dst.Type()).Elem()
+			value.Set(dst)
+			restore = append(restore, func() { dst.Set(value) })
+		}
+	}
+
+	if err := cfg.Load(data); err != nil {
+		return err
+	}
+
+	for _, r := range restore {
+		r()
+	}
+
+	return nil
+}
+
+// flagDestination returns the value that the flag's destination points to
+// (whatever the type of the flag is), if the flag has one.
+func flagDestination(flag cli.Flag) (reflect.Value, bool) {
+	f := reflect.ValueOf(flag)
+	if f.Kind() != reflect.Pointer || f.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	dst := f.Elem().FieldByName("Destination")
+	if !dst.IsValid() || dst.Kind() != reflect.Pointer || dst.IsNil() {
+		return reflect.Value{}, false
+	}
+	return dst.Elem(), true
+}
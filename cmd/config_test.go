@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+)
+
+const testConfigFile = `
+local_db:
+  path: ":memory:"
+log:
+  level: warn
+opsgenie:
+  region: eu
+processor:
+  match_labels: ["team=infra"]
+retry:
+  max_attempts: 3
+  initial_backoff: 2s
+slack:
+  group_by: [cluster]
+webhook:
+  send_body: false
+webhooks:
+  - name: implicit
+    url: https://implicit.example.com
+  - name: explicit
+    url: https://explicit.example.com
+    send_body: false
+`
+
+// runApp runs the command of the app (with no-op action) with the test config
+// file and the args, and returns the resulting config.
+func runApp(t *testing.T, command string, args ...string) *config.Config {
+	return runAppWithConfig(t, testConfigFile, command, args...)
+}
+
+// runAppWithConfig is the same as runApp, but with the given config file.
+func runAppWithConfig(t *testing.T, configFile, command string, args ...string) *config.Config {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(configFile), 0o600))
+
+	cfg := config.New()
+	app := newApp(cfg)
+	for _, cmd := range app.Commands {
+		cmd.Action = func(*cli.Context) error { return nil }
+	}
+
+	err := app.Run(append([]string{"amp-alerts-sink", "--config", path, command}, args...))
+	assert.NoError(t, err)
+
+	return cfg
+}
+
+func TestConfigFile(t *testing.T) {
+	for _, command := range []string{"lambda", "serve"} {
+		t.Run(command, func(t *testing.T) {
+			cfg := runApp(t, command)
+
+			// the file overrides the defaults of the flags
+			assert.Equal(t, "warn", cfg.Log.Level)
+			assert.Equal(t, config.OpsgenieRegionEU, cfg.Opsgenie.Region)
+			assert.Equal(t, []string{"team=infra"}, cfg.Processor.MatchLabels)
+			assert.Equal(t, 3, cfg.Retry.MaxAttempts)
+			assert.Equal(t, 2*time.Second, cfg.Retry.InitialBackoff)
+			assert.Equal(t, []string{"cluster"}, cfg.Slack.GroupBy)
+			assert.False(t, cfg.Webhook.SendBody)
+
+			// the flags that are not in the file keep their defaults
+			assert.Equal(t, "prod", cfg.Log.Mode)
+			assert.Equal(t, 10*time.Second, cfg.Retry.MaxBackoff)
+
+			// the webhooks send the body unless told otherwise
+			if assert.Len(t, cfg.Webhooks, 2) {
+				assert.True(t, cfg.Webhooks[0].SendBody)
+				assert.False(t, cfg.Webhooks[1].SendBody)
+			}
+		})
+	}
+}
+
+func TestConfigFileFlagsTakePrecedence(t *testing.T) {
+	for _, command := range []string{"lambda", "serve"} {
+		t.Run(command, func(t *testing.T) {
+			cfg := runApp(t, command,
+				"--publisher-opsgenie-region", "us",
+				"--processor-match-labels", "team=payments",
+				"--publisher-retry-max-attempts", "5",
+				"--publisher-retry-initial-backoff", "1s",
+				"--publisher-slack-group-by", "namespace",
+				"--publisher-slack-group-by", "service",
+				"--publisher-webhook-send-body=true",
+			)
+
+			assert.Equal(t, config.OpsgenieRegionUS, cfg.Opsgenie.Region)
+			assert.Equal(t, []string{"team=payments"}, cfg.Processor.MatchLabels)
+			assert.Equal(t, 5, cfg.Retry.MaxAttempts)
+			assert.Equal(t, time.Second, cfg.Retry.InitialBackoff)
+			assert.Equal(t, []string{"namespace", "service"}, cfg.Slack.GroupBy)
+			assert.True(t, cfg.Webhook.SendBody)
+
+			// the rest still come from the file
+			assert.Equal(t, "warn", cfg.Log.Level)
+		})
+	}
+}
+
+func TestConfigFileEnvTakesPrecedence(t *testing.T) {
+	t.Setenv("AMP_ALERTS_SINK_LOG_LEVEL", "debug")
+	t.Setenv("AMP_ALERTS_SINK_PUBLISHER_OPSGENIE_REGION", "us")
+	t.Setenv("AMP_ALERTS_SINK_PUBLISHER_SLACK_GROUP_BY", "namespace,service")
+
+	cfg := runApp(t, "serve")
+
+	assert.Equal(t, "debug", cfg.Log.Level)
+	assert.Equal(t, config.OpsgenieRegionUS, cfg.Opsgenie.Region)
+	assert.Equal(t, []string{"namespace", "service"}, cfg.Slack.GroupBy)
+	assert.Equal(t, 3, cfg.Retry.MaxAttempts)
+}
@@ -20,9 +20,28 @@ const (
 )
 
 func main() {
-	cfg := config.New()
+	app := newApp(config.New())
+
+	defer func() {
+		zap.L().Sync() //nolint:errcheck
+	}()
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintf(os.Stderr, "\nFailed with error:\n\n%s\n\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+// newApp returns the cli app that fills in the config.
+func newApp(cfg *config.Config) *cli.App {
+	envConfig := envPrefix + "CONFIG"
 
 	flags := []cli.Flag{
+		&cli.StringFlag{
+			EnvVars: []string{envConfig},
+			Name:    "config",
+			Usage:   "`path` to YAML config file (either local path, s3://bucket/key URL, or ARN of secret manager)",
+		},
+
 		&cli.StringFlag{
 			Destination: &cfg.Log.Level,
 			EnvVars:     []string{envPrefix + "LOG_LEVEL"},
@@ -42,13 +61,14 @@ func main() {
 
 	commands := []*cli.Command{
 		CommandLambda(cfg),
+		CommandServe(cfg),
 		CommandHelp(cfg),
 		CommandVersion(cfg),
 	}
 
-	app := &cli.App{
+	return &cli.App{
 		Name:        "amp-alerts-sink",
-		Usage:       "Receives alerts from AMP via SNS and dispatches them to configured destinations",
+		Usage:       "Receives alerts from AMP via SNS (or from alertmanager via webhook) and dispatches them to configured destinations",
 		Version:     version,
 		HideVersion: false,
 
@@ -56,13 +76,18 @@ func main() {
 		Commands:       commands,
 		DefaultCommand: commands[0].Name,
 
-		Before: func(_ *cli.Context) error {
-			// setup logger
-			l, err := logutils.NewLogger(cfg.Log)
-			if err != nil {
-				return err
+		Before: func(clictx *cli.Context) error {
+			// read the config file
+			if source := clictx.String("config"); source != "" {
+				data, err := readConfigFile(source, envConfig)
+				if err != nil {
+					return err
+				}
+				// it's applied by the commands (once their flags are parsed)
+				clictx.App.Metadata = map[string]interface{}{
+					metadataConfigFile: data,
+				}
 			}
-			zap.ReplaceGlobals(l)
 
 			return nil
 		},
@@ -71,12 +96,15 @@ func main() {
 			return cli.ShowAppHelp(clictx)
 		},
 	}
+}
 
-	defer func() {
-		zap.L().Sync() //nolint:errcheck
-	}()
-	if err := app.Run(os.Args); err != nil {
-		fmt.Fprintf(os.Stderr, "\nFailed with error:\n\n%s\n\n", err.Error())
-		os.Exit(1)
+// setupLogger replaces the global logger with the one that is configured (it
+// must be called once the config is complete).
+func setupLogger(cfg *config.Config) error {
+	l, err := logutils.NewLogger(cfg.Log)
+	if err != nil {
+		return err
 	}
+	zap.ReplaceGlobals(l)
+	return nil
 }
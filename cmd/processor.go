@@ -0,0 +1,923 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/secret"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	categoryDynamoDB   = "DYNAMO DB:"
+	categoryLocalDB    = "LOCAL DB:"
+	categoryRedis      = "REDIS:"
+	categoryProcessor  = "PROCESSOR:"
+	categorySlack      = "PUBLISHER SLACK:"
+	categoryPagerDuty  = "PUBLISHER PAGERDUTY:"
+	categoryOpsgenie   = "PUBLISHER OPSGENIE:"
+	categoryTeams      = "PUBLISHER TEAMS:"
+	categoryTelegram   = "PUBLISHER TELEGRAM:"
+	categoryDiscord    = "PUBLISHER DISCORD:"
+	categoryMattermost = "PUBLISHER MATTERMOST:"
+	categoryEmail      = "PUBLISHER EMAIL:"
+	categoryWebhook    = "PUBLISHER WEBHOOK:"
+	categoryRetry      = "PUBLISHER RETRY:"
+	categoryDeadLetter = "DEAD LETTER:"
+)
+
+// processorFlags returns the flags that configure the processor (together
+// with its db and publishers), and the function that post-processes them
+func processorFlags(cfg *config.Config) ([]cli.Flag, func(*cli.Context) error) {
+	envPrefixDynamoDB := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(categoryDynamoDB, " ", "_"), ":", "")) + "_"
+	envPrefixLocalDB := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(categoryLocalDB, " ", "_"), ":", "")) + "_"
+	envPrefixRedis := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(categoryRedis, " ", "_"), ":", "")) + "_"
+	envPrefixProcessor := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(categoryProcessor, " ", "_"), ":", "")) + "_"
+	envPrefixSlack := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(categorySlack, " ", "_"), ":", "")) + "_"
+	envPrefixPagerDuty := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(categoryPagerDuty, " ", "_"), ":", "")) + "_"
+	envPrefixOpsgenie := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(categoryOpsgenie, " ", "_"), ":", "")) + "_"
+	envPrefixTeams := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(categoryTeams, " ", "_"), ":", "")) + "_"
+	envPrefixTelegram := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(categoryTelegram, " ", "_"), ":", "")) + "_"
+	envPrefixDiscord := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(categoryDiscord, " ", "_"), ":", "")) + "_"
+	envPrefixMattermost := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(categoryMattermost, " ", "_"), ":", "")) + "_"
+	envPrefixEmail := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(categoryEmail, " ", "_"), ":", "")) + "_"
+	envPrefixWebhook := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(categoryWebhook, " ", "_"), ":", "")) + "_"
+	envPrefixRetry := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(categoryRetry, " ", "_"), ":", "")) + "_"
+	envPrefixDeadLetter := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(categoryDeadLetter, " ", "_"), ":", "")) + "_"
+
+	cliPrefixDynamoDB := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(categoryDynamoDB, " ", "-"), ":", "")) + "-"
+	cliPrefixLocalDB := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(categoryLocalDB, " ", "-"), ":", "")) + "-"
+	cliPrefixRedis := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(categoryRedis, " ", "-"), ":", "")) + "-"
+	cliPrefixProcessor := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(categoryProcessor, " ", "-"), ":", "")) + "-"
+	cliPrefixSlack := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(categorySlack, " ", "-"), ":", "")) + "-"
+	cliPrefixPagerDuty := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(categoryPagerDuty, " ", "-"), ":", "")) + "-"
+	cliPrefixOpsgenie := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(categoryOpsgenie, " ", "-"), ":", "")) + "-"
+	cliPrefixTeams := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(categoryTeams, " ", "-"), ":", "")) + "-"
+	cliPrefixTelegram := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(categoryTelegram, " ", "-"), ":", "")) + "-"
+	cliPrefixDiscord := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(categoryDiscord, " ", "-"), ":", "")) + "-"
+	cliPrefixMattermost := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(categoryMattermost, " ", "-"), ":", "")) + "-"
+	cliPrefixEmail := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(categoryEmail, " ", "-"), ":", "")) + "-"
+	cliPrefixWebhook := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(categoryWebhook, " ", "-"), ":", "")) + "-"
+	cliPrefixRetry := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(categoryRetry, " ", "-"), ":", "")) + "-"
+	cliPrefixDeadLetter := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(categoryDeadLetter, " ", "-"), ":", "")) + "-"
+
+	envRedisPassword := envPrefix + envPrefixRedis + "PASSWORD"
+	envSlackToken := envPrefix + envPrefixSlack + "TOKEN"
+	envSlackSigningSecret := envPrefix + envPrefixSlack + "SIGNING_SECRET"
+	envPagerDutyIntegrationKey := envPrefix + envPrefixPagerDuty + "INTEGRATION_KEY"
+	envOpsgenieAPIKey := envPrefix + envPrefixOpsgenie + "API_KEY"
+	envTeamsURL := envPrefix + envPrefixTeams + "URL"
+	envTelegramBotToken := envPrefix + envPrefixTelegram + "BOT_TOKEN"
+	envDiscordBotToken := envPrefix + envPrefixDiscord + "BOT_TOKEN"
+	envMattermostToken := envPrefix + envPrefixMattermost + "TOKEN"
+	envEmailUsername := envPrefix + envPrefixEmail + "USERNAME"
+	envEmailPassword := envPrefix + envPrefixEmail + "PASSWORD"
+	envWebhookURL := envPrefix + envPrefixWebhook + "URL"
+	envWebhookBasicAuthPassword := envPrefix + envPrefixWebhook + "BASIC_AUTH_PASSWORD"
+	envWebhookBearerToken := envPrefix + envPrefixWebhook + "BEARER_TOKEN"
+	envWebhookSigningSecret := envPrefix + envPrefixWebhook + "SIGNING_SECRET"
+	envWebhookClientKey := envPrefix + envPrefixWebhook + "CLIENT_KEY"
+
+	rawProcessorIgnoreRules := &matcherList{}
+	rawProcessorMatchLabels := &matcherList{}
+	rawSlackGroupBy := &cli.StringSlice{}
+	rawOpsgenieTagLabels := &cli.StringSlice{}
+	rawEmailTo := &cli.StringSlice{}
+	rawWebhookHeaders := &cli.StringSlice{}
+	rawWebhookSuccessStatuses := &cli.IntSlice{}
+
+	flagsDB := []cli.Flag{
+		&cli.StringFlag{
+			Category:    categoryDynamoDB,
+			Destination: &cfg.DynamoDB.Name,
+			EnvVars:     []string{envPrefix + envPrefixDynamoDB + "NAME"},
+			Name:        cliPrefixDynamoDB + "name",
+			Usage:       "`name` of Dynamo DB to keep track of alert statuses with",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryLocalDB,
+			Destination: &cfg.LocalDB.Path,
+			EnvVars:     []string{envPrefix + envPrefixLocalDB + "PATH"},
+			Name:        cliPrefixLocalDB + "path",
+			Usage:       "`path` to local db file to keep track of alert statuses with (use '" + config.LocalDBInMemory + "' to keep them in memory)",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryRedis,
+			Destination: &cfg.Redis.Address,
+			EnvVars:     []string{envPrefix + envPrefixRedis + "ADDRESS"},
+			Name:        cliPrefixRedis + "address",
+			Usage:       "`host:port` of redis to keep track of alert statuses with",
+		},
+
+		&cli.IntFlag{
+			Category:    categoryRedis,
+			Destination: &cfg.Redis.DB,
+			EnvVars:     []string{envPrefix + envPrefixRedis + "DB"},
+			Name:        cliPrefixRedis + "db",
+			Usage:       "redis database `number`",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryRedis,
+			Destination: &cfg.Redis.KeyPrefix,
+			EnvVars:     []string{envPrefix + envPrefixRedis + "KEY_PREFIX"},
+			Name:        cliPrefixRedis + "key-prefix",
+			Usage:       "`prefix` for all redis keys",
+			Value:       "amp-alerts-sink",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryRedis,
+			Destination: &cfg.Redis.Password,
+			EnvVars:     []string{envRedisPassword},
+			Name:        cliPrefixRedis + "password",
+			Usage:       "redis `password` (either raw password, or ARN of secret manager)",
+		},
+
+		&cli.BoolFlag{
+			Category:    categoryRedis,
+			Destination: &cfg.Redis.TLS,
+			EnvVars:     []string{envPrefix + envPrefixRedis + "TLS"},
+			Name:        cliPrefixRedis + "tls",
+			Usage:       "whether to connect to redis over TLS",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryRedis,
+			Destination: &cfg.Redis.Username,
+			EnvVars:     []string{envPrefix + envPrefixRedis + "USERNAME"},
+			Name:        cliPrefixRedis + "username",
+			Usage:       "redis `username` (for ACL auth)",
+		},
+	}
+
+	flagsProcessor := []cli.Flag{
+		&cli.GenericFlag{
+			Category: categoryProcessor,
+			EnvVars:  []string{envPrefix + envPrefixProcessor + "IGNORE_RULES"},
+			Name:     cliPrefixProcessor + "ignore-rules",
+			Usage:    "`rule` to ignore (either alert name, or label matcher like 'label=~regex'; repeat the flag for more rules, or put one per line of env var)",
+			Value:    rawProcessorIgnoreRules,
+		},
+
+		&cli.GenericFlag{
+			Category: categoryProcessor,
+			EnvVars:  []string{envPrefix + envPrefixProcessor + "MATCH_LABELS"},
+			Name:     cliPrefixProcessor + "match-labels",
+			Usage:    "label `matcher` ('label=value', 'label!=value', 'label=~regex' or 'label!~regex') to match (repeat the flag for more matchers, or put one per line of env var)",
+			Value:    rawProcessorMatchLabels,
+		},
+	}
+
+	flagsSlack := []cli.Flag{
+		&cli.StringFlag{
+			Category:    categorySlack,
+			Destination: &cfg.Slack.Channel.ID,
+			EnvVars:     []string{envPrefix + envPrefixSlack + "CHANNEL_ID"},
+			Name:        cliPrefixSlack + "channel-id",
+			Usage:       "slack channel `ID` to publish alerts to",
+		},
+
+		&cli.StringFlag{
+			Category:    categorySlack,
+			Destination: &cfg.Slack.Format,
+			EnvVars:     []string{envPrefix + envPrefixSlack + "FORMAT"},
+			Name:        cliPrefixSlack + "format",
+			Usage:       "slack message `format` (either 'attachment' or 'blocks')",
+			Value:       config.SlackFormatAttachment,
+		},
+
+		&cli.StringFlag{
+			Category:    categorySlack,
+			Destination: &cfg.Slack.Token,
+			EnvVars:     []string{envSlackToken},
+			Name:        cliPrefixSlack + "token",
+			Usage:       "slack API `token` (either raw token, or ARN of secret manager)",
+		},
+
+		&cli.BoolFlag{
+			Category:    categorySlack,
+			Destination: &cfg.Slack.UpdateOnResolve,
+			EnvVars:     []string{envPrefix + envPrefixSlack + "UPDATE_ON_RESOLVE"},
+			Name:        cliPrefixSlack + "update-on-resolve",
+			Usage:       "update the thread-starting slack message in-place when the alert is resolved",
+		},
+
+		&cli.BoolFlag{
+			Category:    categorySlack,
+			Destination: &cfg.Slack.Group,
+			EnvVars:     []string{envPrefix + envPrefixSlack + "GROUP"},
+			Name:        cliPrefixSlack + "group",
+			Usage:       "publish the alerts of the same group into the thread of group's summary message",
+		},
+
+		&cli.StringSliceFlag{
+			Category:    categorySlack,
+			Destination: rawSlackGroupBy,
+			EnvVars:     []string{envPrefix + envPrefixSlack + "GROUP_BY"},
+			Name:        cliPrefixSlack + "group-by",
+			Usage:       "comma-separated list of `label`s to group the alerts by (default: alertmanager's group labels)",
+		},
+
+		&cli.StringFlag{
+			Category:    categorySlack,
+			Destination: &cfg.Slack.SigningSecret,
+			EnvVars:     []string{envSlackSigningSecret},
+			Name:        cliPrefixSlack + "signing-secret",
+			Usage:       "slack app signing `secret` that enables interactive buttons (either raw secret, or ARN of secret manager)",
+		},
+
+		&cli.StringFlag{
+			Category:    categorySlack,
+			Destination: &cfg.Slack.AlertmanagerURL,
+			EnvVars:     []string{envPrefix + envPrefixSlack + "ALERTMANAGER_URL"},
+			Name:        cliPrefixSlack + "alertmanager-url",
+			Usage:       "`url` of alertmanager to create silences at (when the alert does not carry one)",
+		},
+	}
+
+	flagsPagerDuty := []cli.Flag{
+		&cli.StringFlag{
+			Category:    categoryPagerDuty,
+			Destination: &cfg.PagerDuty.IntegrationKey,
+			EnvVars:     []string{envPagerDutyIntegrationKey},
+			Name:        cliPrefixPagerDuty + "integration-key",
+			Usage:       "pagerduty `integration key` to publish alerts to",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryPagerDuty,
+			Destination: &cfg.PagerDuty.ClassLabel,
+			EnvVars:     []string{envPrefix + envPrefixPagerDuty + "CLASS_LABEL"},
+			Name:        cliPrefixPagerDuty + "class-label",
+			Usage:       "`label` to map onto the class of pagerduty events",
+			Value:       "alertname",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryPagerDuty,
+			Destination: &cfg.PagerDuty.ComponentLabel,
+			EnvVars:     []string{envPrefix + envPrefixPagerDuty + "COMPONENT_LABEL"},
+			Name:        cliPrefixPagerDuty + "component-label",
+			Usage:       "`label` to map onto the component of pagerduty events",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryPagerDuty,
+			Destination: &cfg.PagerDuty.GroupLabel,
+			EnvVars:     []string{envPrefix + envPrefixPagerDuty + "GROUP_LABEL"},
+			Name:        cliPrefixPagerDuty + "group-label",
+			Usage:       "`label` to map onto the group of pagerduty events",
+		},
+	}
+
+	flagsOpsgenie := []cli.Flag{
+		&cli.StringFlag{
+			Category:    categoryOpsgenie,
+			Destination: &cfg.Opsgenie.APIKey,
+			EnvVars:     []string{envOpsgenieAPIKey},
+			Name:        cliPrefixOpsgenie + "api-key",
+			Usage:       "opsgenie API `key` (either raw key, or ARN of secret manager)",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryOpsgenie,
+			Destination: &cfg.Opsgenie.Region,
+			EnvVars:     []string{envPrefix + envPrefixOpsgenie + "REGION"},
+			Name:        cliPrefixOpsgenie + "region",
+			Usage:       "opsgenie `region` (either 'us' or 'eu')",
+			Value:       config.OpsgenieRegionUS,
+		},
+
+		&cli.StringFlag{
+			Category:    categoryOpsgenie,
+			Destination: &cfg.Opsgenie.APIURL,
+			EnvVars:     []string{envPrefix + envPrefixOpsgenie + "API_URL"},
+			Name:        cliPrefixOpsgenie + "api-url",
+			Usage:       "custom opsgenie API `url` (overrides the region)",
+		},
+
+		&cli.StringSliceFlag{
+			Category:    categoryOpsgenie,
+			Destination: rawOpsgenieTagLabels,
+			EnvVars:     []string{envPrefix + envPrefixOpsgenie + "TAG_LABELS"},
+			Name:        cliPrefixOpsgenie + "tag-label",
+			Usage:       "comma-separated list of `label`s to pass as opsgenie tags (default: all labels)",
+		},
+	}
+
+	flagsTeams := []cli.Flag{
+		&cli.StringFlag{
+			Category:    categoryTeams,
+			Destination: &cfg.Teams.URL,
+			EnvVars:     []string{envTeamsURL},
+			Name:        cliPrefixTeams + "url",
+			Usage:       "teams incoming webhook (or workflows) `URL` to post alerts to (either raw URL, or ARN of secret manager)",
+		},
+	}
+
+	flagsTelegram := []cli.Flag{
+		&cli.StringFlag{
+			Category:    categoryTelegram,
+			Destination: &cfg.Telegram.BotToken,
+			EnvVars:     []string{envTelegramBotToken},
+			Name:        cliPrefixTelegram + "bot-token",
+			Usage:       "telegram bot `token` (either raw token, or ARN of secret manager)",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryTelegram,
+			Destination: &cfg.Telegram.ChatID,
+			EnvVars:     []string{envPrefix + envPrefixTelegram + "CHAT_ID"},
+			Name:        cliPrefixTelegram + "chat-id",
+			Usage:       "telegram chat `id` (or @username of the channel) to publish alerts to",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryTelegram,
+			Destination: &cfg.Telegram.APIURL,
+			EnvVars:     []string{envPrefix + envPrefixTelegram + "API_URL"},
+			Name:        cliPrefixTelegram + "api-url",
+			Usage:       "custom telegram bot API `url`",
+		},
+	}
+
+	flagsDiscord := []cli.Flag{
+		&cli.StringFlag{
+			Category:    categoryDiscord,
+			Destination: &cfg.Discord.BotToken,
+			EnvVars:     []string{envDiscordBotToken},
+			Name:        cliPrefixDiscord + "bot-token",
+			Usage:       "discord bot `token` (either raw token, or ARN of secret manager)",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryDiscord,
+			Destination: &cfg.Discord.ChannelID,
+			EnvVars:     []string{envPrefix + envPrefixDiscord + "CHANNEL_ID"},
+			Name:        cliPrefixDiscord + "channel-id",
+			Usage:       "discord channel `id` to publish alerts to",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryDiscord,
+			Destination: &cfg.Discord.APIURL,
+			EnvVars:     []string{envPrefix + envPrefixDiscord + "API_URL"},
+			Name:        cliPrefixDiscord + "api-url",
+			Usage:       "custom discord API `url`",
+		},
+	}
+
+	flagsMattermost := []cli.Flag{
+		&cli.StringFlag{
+			Category:    categoryMattermost,
+			Destination: &cfg.Mattermost.URL,
+			EnvVars:     []string{envPrefix + envPrefixMattermost + "URL"},
+			Name:        cliPrefixMattermost + "url",
+			Usage:       "mattermost server `url`",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryMattermost,
+			Destination: &cfg.Mattermost.Token,
+			EnvVars:     []string{envMattermostToken},
+			Name:        cliPrefixMattermost + "token",
+			Usage:       "mattermost bot access `token` (either raw token, or ARN of secret manager)",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryMattermost,
+			Destination: &cfg.Mattermost.ChannelID,
+			EnvVars:     []string{envPrefix + envPrefixMattermost + "CHANNEL_ID"},
+			Name:        cliPrefixMattermost + "channel-id",
+			Usage:       "mattermost channel `id` to publish alerts to",
+		},
+	}
+
+	flagsEmail := []cli.Flag{
+		&cli.StringFlag{
+			Category:    categoryEmail,
+			Destination: &cfg.Email.Host,
+			EnvVars:     []string{envPrefix + envPrefixEmail + "HOST"},
+			Name:        cliPrefixEmail + "host",
+			Usage:       "smtp server `host` to send emails through",
+		},
+
+		&cli.IntFlag{
+			Category:    categoryEmail,
+			Destination: &cfg.Email.Port,
+			EnvVars:     []string{envPrefix + envPrefixEmail + "PORT"},
+			Name:        cliPrefixEmail + "port",
+			Usage:       "smtp server `port`",
+			Value:       587,
+		},
+
+		&cli.StringFlag{
+			Category:    categoryEmail,
+			Destination: &cfg.Email.TLS,
+			EnvVars:     []string{envPrefix + envPrefixEmail + "TLS"},
+			Name:        cliPrefixEmail + "tls",
+			Usage:       "smtp tls `mode` (one of 'starttls', 'tls', or 'none')",
+			Value:       config.EmailTLSStartTLS,
+		},
+
+		&cli.StringFlag{
+			Category:    categoryEmail,
+			Destination: &cfg.Email.Username,
+			EnvVars:     []string{envEmailUsername},
+			Name:        cliPrefixEmail + "username",
+			Usage:       "smtp `username` (either raw username, or ARN of secret manager)",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryEmail,
+			Destination: &cfg.Email.Password,
+			EnvVars:     []string{envEmailPassword},
+			Name:        cliPrefixEmail + "password",
+			Usage:       "smtp `password` (either raw password, or ARN of secret manager)",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryEmail,
+			Destination: &cfg.Email.From,
+			EnvVars:     []string{envPrefix + envPrefixEmail + "FROM"},
+			Name:        cliPrefixEmail + "from",
+			Usage:       "sender `address` of the emails",
+		},
+
+		&cli.StringSliceFlag{
+			Category:    categoryEmail,
+			Destination: rawEmailTo,
+			EnvVars:     []string{envPrefix + envPrefixEmail + "TO"},
+			Name:        cliPrefixEmail + "to",
+			Usage:       "default recipient `address`(es) of the emails",
+		},
+	}
+
+	flagsWebhook := []cli.Flag{
+		&cli.StringFlag{
+			Category:    categoryWebhook,
+			Destination: &cfg.Webhook.URL,
+			EnvVars:     []string{envWebhookURL},
+			Name:        cliPrefixWebhook + "url",
+			Usage:       "webhook `URL` to send alerts to (either raw URL, or ARN of secret manager)",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryWebhook,
+			Destination: &cfg.Webhook.Method,
+			EnvVars:     []string{envPrefix + envPrefixWebhook + "METHOD"},
+			Name:        cliPrefixWebhook + "method",
+			Usage:       "HTTP `method` to use for webhook requests",
+			Value:       "POST",
+		},
+
+		&cli.BoolFlag{
+			Category:    categoryWebhook,
+			Destination: &cfg.Webhook.SendBody,
+			EnvVars:     []string{envPrefix + envPrefixWebhook + "SEND_BODY"},
+			Name:        cliPrefixWebhook + "send-body",
+			Usage:       "whether to send alert data as JSON body in webhook requests",
+			Value:       true,
+		},
+
+		&cli.DurationFlag{
+			Category:    categoryWebhook,
+			Destination: &cfg.Webhook.Timeout,
+			EnvVars:     []string{envPrefix + envPrefixWebhook + "TIMEOUT"},
+			Name:        cliPrefixWebhook + "timeout",
+			Usage:       "max `duration` of webhook request (zero means no limit)",
+		},
+
+		&cli.IntSliceFlag{
+			Category:    categoryWebhook,
+			Destination: rawWebhookSuccessStatuses,
+			EnvVars:     []string{envPrefix + envPrefixWebhook + "SUCCESS_STATUSES"},
+			Name:        cliPrefixWebhook + "success-status",
+			Usage:       "http `status` of webhook response that means the alert was delivered (default: any 2xx)",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryWebhook,
+			Destination: &cfg.Webhook.Format,
+			EnvVars:     []string{envPrefix + envPrefixWebhook + "FORMAT"},
+			Name:        cliPrefixWebhook + "format",
+			Usage:       "`format` of webhook request body (one of 'alertmanager', 'cloudevents', 'raw', or 'template')",
+			Value:       config.WebhookFormatAlertmanager,
+		},
+
+		&cli.StringFlag{
+			Category:    categoryWebhook,
+			Destination: &cfg.Webhook.Template,
+			EnvVars:     []string{envPrefix + envPrefixWebhook + "TEMPLATE"},
+			Name:        cliPrefixWebhook + "template",
+			Usage:       "go `template` of webhook request body (for 'template' format)",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryWebhook,
+			Destination: &cfg.Webhook.ContentType,
+			EnvVars:     []string{envPrefix + envPrefixWebhook + "CONTENT_TYPE"},
+			Name:        cliPrefixWebhook + "content-type",
+			Usage:       "content `type` of webhook request body (for 'template' format)",
+			Value:       "application/json",
+		},
+
+		&cli.StringSliceFlag{
+			Category:    categoryWebhook,
+			Destination: rawWebhookHeaders,
+			EnvVars:     []string{envPrefix + envPrefixWebhook + "HEADERS"},
+			Name:        cliPrefixWebhook + "header",
+			Usage:       "`header` to add to webhook requests (in 'Name=value' format)",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryWebhook,
+			Destination: &cfg.Webhook.BasicAuthUsername,
+			EnvVars:     []string{envPrefix + envPrefixWebhook + "BASIC_AUTH_USERNAME"},
+			Name:        cliPrefixWebhook + "basic-auth-username",
+			Usage:       "`username` for basic auth of webhook requests",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryWebhook,
+			Destination: &cfg.Webhook.BasicAuthPassword,
+			EnvVars:     []string{envWebhookBasicAuthPassword},
+			Name:        cliPrefixWebhook + "basic-auth-password",
+			Usage:       "`password` for basic auth of webhook requests (either raw password, or ARN of secret manager)",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryWebhook,
+			Destination: &cfg.Webhook.BearerToken,
+			EnvVars:     []string{envWebhookBearerToken},
+			Name:        cliPrefixWebhook + "bearer-token",
+			Usage:       "bearer `token` for webhook requests (either raw token, or ARN of secret manager)",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryWebhook,
+			Destination: &cfg.Webhook.SigningSecret,
+			EnvVars:     []string{envWebhookSigningSecret},
+			Name:        cliPrefixWebhook + "signing-secret",
+			Usage:       "`secret` to sign webhook requests with HMAC-SHA256 (either raw secret, or ARN of secret manager)",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryWebhook,
+			Destination: &cfg.Webhook.CACert,
+			EnvVars:     []string{envPrefix + envPrefixWebhook + "CA_CERT"},
+			Name:        cliPrefixWebhook + "ca-cert",
+			Usage:       "CA `certificate` to verify webhook server with (PEM, or path to PEM file)",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryWebhook,
+			Destination: &cfg.Webhook.ClientCert,
+			EnvVars:     []string{envPrefix + envPrefixWebhook + "CLIENT_CERT"},
+			Name:        cliPrefixWebhook + "client-cert",
+			Usage:       "client `certificate` for webhook mTLS (PEM, or path to PEM file)",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryWebhook,
+			Destination: &cfg.Webhook.ClientKey,
+			EnvVars:     []string{envWebhookClientKey},
+			Name:        cliPrefixWebhook + "client-key",
+			Usage:       "client `key` for webhook mTLS (PEM, path to PEM file, or ARN of secret manager)",
+		},
+	}
+
+	flagsRetry := []cli.Flag{
+		&cli.IntFlag{
+			Category:    categoryRetry,
+			Destination: &cfg.Retry.MaxAttempts,
+			EnvVars:     []string{envPrefix + envPrefixRetry + "MAX_ATTEMPTS"},
+			Name:        cliPrefixRetry + "max-attempts",
+			Usage:       "max `number` of attempts to publish an alert",
+			Value:       1,
+		},
+
+		&cli.DurationFlag{
+			Category:    categoryRetry,
+			Destination: &cfg.Retry.InitialBackoff,
+			EnvVars:     []string{envPrefix + envPrefixRetry + "INITIAL_BACKOFF"},
+			Name:        cliPrefixRetry + "initial-backoff",
+			Usage:       "`duration` to wait before the first retry (doubles with every next one)",
+			Value:       500 * time.Millisecond,
+		},
+
+		&cli.DurationFlag{
+			Category:    categoryRetry,
+			Destination: &cfg.Retry.MaxBackoff,
+			EnvVars:     []string{envPrefix + envPrefixRetry + "MAX_BACKOFF"},
+			Name:        cliPrefixRetry + "max-backoff",
+			Usage:       "max `duration` to wait between retries",
+			Value:       10 * time.Second,
+		},
+	}
+
+	flagsDeadLetter := []cli.Flag{
+		&cli.StringFlag{
+			Category:    categoryDeadLetter,
+			Destination: &cfg.DeadLetter.File,
+			EnvVars:     []string{envPrefix + envPrefixDeadLetter + "FILE"},
+			Name:        cliPrefixDeadLetter + "file",
+			Usage:       "`path` to the file to append undeliverable alerts to",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryDeadLetter,
+			Destination: &cfg.DeadLetter.SQSQueueURL,
+			EnvVars:     []string{envPrefix + envPrefixDeadLetter + "SQS_QUEUE_URL"},
+			Name:        cliPrefixDeadLetter + "sqs-queue-url",
+			Usage:       "`URL` of SQS queue to send undeliverable alerts to",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryDeadLetter,
+			Destination: &cfg.DeadLetter.SQSEndpoint,
+			EnvVars:     []string{envPrefix + envPrefixDeadLetter + "SQS_ENDPOINT"},
+			Name:        cliPrefixDeadLetter + "sqs-endpoint",
+			Usage:       "custom `URL` of SQS-compatible API endpoint",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryDeadLetter,
+			Destination: &cfg.DeadLetter.WebhookURL,
+			EnvVars:     []string{envPrefix + envPrefixDeadLetter + "WEBHOOK_URL"},
+			Name:        cliPrefixDeadLetter + "webhook-url",
+			Usage:       "webhook `URL` to post undeliverable alerts to",
+		},
+	}
+
+	flags := slices.Concat(
+		flagsDB,
+		flagsProcessor,
+		flagsSlack,
+		flagsPagerDuty,
+		flagsOpsgenie,
+		flagsTeams,
+		flagsTelegram,
+		flagsDiscord,
+		flagsMattermost,
+		flagsEmail,
+		flagsWebhook,
+		flagsRetry,
+		flagsDeadLetter,
+	)
+
+	before := func(_ *cli.Context) error {
+		{ // parse the list of ignored rules
+			processorIgnoreRules := rawProcessorIgnoreRules.Value()
+			if len(processorIgnoreRules) > 0 {
+				cfg.Processor.IgnoreRules = processorIgnoreRules
+			}
+		}
+
+		{ // parse the list of matched labels
+			processorMatchLabels := rawProcessorMatchLabels.Value()
+			if len(processorMatchLabels) > 0 {
+				cfg.Processor.MatchLabels = processorMatchLabels
+			}
+		}
+
+		{ // parse the list of slack group-by labels
+			slackGroupBy := rawSlackGroupBy.Value()
+			if len(slackGroupBy) > 0 {
+				cfg.Slack.GroupBy = slackGroupBy
+			}
+		}
+
+		{ // parse the list of opsgenie tag labels
+			opsgenieTagLabels := rawOpsgenieTagLabels.Value()
+			if len(opsgenieTagLabels) > 0 {
+				cfg.Opsgenie.TagLabels = opsgenieTagLabels
+			}
+		}
+
+		{ // parse webhook headers
+			webhookHeaders := rawWebhookHeaders.Value()
+			if len(webhookHeaders) > 0 {
+				cfg.Webhook.Headers = make(map[string]string, len(webhookHeaders))
+				for _, h := range webhookHeaders {
+					name, value, ok := strings.Cut(h, "=")
+					if !ok || strings.TrimSpace(name) == "" {
+						return fmt.Errorf("%w: %s",
+							config.ErrWebhookHeaderInvalid, h,
+						)
+					}
+					cfg.Webhook.Headers[strings.TrimSpace(name)] = value
+				}
+			}
+		}
+
+		{ // parse the list of webhook success statuses
+			webhookSuccessStatuses := rawWebhookSuccessStatuses.Value()
+			if len(webhookSuccessStatuses) > 0 {
+				cfg.Webhook.SuccessStatuses = webhookSuccessStatuses
+			}
+		}
+
+		{ // parse the list of default email recipients
+			emailTo := rawEmailTo.Value()
+			if len(emailTo) > 0 {
+				cfg.Email.To = emailTo
+			}
+		}
+
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+
+		var err error
+
+		cfg.Redis.Password, err = stringOrLoadFromSecretsmanager(
+			cfg.Redis.Password, envRedisPassword)
+		if err != nil {
+			return err
+		}
+
+		if cfg.Slack.Token != "" {
+			cfg.Slack.Token, err = stringOrLoadFromSecretsmanager(cfg.Slack.Token, envSlackToken)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, ch := range cfg.Slack.Channels {
+			if ch.Token != "" {
+				// each channel has its own key in the secret (so that the
+				// tokens of all of them can be kept in the same one)
+				secretKey := ch.SecretKey
+				if secretKey == "" {
+					secretKey = envSlackToken + "_" + ch.ID
+				}
+				ch.Token, err = stringOrLoadFromSecretsmanager(ch.Token, secretKey)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		cfg.Slack.SigningSecret, err = stringOrLoadFromSecretsmanager(
+			cfg.Slack.SigningSecret, envSlackSigningSecret)
+		if err != nil {
+			return err
+		}
+
+		cfg.PagerDuty.IntegrationKey, err = stringOrLoadFromSecretsmanager(
+			cfg.PagerDuty.IntegrationKey, envPagerDutyIntegrationKey)
+		if err != nil {
+			return err
+		}
+
+		cfg.Opsgenie.APIKey, err = stringOrLoadFromSecretsmanager(
+			cfg.Opsgenie.APIKey, envOpsgenieAPIKey)
+		if err != nil {
+			return err
+		}
+
+		for idx, rk := range cfg.PagerDuty.RoutingKeys {
+			// each routing key has its own key in the secret (so that they
+			// all can be kept in the same one)
+			secretKey := rk.SecretKey
+			if secretKey == "" {
+				secretKey = fmt.Sprintf("%s_%d", envPagerDutyIntegrationKey, idx)
+			}
+			rk.Key, err = stringOrLoadFromSecretsmanager(rk.Key, secretKey)
+			if err != nil {
+				return err
+			}
+		}
+
+		cfg.Telegram.BotToken, err = stringOrLoadFromSecretsmanager(
+			cfg.Telegram.BotToken, envTelegramBotToken)
+		if err != nil {
+			return err
+		}
+
+		cfg.Discord.BotToken, err = stringOrLoadFromSecretsmanager(
+			cfg.Discord.BotToken, envDiscordBotToken)
+		if err != nil {
+			return err
+		}
+
+		cfg.Mattermost.Token, err = stringOrLoadFromSecretsmanager(
+			cfg.Mattermost.Token, envMattermostToken)
+		if err != nil {
+			return err
+		}
+
+		cfg.Email.Username, err = stringOrLoadFromSecretsmanager(
+			cfg.Email.Username, envEmailUsername)
+		if err != nil {
+			return err
+		}
+
+		cfg.Email.Password, err = stringOrLoadFromSecretsmanager(
+			cfg.Email.Password, envEmailPassword)
+		if err != nil {
+			return err
+		}
+
+		cfg.Teams.URL, err = stringOrLoadFromSecretsmanager(
+			cfg.Teams.URL, envTeamsURL)
+		if err != nil {
+			return err
+		}
+
+		for idx, w := range append([]*config.Webhook{cfg.Webhook}, cfg.Webhooks...) {
+			// each webhook of the list has its own keys in the secret (so
+			// that the secrets of all of them can be kept in the same one)
+			secretKeySuffix := w.SecretKeySuffix
+			if secretKeySuffix == "" && idx > 0 {
+				secretKeySuffix = w.Name
+			}
+			secretKey := func(key string) string {
+				if secretKeySuffix == "" {
+					return key
+				}
+				return key + "_" + secretKeySuffix
+			}
+
+			w.URL, err = stringOrLoadFromSecretsmanager(
+				w.URL, secretKey(envWebhookURL))
+			if err != nil {
+				return err
+			}
+
+			w.BasicAuthPassword, err = stringOrLoadFromSecretsmanager(
+				w.BasicAuthPassword, secretKey(envWebhookBasicAuthPassword))
+			if err != nil {
+				return err
+			}
+
+			w.BearerToken, err = stringOrLoadFromSecretsmanager(
+				w.BearerToken, secretKey(envWebhookBearerToken))
+			if err != nil {
+				return err
+			}
+
+			w.SigningSecret, err = stringOrLoadFromSecretsmanager(
+				w.SigningSecret, secretKey(envWebhookSigningSecret))
+			if err != nil {
+				return err
+			}
+
+			w.ClientKey, err = stringOrLoadFromSecretsmanager(
+				w.ClientKey, secretKey(envWebhookClientKey))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return flags, before
+}
+
+// matcherList is the value of the flags that take label matchers.  Unlike
+// cli.StringSlice it does not split the values by commas (as they can be part
+// of the regexes): each occurrence of the flag is a matcher of its own, and
+// the env var has one matcher per line.
+type matcherList struct {
+	matchers []string
+}
+
+func (m *matcherList) Set(value string) error {
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			m.matchers = append(m.matchers, line)
+		}
+	}
+	return nil
+}
+
+func (m *matcherList) String() string {
+	return strings.Join(m.matchers, "\n")
+}
+
+func (m *matcherList) Value() []string {
+	return m.matchers
+}
+
+// loadSecretValue looks up the key in the Secrets Manager secret (it's a
+// variable, so that the tests can do without the Secrets Manager)
+var loadSecretValue = secret.AWSValue
+
+// stringOrLoadFromSecretsmanager either returns s as-is, or looks up
+// the Secrets Manager secret by ARN and looks up the key in object
+func stringOrLoadFromSecretsmanager(s, key string) (string, error) {
+	if !strings.HasPrefix(s, "arn:aws:secretsmanager:") {
+		return s, nil
+	}
+
+	return loadSecretValue(s, key)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecretArn = "arn:aws:secretsmanager:us-east-2:123456789012:secret:sink"
+
+// fakeSecret replaces the Secrets Manager with the single secret that holds
+// the values.
+func fakeSecret(t *testing.T, values map[string]string) {
+	prev := loadSecretValue
+	t.Cleanup(func() { loadSecretValue = prev })
+
+	loadSecretValue = func(arn, key string) (string, error) {
+		assert.Equal(t, testSecretArn, arn)
+		v, ok := values[key]
+		if !ok {
+			return "", fmt.Errorf("missing key: %s", key)
+		}
+		return v, nil
+	}
+}
+
+func TestPagerDutyRoutingKeysFromSecret(t *testing.T) {
+	fakeSecret(t, map[string]string{
+		"AMP_ALERTS_SINK_PUBLISHER_PAGERDUTY_INTEGRATION_KEY":   "defaultKey",
+		"AMP_ALERTS_SINK_PUBLISHER_PAGERDUTY_INTEGRATION_KEY_0": "paymentsKey",
+		"INFRA_ROUTING_KEY": "infraKey",
+	})
+
+	cfg := runAppWithConfig(t, `
+local_db:
+  path: ":memory:"
+pagerduty:
+  integration_key: `+testSecretArn+`
+  routing_keys:
+    - key: `+testSecretArn+`
+      match_labels: [team="payments"]
+    - key: `+testSecretArn+`
+      secret_key: INFRA_ROUTING_KEY
+      match_labels: [team="infra"]
+    - key: rawKey
+`, "lambda")
+
+	assert.Equal(t, "defaultKey", cfg.PagerDuty.IntegrationKey)
+	if assert.Len(t, cfg.PagerDuty.RoutingKeys, 3) {
+		assert.Equal(t, "paymentsKey", cfg.PagerDuty.RoutingKeys[0].Key)
+		assert.Equal(t, "infraKey", cfg.PagerDuty.RoutingKeys[1].Key)
+		assert.Equal(t, "rawKey", cfg.PagerDuty.RoutingKeys[2].Key)
+	}
+}
+
+func TestSlackChannelTokensFromSecret(t *testing.T) {
+	fakeSecret(t, map[string]string{
+		"AMP_ALERTS_SINK_PUBLISHER_SLACK_TOKEN":             "commonToken",
+		"AMP_ALERTS_SINK_PUBLISHER_SLACK_TOKEN_CINFRAXXXXX": "infraToken",
+		"ONCALL_SLACK_TOKEN":                                "oncallToken",
+	})
+
+	cfg := runAppWithConfig(t, `
+local_db:
+  path: ":memory:"
+slack:
+  token: `+testSecretArn+`
+  channels:
+    - id: CINFRAXXXXX
+      token: `+testSecretArn+`
+    - id: CONCALLXXXX
+      token: `+testSecretArn+`
+      secret_key: ONCALL_SLACK_TOKEN
+    - id: CCOMMONXXXX
+`, "lambda")
+
+	assert.Equal(t, "commonToken", cfg.Slack.Token)
+	if assert.Len(t, cfg.Slack.Channels, 3) {
+		assert.Equal(t, "infraToken", cfg.Slack.Channels[0].Token)
+		assert.Equal(t, "oncallToken", cfg.Slack.Channels[1].Token)
+		assert.Empty(t, cfg.Slack.Channels[2].Token)
+	}
+}
+
+func TestWebhookSecretsFromSecret(t *testing.T) {
+	fakeSecret(t, map[string]string{
+		"AMP_ALERTS_SINK_PUBLISHER_WEBHOOK_URL":                    "https://default",
+		"AMP_ALERTS_SINK_PUBLISHER_WEBHOOK_BEARER_TOKEN":           "defaultToken",
+		"AMP_ALERTS_SINK_PUBLISHER_WEBHOOK_BEARER_TOKEN_incidents": "incidentsToken",
+		"AMP_ALERTS_SINK_PUBLISHER_WEBHOOK_SIGNING_SECRET_TICKETS": "ticketsSecret",
+	})
+
+	cfg := runAppWithConfig(t, `
+local_db:
+  path: ":memory:"
+webhook:
+  url: `+testSecretArn+`
+  bearer_token: `+testSecretArn+`
+webhooks:
+  - name: incidents
+    url: https://incidents
+    bearer_token: `+testSecretArn+`
+  - name: tickets
+    url: https://tickets
+    signing_secret: `+testSecretArn+`
+    secret_key_suffix: TICKETS
+`, "lambda")
+
+	assert.Equal(t, "https://default", cfg.Webhook.URL)
+	assert.Equal(t, "defaultToken", cfg.Webhook.BearerToken)
+	if assert.Len(t, cfg.Webhooks, 2) {
+		assert.Equal(t, "incidentsToken", cfg.Webhooks[0].BearerToken)
+		assert.Equal(t, "ticketsSecret", cfg.Webhooks[1].SigningSecret)
+	}
+}
+
+func TestProcessorMatchersWithCommas(t *testing.T) {
+	cfg := runApp(t, "lambda",
+		"--processor-match-labels", `severity=~"a|b{1,3}"`,
+		"--processor-match-labels", `job=~"x,y"`,
+		"--processor-ignore-rules", `namespace=~"test-(a,b)"`,
+	)
+
+	assert.Equal(t, []string{`severity=~"a|b{1,3}"`, `job=~"x,y"`}, cfg.Processor.MatchLabels)
+	assert.Equal(t, []string{`namespace=~"test-(a,b)"`}, cfg.Processor.IgnoreRules)
+	assert.NoError(t, cfg.Processor.Validate())
+}
+
+func TestProcessorMatchersFromEnv(t *testing.T) {
+	t.Setenv("AMP_ALERTS_SINK_PROCESSOR_MATCH_LABELS", "severity=~\"a|b{1,3}\"\njob=~\"x,y\"\n")
+
+	cfg := runApp(t, "lambda")
+
+	assert.Equal(t, []string{`severity=~"a|b{1,3}"`, `job=~"x,y"`}, cfg.Processor.MatchLabels)
+}
+
+func TestProcessorRouteValidation(t *testing.T) {
+	cfg := config.New()
+	assert.NoError(t, cfg.Load([]byte(`
+processor:
+  receivers:
+    - name: default
+      publishers: [slack]
+    - name: default
+      publishers: [pagerduty]
+  route:
+    matchers: [env=prod]
+    routes:
+      - receiver: oncall
+      - receiver: default
+        routes:
+          - matchers: ['severity=~"(critical"']
+`)))
+
+	err := cfg.Processor.Validate()
+	assert.ErrorIs(t, err, config.ErrReceiverDuplicate)
+	assert.ErrorIs(t, err, config.ErrRouteReceiverUndefined)
+	assert.ErrorIs(t, err, config.ErrRouteUnknownReceiver)
+	assert.ErrorIs(t, err, config.ErrRouteInvalidMatcher)
+	assert.ErrorContains(t, err, "receivers[1]: duplicate receiver: default")
+	assert.ErrorContains(t, err, "route: root route must have a receiver")
+	assert.ErrorContains(t, err, "route.routes[0]: route refers to unknown receiver: oncall")
+	assert.ErrorContains(t, err, "route.routes[1].routes[0]: invalid route matcher")
+}
+
+func TestSlackValidationReportsAllErrors(t *testing.T) {
+	cfg := config.New()
+	cfg.Slack.Token = "testToken"
+	cfg.Slack.Format = "unknown"
+
+	err := cfg.Slack.Validate()
+	assert.ErrorIs(t, err, config.ErrSlackChannelIDNotConfigured)
+	assert.ErrorIs(t, err, config.ErrSlackFormatInvalid)
+}
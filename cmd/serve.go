@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/processor"
+	"github.com/flashbots/amp-alerts-sink/server"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	categoryServer = "SERVER:"
+)
+
+func CommandServe(cfg *config.Config) *cli.Command {
+	envPrefixServer := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(categoryServer, " ", "_"), ":", "")) + "_"
+	cliPrefixServer := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(categoryServer, " ", "-"), ":", "")) + "-"
+
+	envServerAlertmanagerBasicAuthPassword := envPrefix + envPrefixServer + "ALERTMANAGER_BASIC_AUTH_PASSWORD"
+	envServerAlertmanagerBearerToken := envPrefix + envPrefixServer + "ALERTMANAGER_BEARER_TOKEN"
+
+	flagsProcessor, beforeProcessor := processorFlags(cfg)
+
+	rawServerSnsTopicArns := &cli.StringSlice{}
+
+	flagsServer := []cli.Flag{
+		&cli.StringFlag{
+			Category:    categoryServer,
+			Destination: &cfg.Server.ListenAddress,
+			EnvVars:     []string{envPrefix + envPrefixServer + "LISTEN_ADDRESS"},
+			Name:        cliPrefixServer + "listen-address",
+			Usage:       "`host:port` for the server to listen on",
+			Value:       "0.0.0.0:8080",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryServer,
+			Destination: &cfg.Server.AlertmanagerBasicAuthUsername,
+			EnvVars:     []string{envPrefix + envPrefixServer + "ALERTMANAGER_BASIC_AUTH_USERNAME"},
+			Name:        cliPrefixServer + "alertmanager-basic-auth-username",
+			Usage:       "`username` that alertmanager webhooks must authenticate with",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryServer,
+			Destination: &cfg.Server.AlertmanagerBasicAuthPassword,
+			EnvVars:     []string{envServerAlertmanagerBasicAuthPassword},
+			Name:        cliPrefixServer + "alertmanager-basic-auth-password",
+			Usage:       "`password` that alertmanager webhooks must authenticate with (either raw password, or ARN of secret manager)",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryServer,
+			Destination: &cfg.Server.AlertmanagerBearerToken,
+			EnvVars:     []string{envServerAlertmanagerBearerToken},
+			Name:        cliPrefixServer + "alertmanager-bearer-token",
+			Usage:       "bearer `token` that alertmanager webhooks must authenticate with (either raw token, or ARN of secret manager)",
+		},
+
+		&cli.StringSliceFlag{
+			Category:    categoryServer,
+			Destination: rawServerSnsTopicArns,
+			EnvVars:     []string{envPrefix + envPrefixServer + "SNS_TOPIC_ARNS"},
+			Name:        cliPrefixServer + "sns-topic-arn",
+			Usage:       "`arn` of sns topic to accept the subscriptions and notifications from (the messages of other topics are rejected)",
+		},
+	}
+
+	flags := slices.Concat(
+		flagsProcessor,
+		flagsServer,
+	)
+
+	return &cli.Command{
+		Name:  "serve",
+		Usage: "Run http server that accepts alertmanager webhooks and sns notifications",
+		Flags: flags,
+
+		Before: func(clictx *cli.Context) error {
+			cfg.SetRunMode(config.RunModeServe)
+			if err := applyConfigFile(clictx, cfg); err != nil {
+				return err
+			}
+			if err := setupLogger(cfg); err != nil {
+				return err
+			}
+			if err := beforeProcessor(clictx); err != nil {
+				return err
+			}
+
+			{ // parse the list of allowed sns topics
+				snsTopicArns := rawServerSnsTopicArns.Value()
+				if len(snsTopicArns) > 0 {
+					cfg.Server.SnsTopicArns = snsTopicArns
+				}
+			}
+
+			var err error
+
+			cfg.Server.AlertmanagerBasicAuthPassword, err = stringOrLoadFromSecretsmanager(
+				cfg.Server.AlertmanagerBasicAuthPassword, envServerAlertmanagerBasicAuthPassword)
+			if err != nil {
+				return err
+			}
+
+			cfg.Server.AlertmanagerBearerToken, err = stringOrLoadFromSecretsmanager(
+				cfg.Server.AlertmanagerBearerToken, envServerAlertmanagerBearerToken)
+			if err != nil {
+				return err
+			}
+
+			return nil
+		},
+
+		Action: func(clictx *cli.Context) error {
+			p, err := processor.New(cfg)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return server.New(cfg.Server, p, p.SlackInteractions()).Run(ctx)
+		},
+	}
+}
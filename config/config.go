@@ -1,23 +1,185 @@
 package config
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
 type Config struct {
-	DynamoDB  *DynamoDB  `yaml:"dynamo_db"`
-	Log       *Log       `yaml:"log"`
-	Processor *Processor `yaml:"processor"`
+	DeadLetter *DeadLetter `yaml:"dead_letter"`
+	DynamoDB   *DynamoDB   `yaml:"dynamo_db"`
+	LocalDB    *LocalDB    `yaml:"local_db"`
+	Log        *Log        `yaml:"log"`
+	Processor  *Processor  `yaml:"processor"`
+	Redis      *Redis      `yaml:"redis"`
+	Retry      *Retry      `yaml:"retry"`
+	Server     *Server     `yaml:"server"`
+
+	Discord    *Discord    `yaml:"discord"`
+	Email      *Email      `yaml:"email"`
+	Mattermost *Mattermost `yaml:"mattermost"`
+	Opsgenie   *Opsgenie   `yaml:"opsgenie"`
+	PagerDuty  *PagerDuty  `yaml:"pagerduty"`
+	Slack      *Slack      `yaml:"slack"`
+	Teams      *Teams      `yaml:"teams"`
+	Telegram   *Telegram   `yaml:"telegram"`
+	Webhook    *Webhook    `yaml:"webhook"`
+	Webhooks   []*Webhook  `yaml:"webhooks"`
 
-	PagerDuty *PagerDuty `yaml:"pagerduty"`
-	Slack     *Slack     `yaml:"slack"`
-	Webhook   *Webhook   `yaml:"webhook"`
+	// RunMode is the mode that the sink runs in (it's set by the command, and
+	// not by the config file).
+	RunMode string `yaml:"-"`
 }
 
+const (
+	RunModeLambda = "lambda"
+	RunModeServe  = "serve"
+)
+
+var (
+	ErrConfigInvalid   = errors.New("invalid config")
+	ErrDBAmbiguous     = errors.New("only one db must be configured")
+	ErrDBNotConfigured = errors.New("db must be configured (either dynamo db, local db, or redis)")
+)
+
 func New() *Config {
 	return &Config{
-		DynamoDB:  &DynamoDB{},
-		Log:       &Log{},
-		Processor: &Processor{},
+		DeadLetter: &DeadLetter{},
+		DynamoDB:   &DynamoDB{},
+		LocalDB:    &LocalDB{},
+		Log:        &Log{},
+		Processor:  &Processor{},
+		Redis:      &Redis{},
+		Retry:      &Retry{},
+		Server:     &Server{},
+
+		Discord:    &Discord{},
+		Email:      &Email{Templates: &EmailTemplates{}},
+		Mattermost: &Mattermost{},
+		Opsgenie:   &Opsgenie{},
+		PagerDuty:  &PagerDuty{},
+		Slack:      &Slack{Channel: &SlackChannel{}, Templates: &SlackTemplates{}},
+		Teams:      &Teams{},
+		Telegram:   &Telegram{},
+		Webhook:    &Webhook{},
+	}
+}
+
+// SetRunMode sets the mode that the sink runs in (for the config itself, and
+// for the publishers that behave differently depending on it).
+func (c *Config) SetRunMode(mode string) {
+	c.RunMode = mode
+	c.PagerDuty.RunMode = mode
+	c.Slack.RunMode = mode
+}
+
+// Load applies YAML document on top of the config.  The fields that are
+// absent in the document keep their current values.
+func (c *Config) Load(data []byte) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: %w",
+			ErrConfigInvalid, err,
+		)
+	}
+
+	return nil
+}
+
+// Validate checks the config for consistency.
+func (c *Config) Validate() error {
+	errs := []error{}
+
+	switch dbs := c.configuredDBs(); {
+	case dbs == 0:
+		errs = append(errs, ErrDBNotConfigured)
+	case dbs > 1:
+		errs = append(errs, ErrDBAmbiguous)
+	}
+	if err := c.DeadLetter.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Discord.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Email.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Mattermost.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Opsgenie.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.PagerDuty.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Processor.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Server.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Slack.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Telegram.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Webhook.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	for idx, w := range c.Webhooks {
+		if w.Name == "" {
+			errs = append(errs, fmt.Errorf("%w: webhooks[%d]",
+				ErrWebhookNameNotConfigured, idx,
+			))
+		}
+		if w.URL == "" {
+			errs = append(errs, fmt.Errorf("%w: webhooks[%d]",
+				ErrWebhookURLNotConfigured, idx,
+			))
+		}
+		if err := w.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("webhooks[%d]: %w", idx, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
 
-		PagerDuty: &PagerDuty{},
-		Slack:     &Slack{Channel: &SlackChannel{}},
-		Webhook:   &Webhook{},
+// EnabledWebhooks returns the list of all configured webhooks (that is, the
+// single webhook configured via flags followed by the list of the webhooks
+// from the config file).
+func (c *Config) EnabledWebhooks() []*Webhook {
+	res := make([]*Webhook, 0, len(c.Webhooks)+1)
+	if c.Webhook.Enabled() {
+		res = append(res, c.Webhook)
+	}
+	for _, w := range c.Webhooks {
+		if w.Enabled() {
+			res = append(res, w)
+		}
+	}
+	return res
+}
+
+func (c *Config) configuredDBs() int {
+	count := 0
+	if c.DynamoDB.Name != "" {
+		count++
+	}
+	if c.LocalDB.Path != "" {
+		count++
+	}
+	if c.Redis.Address != "" {
+		count++
 	}
+	return count
 }
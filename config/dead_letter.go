@@ -0,0 +1,31 @@
+package config
+
+import "errors"
+
+type DeadLetter struct {
+	File        string `yaml:"file"`
+	SQSEndpoint string `yaml:"sqs_endpoint"`
+	SQSQueueURL string `yaml:"sqs_queue_url"`
+	WebhookURL  string `yaml:"webhook_url"`
+}
+
+var (
+	ErrDeadLetterAmbiguous = errors.New("only one dead-letter sink must be configured")
+)
+
+func (d *DeadLetter) Enabled() bool {
+	return d.File != "" || d.SQSQueueURL != "" || d.WebhookURL != ""
+}
+
+func (d *DeadLetter) Validate() error {
+	count := 0
+	for _, sink := range []string{d.File, d.SQSQueueURL, d.WebhookURL} {
+		if sink != "" {
+			count++
+		}
+	}
+	if count > 1 {
+		return ErrDeadLetterAmbiguous
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package config
+
+import "errors"
+
+type Discord struct {
+	BotToken  string `yaml:"bot_token"`
+	ChannelID string `yaml:"channel_id"`
+
+	// APIURL overrides the default API host (e.g. for testing against a local
+	// stand-in).
+	APIURL string `yaml:"api_url"`
+}
+
+var (
+	ErrDiscordChannelIDNotConfigured = errors.New("discord channel id must be configured")
+)
+
+func (d *Discord) Enabled() bool {
+	return d.BotToken != ""
+}
+
+func (d *Discord) Validate() error {
+	if d.Enabled() && d.ChannelID == "" {
+		return ErrDiscordChannelIDNotConfigured
+	}
+	return nil
+}
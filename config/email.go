@@ -0,0 +1,120 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/flashbots/amp-alerts-sink/matcher"
+	"github.com/flashbots/amp-alerts-sink/template"
+)
+
+type Email struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	TLS      string `yaml:"tls"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+
+	From       string            `yaml:"from"`
+	To         []string          `yaml:"to"`
+	Recipients []*EmailRecipient `yaml:"recipients"`
+
+	Templates *EmailTemplates `yaml:"templates"`
+}
+
+// EmailRecipient routes the alerts that match the labels to its own list of
+// addresses.
+type EmailRecipient struct {
+	MatchLabels []string `yaml:"match_labels"`
+	To          []string `yaml:"to"`
+}
+
+// EmailTemplates are go text/templates that override the default rendering
+// of emails (empty means the default one is used).
+type EmailTemplates struct {
+	Subject string `yaml:"subject"`
+	HTML    string `yaml:"html"`
+	Text    string `yaml:"text"`
+}
+
+const (
+	EmailTLSImplicit = "tls"
+	EmailTLSNone     = "none"
+	EmailTLSStartTLS = "starttls"
+)
+
+var (
+	ErrEmailFromNotConfigured       = errors.New("email sender must be configured")
+	ErrEmailRecipientsNotConfigured = errors.New("email recipients must be configured")
+	ErrEmailTLSInvalid              = errors.New("invalid email tls mode (must be one of 'starttls', 'tls', or 'none')")
+)
+
+func (e *Email) Enabled() bool {
+	return e.Host != ""
+}
+
+func (e *Email) Validate() error {
+	if !e.Enabled() {
+		return nil
+	}
+
+	errs := []error{}
+	if e.From == "" {
+		errs = append(errs, ErrEmailFromNotConfigured)
+	}
+	if len(e.To) == 0 && len(e.Recipients) == 0 {
+		errs = append(errs, ErrEmailRecipientsNotConfigured)
+	}
+	switch e.TLS {
+	case "", EmailTLSImplicit, EmailTLSNone, EmailTLSStartTLS:
+	default:
+		errs = append(errs, fmt.Errorf("%w: %s",
+			ErrEmailTLSInvalid, e.TLS,
+		))
+	}
+	for idx, r := range e.Recipients {
+		if len(r.To) == 0 {
+			errs = append(errs, fmt.Errorf("%w: recipients[%d]",
+				ErrEmailRecipientsNotConfigured, idx,
+			))
+		}
+		if _, err := r.MatchLabelsMatchers(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if e.Templates != nil {
+		if err := e.Templates.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MatchLabelsMatchers parses label matchers that alerts must satisfy to be
+// sent to the recipient.
+func (r *EmailRecipient) MatchLabelsMatchers() (matcher.Matchers, error) {
+	res, err := matcher.ParseList(r.MatchLabels)
+	if err != nil {
+		return nil, fmt.Errorf("%w: email recipient: %w",
+			ErrProcessorInvalidLabelMatch, err,
+		)
+	}
+	return res, nil
+}
+
+func (t *EmailTemplates) Validate() error {
+	errs := []error{}
+	for name, text := range map[string]string{
+		"email-subject": t.Subject,
+		"email-html":    t.HTML,
+		"email-text":    t.Text,
+	} {
+		if text == "" {
+			continue
+		}
+		if _, err := template.New(name, text); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
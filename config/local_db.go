@@ -0,0 +1,9 @@
+package config
+
+// LocalDBInMemory is the special path that makes local db keep the data in
+// memory only.
+const LocalDBInMemory = ":memory:"
+
+type LocalDB struct {
+	Path string `yaml:"path"`
+}
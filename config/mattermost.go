@@ -0,0 +1,31 @@
+package config
+
+import "errors"
+
+type Mattermost struct {
+	URL       string `yaml:"url"`
+	Token     string `yaml:"token"`
+	ChannelID string `yaml:"channel_id"`
+}
+
+var (
+	ErrMattermostChannelIDNotConfigured = errors.New("mattermost channel id must be configured")
+	ErrMattermostURLNotConfigured       = errors.New("mattermost url must be configured")
+)
+
+func (m *Mattermost) Enabled() bool {
+	return m.Token != ""
+}
+
+func (m *Mattermost) Validate() error {
+	if !m.Enabled() {
+		return nil
+	}
+	if m.URL == "" {
+		return ErrMattermostURLNotConfigured
+	}
+	if m.ChannelID == "" {
+		return ErrMattermostChannelIDNotConfigured
+	}
+	return nil
+}
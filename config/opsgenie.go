@@ -0,0 +1,43 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+type Opsgenie struct {
+	APIKey string `yaml:"api_key"`
+	Region string `yaml:"region"`
+
+	// APIURL overrides the API host derived from the region (e.g. for
+	// testing against a local stand-in).
+	APIURL string `yaml:"api_url"`
+
+	// TagLabels are the labels to pass to opsgenie as the alert's tags (all
+	// labels are passed when empty).
+	TagLabels []string `yaml:"tag_labels"`
+}
+
+const (
+	OpsgenieRegionEU = "eu"
+	OpsgenieRegionUS = "us"
+)
+
+var (
+	ErrOpsgenieRegionInvalid = errors.New("invalid opsgenie region (must be either 'us' or 'eu')")
+)
+
+func (o *Opsgenie) Enabled() bool {
+	return o.APIKey != ""
+}
+
+func (o *Opsgenie) Validate() error {
+	switch o.Region {
+	case "", OpsgenieRegionEU, OpsgenieRegionUS:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s",
+			ErrOpsgenieRegionInvalid, o.Region,
+		)
+	}
+}
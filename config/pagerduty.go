@@ -1,9 +1,68 @@
 package config
 
+import (
+	"errors"
+	"fmt"
+
+	"github.com/flashbots/amp-alerts-sink/matcher"
+)
+
 type PagerDuty struct {
-	IntegrationKey string `yaml:"integration_key"`
+	IntegrationKey string                 `yaml:"integration_key"`
+	RoutingKeys    []*PagerDutyRoutingKey `yaml:"routing_keys"`
+
+	// ClassLabel, ComponentLabel and GroupLabel are the names of the labels
+	// that are mapped onto the respective fields of pagerduty events.
+	ClassLabel     string `yaml:"class_label"`
+	ComponentLabel string `yaml:"component_label"`
+	GroupLabel     string `yaml:"group_label"`
+
+	// RunMode is the mode that the sink runs in (see Config.SetRunMode).
+	RunMode string `yaml:"-"`
+}
+
+// PagerDutyRoutingKey routes the alerts that match the labels to the
+// pagerduty service with the key.
+type PagerDutyRoutingKey struct {
+	Key         string   `yaml:"key"`
+	MatchLabels []string `yaml:"match_labels"`
+
+	// SecretKey is the key that the routing key is stored under in the secret
+	// (when Key is ARN of secret manager).
+	SecretKey string `yaml:"secret_key"`
 }
 
+var (
+	ErrPagerDutyRoutingKeyNotConfigured = errors.New("pagerduty routing key must be configured")
+)
+
 func (s PagerDuty) Enabled() bool {
-	return s.IntegrationKey != ""
+	return s.IntegrationKey != "" || len(s.RoutingKeys) > 0
+}
+
+func (s PagerDuty) Validate() error {
+	errs := []error{}
+	for idx, rk := range s.RoutingKeys {
+		if rk.Key == "" {
+			errs = append(errs, fmt.Errorf("%w: routing_keys[%d]",
+				ErrPagerDutyRoutingKeyNotConfigured, idx,
+			))
+		}
+		if _, err := rk.MatchLabelsMatchers(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MatchLabelsMatchers parses label matchers that alerts must satisfy to be
+// routed with the key.
+func (rk *PagerDutyRoutingKey) MatchLabelsMatchers() (matcher.Matchers, error) {
+	res, err := matcher.ParseList(rk.MatchLabels)
+	if err != nil {
+		return nil, fmt.Errorf("%w: pagerduty routing key: %w",
+			ErrProcessorInvalidLabelMatch, err,
+		)
+	}
+	return res, nil
 }
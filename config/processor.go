@@ -1,6 +1,90 @@
 package config
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/flashbots/amp-alerts-sink/matcher"
+)
+
 type Processor struct {
-	IgnoreRules []string          `yaml:"ignore_rules"`
-	MatchLabels map[string]string `yaml:"match_labels"`
+	IgnoreRules []string `yaml:"ignore_rules"`
+	MatchLabels []string `yaml:"match_labels"`
+
+	Receivers []*Receiver `yaml:"receivers"`
+	Route     *Route      `yaml:"route"`
+}
+
+var (
+	ErrProcessorInvalidIgnoreRule = errors.New("invalid ignore rule")
+	ErrProcessorInvalidLabelMatch = errors.New("invalid label match")
+)
+
+// IgnoreRuleMatchers parses ignore-rules, each of which is either a plain
+// alert name (shorthand for `alertname="<name>"`), or a label matcher.
+func (p *Processor) IgnoreRuleMatchers() ([]*matcher.Matcher, error) {
+	res := make([]*matcher.Matcher, 0, len(p.IgnoreRules))
+	errs := []error{}
+	for _, rule := range p.IgnoreRules {
+		var (
+			m   *matcher.Matcher
+			err error
+		)
+		if strings.ContainsAny(rule, "=!") {
+			m, err = matcher.Parse(rule)
+		} else {
+			m, err = matcher.New(matcher.MatchEqual, "alertname", strings.TrimSpace(rule))
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: %w",
+				ErrProcessorInvalidIgnoreRule, err,
+			))
+			continue
+		}
+		res = append(res, m)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return res, nil
+}
+
+// MatchLabelsMatchers parses label matchers that all alerts must satisfy.
+func (p *Processor) MatchLabelsMatchers() (matcher.Matchers, error) {
+	res, err := matcher.ParseList(p.MatchLabels)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w",
+			ErrProcessorInvalidLabelMatch, err,
+		)
+	}
+	return res, nil
+}
+
+func (p *Processor) Validate() error {
+	_, errIgnoreRules := p.IgnoreRuleMatchers()
+	_, errMatchLabels := p.MatchLabelsMatchers()
+	errs := []error{errIgnoreRules, errMatchLabels}
+
+	receivers := make(map[string]bool, len(p.Receivers))
+	for idx, receiver := range p.Receivers {
+		switch {
+		case receiver == nil || receiver.Name == "":
+			errs = append(errs, fmt.Errorf("receivers[%d]: %w",
+				idx, ErrReceiverNameNotConfigured,
+			))
+		case receivers[receiver.Name]:
+			errs = append(errs, fmt.Errorf("receivers[%d]: %w: %s",
+				idx, ErrReceiverDuplicate, receiver.Name,
+			))
+		default:
+			receivers[receiver.Name] = true
+		}
+	}
+
+	if p.Route != nil {
+		errs = append(errs, p.Route.validate("route", receivers, true))
+	}
+
+	return errors.Join(errs...)
 }
@@ -0,0 +1,10 @@
+package config
+
+type Redis struct {
+	Address   string `yaml:"address"`
+	DB        int    `yaml:"db"`
+	KeyPrefix string `yaml:"key_prefix"`
+	Password  string `yaml:"password"`
+	TLS       bool   `yaml:"tls"`
+	Username  string `yaml:"username"`
+}
@@ -0,0 +1,13 @@
+package config
+
+import "time"
+
+type Retry struct {
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxAttempts    int           `yaml:"max_attempts"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+func (r *Retry) Enabled() bool {
+	return r.MaxAttempts > 1
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/flashbots/amp-alerts-sink/matcher"
+)
+
+// Route is the node of alertmanager-style routing tree.
+type Route struct {
+	Receiver string   `yaml:"receiver"`
+	Matchers []string `yaml:"matchers"`
+	Continue bool     `yaml:"continue"`
+	Routes   []*Route `yaml:"routes"`
+}
+
+// Receiver maps the name that routes refer to onto the list of publishers.
+type Receiver struct {
+	Name       string   `yaml:"name"`
+	Publishers []string `yaml:"publishers"`
+}
+
+var (
+	ErrReceiverDuplicate         = errors.New("duplicate receiver")
+	ErrReceiverNameNotConfigured = errors.New("receiver name must be configured")
+	ErrRouteEmpty                = errors.New("route must not be empty")
+	ErrRouteInvalidMatcher       = errors.New("invalid route matcher")
+	ErrRouteReceiverUndefined    = errors.New("root route must have a receiver")
+	ErrRouteUnknownReceiver      = errors.New("route refers to unknown receiver")
+)
+
+// MatchersList parses the matchers of the route.
+func (r *Route) MatchersList() (matcher.Matchers, error) {
+	res, err := matcher.ParseList(r.Matchers)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w",
+			ErrRouteInvalidMatcher, err,
+		)
+	}
+	return res, nil
+}
+
+// validate checks the route and its sub-routes (the errors are prefixed with
+// the path of the route they are about, e.g. `route.routes[1]`).
+func (r *Route) validate(path string, receivers map[string]bool, isRoot bool) error {
+	if r == nil {
+		return fmt.Errorf("%s: %w", path, ErrRouteEmpty)
+	}
+
+	errs := []error{}
+
+	if _, err := r.MatchersList(); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", path, err))
+	}
+
+	switch {
+	case r.Receiver == "" && isRoot:
+		errs = append(errs, fmt.Errorf("%s: %w", path, ErrRouteReceiverUndefined))
+	case r.Receiver != "" && !receivers[r.Receiver]:
+		errs = append(errs, fmt.Errorf("%s: %w: %s",
+			path, ErrRouteUnknownReceiver, r.Receiver,
+		))
+	}
+
+	for idx, child := range r.Routes {
+		if err := child.validate(fmt.Sprintf("%s.routes[%d]", path, idx), receivers, false); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
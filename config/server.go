@@ -0,0 +1,29 @@
+package config
+
+import "errors"
+
+type Server struct {
+	ListenAddress string `yaml:"listen_address"`
+
+	// AlertmanagerBasicAuthUsername, AlertmanagerBasicAuthPassword, and
+	// AlertmanagerBearerToken (only one of) protect alertmanager endpoint.
+	AlertmanagerBasicAuthUsername string `yaml:"alertmanager_basic_auth_username"`
+	AlertmanagerBasicAuthPassword string `yaml:"alertmanager_basic_auth_password"`
+	AlertmanagerBearerToken       string `yaml:"alertmanager_bearer_token"`
+
+	// SnsTopicArns are the topics that sns endpoint accepts the subscriptions
+	// and notifications from (the messages of all other topics are rejected).
+	SnsTopicArns []string `yaml:"sns_topic_arns"`
+}
+
+var (
+	ErrServerAuthAmbiguous = errors.New("only one of server's alertmanager basic auth or bearer token must be configured")
+)
+
+func (s *Server) Validate() error {
+	hasBasicAuth := s.AlertmanagerBasicAuthUsername != "" || s.AlertmanagerBasicAuthPassword != ""
+	if hasBasicAuth && s.AlertmanagerBearerToken != "" {
+		return ErrServerAuthAmbiguous
+	}
+	return nil
+}
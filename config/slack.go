@@ -1,11 +1,141 @@
 package config
 
+import (
+	"errors"
+	"fmt"
+)
+
 type Slack struct {
-	Channel *SlackChannel `yaml:"channel"`
-	Token   string        `yaml:"slack"`
+	Channel   *SlackChannel   `yaml:"channel"`
+	Channels  []*SlackChannel `yaml:"channels"`
+	Format    string          `yaml:"format"`
+	Mentions  []*SlackMention `yaml:"mentions"`
+	Templates *SlackTemplates `yaml:"templates"`
+	Token     string          `yaml:"token"`
+
+	// UpdateOnResolve makes the thread-starting message to be updated in-place
+	// when the alert is resolved.
+	UpdateOnResolve bool `yaml:"update_on_resolve"`
+
+	// Group makes the alerts of the same group to be published into the thread
+	// of group's summary message (the groups are defined by GroupBy labels, or
+	// by alertmanager's group labels, if there are none).
+	Group   bool     `yaml:"group"`
+	GroupBy []string `yaml:"group_by"`
+
+	// SigningSecret enables interactive buttons (and the verification of the
+	// requests that slack sends when they are clicked).
+	SigningSecret string `yaml:"signing_secret"`
+
+	// AlertmanagerURL is where the silences are created when the alert does
+	// not carry the external URL of its alertmanager.
+	AlertmanagerURL string `yaml:"alertmanager_url"`
+
+	// RunMode is the mode that the sink runs in (see Config.SetRunMode).
+	RunMode string `yaml:"-"`
 }
 
+const (
+	SlackFormatAttachment = "attachment"
+	SlackFormatBlocks     = "blocks"
+)
+
+var (
+	ErrSlackFormatInvalid             = errors.New("invalid slack message format (must be either 'attachment' or 'blocks')")
+	ErrSlackChannelIDNotConfigured    = errors.New("slack channel ID must be configured")
+	ErrSlackChannelTokenNotConfigured = errors.New("slack token must be configured")
+)
+
 func (s *Slack) Enabled() bool {
-	return s.Token != "" &&
-		s.Channel != nil && s.Channel.ID != ""
+	return len(s.EnabledChannels()) > 0
+}
+
+// InteractiveEnabled returns true when slack messages must carry interactive
+// (acknowledge, silence) buttons.  The clicks are only handled by the http
+// server, so there are no buttons when the sink runs as lambda.
+func (s *Slack) InteractiveEnabled() bool {
+	return s.SigningSecret != "" && s.RunMode == RunModeServe
+}
+
+// EnabledChannels returns the list of all configured channels (that is, the
+// single channel configured via flags followed by the list of the channels
+// from the config file) that can be published to.
+func (s *Slack) EnabledChannels() []*SlackChannel {
+	res := make([]*SlackChannel, 0, len(s.Channels)+1)
+	if s.Channel != nil && s.Channel.ID != "" && s.Token != "" {
+		res = append(res, s.Channel)
+	}
+	for _, ch := range s.Channels {
+		if ch.ID != "" && (ch.Token != "" || s.Token != "") {
+			res = append(res, ch)
+		}
+	}
+	return res
+}
+
+// ForChannel returns the copy of slack config narrowed down to just one
+// channel (with channel's token and mentions overriding the common ones, if
+// set).
+func (s *Slack) ForChannel(ch *SlackChannel) *Slack {
+	res := *s
+	res.Channel = ch
+	res.Channels = nil
+	if ch.Token != "" {
+		res.Token = ch.Token
+	}
+	if ch.Mentions != nil {
+		res.Mentions = ch.Mentions
+	}
+	return &res
+}
+
+func (s *Slack) Validate() error {
+	errs := []error{}
+
+	if s.Token != "" && (s.Channel == nil || s.Channel.ID == "") && len(s.Channels) == 0 {
+		errs = append(errs, ErrSlackChannelIDNotConfigured)
+	}
+	switch s.Format {
+	case "", SlackFormatAttachment, SlackFormatBlocks:
+	default:
+		errs = append(errs, fmt.Errorf("%w: %s",
+			ErrSlackFormatInvalid, s.Format,
+		))
+	}
+	if s.Templates != nil {
+		if err := s.Templates.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for idx, m := range s.Mentions {
+		if err := m.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("mentions[%d]: %w", idx, err))
+		}
+	}
+	if s.Channel != nil {
+		if _, err := s.Channel.MatchLabelsMatchers(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for idx, ch := range s.Channels {
+		if ch.ID == "" {
+			errs = append(errs, fmt.Errorf("%w: channels[%d]",
+				ErrSlackChannelIDNotConfigured, idx,
+			))
+		}
+		if ch.Token == "" && s.Token == "" {
+			errs = append(errs, fmt.Errorf("%w: channels[%d]",
+				ErrSlackChannelTokenNotConfigured, idx,
+			))
+		}
+		if _, err := ch.MatchLabelsMatchers(); err != nil {
+			errs = append(errs, err)
+		}
+		for idx2, m := range ch.Mentions {
+			if err := m.Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("channels[%d].mentions[%d]: %w", idx, idx2, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
 }
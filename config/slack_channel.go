@@ -1,5 +1,42 @@
 package config
 
+import (
+	"fmt"
+
+	"github.com/flashbots/amp-alerts-sink/matcher"
+)
+
 type SlackChannel struct {
-	ID string `yaml:"id"`
+	ID          string   `yaml:"id"`
+	Name        string   `yaml:"name"`
+	Token       string   `yaml:"token"`
+	MatchLabels []string `yaml:"match_labels"`
+
+	// SecretKey is the key that the token is stored under in the secret (when
+	// Token is ARN of secret manager).
+	SecretKey string `yaml:"secret_key"`
+
+	// Mentions (if set) override the common mention rules for the channel.
+	Mentions []*SlackMention `yaml:"mentions"`
+}
+
+// PublisherName returns the name by which the routes can refer to the
+// channel's publisher.
+func (ch *SlackChannel) PublisherName() string {
+	if ch.Name != "" {
+		return ch.Name
+	}
+	return "slack-" + ch.ID
+}
+
+// MatchLabelsMatchers parses label matchers that alerts must satisfy to be
+// published into the channel.
+func (ch *SlackChannel) MatchLabelsMatchers() (matcher.Matchers, error) {
+	res, err := matcher.ParseList(ch.MatchLabels)
+	if err != nil {
+		return nil, fmt.Errorf("%w: slack channel %s: %w",
+			ErrProcessorInvalidLabelMatch, ch.ID, err,
+		)
+	}
+	return res, nil
 }
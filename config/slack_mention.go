@@ -0,0 +1,44 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/flashbots/amp-alerts-sink/matcher"
+)
+
+// SlackMention is the rule that defines who must be mentioned when matching
+// alert starts firing.
+type SlackMention struct {
+	MatchLabels []string `yaml:"match_labels"`
+	Severity    string   `yaml:"severity"`
+
+	Channel    bool     `yaml:"channel"`
+	Here       bool     `yaml:"here"`
+	UserGroups []string `yaml:"user_groups"`
+	Users      []string `yaml:"users"`
+}
+
+var (
+	ErrSlackMentionNobody = errors.New("slack mention must mention somebody")
+)
+
+// MatchLabelsMatchers parses label matchers that alerts must satisfy for the
+// mention to apply.
+func (m *SlackMention) MatchLabelsMatchers() (matcher.Matchers, error) {
+	res, err := matcher.ParseList(m.MatchLabels)
+	if err != nil {
+		return nil, fmt.Errorf("%w: slack mention: %w",
+			ErrProcessorInvalidLabelMatch, err,
+		)
+	}
+	return res, nil
+}
+
+func (m *SlackMention) Validate() error {
+	if !m.Channel && !m.Here && len(m.UserGroups) == 0 && len(m.Users) == 0 {
+		return ErrSlackMentionNobody
+	}
+	_, err := m.MatchLabelsMatchers()
+	return err
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"errors"
+
+	"github.com/flashbots/amp-alerts-sink/template"
+)
+
+// SlackTemplates are go text/templates that override the default rendering
+// of slack messages (empty means the default one is used).
+type SlackTemplates struct {
+	Color  string `yaml:"color"`
+	Footer string `yaml:"footer"`
+	Text   string `yaml:"text"`
+	Title  string `yaml:"title"`
+}
+
+func (t *SlackTemplates) Validate() error {
+	errs := []error{}
+	for name, text := range map[string]string{
+		"slack-color":  t.Color,
+		"slack-footer": t.Footer,
+		"slack-text":   t.Text,
+		"slack-title":  t.Title,
+	} {
+		if text == "" {
+			continue
+		}
+		if _, err := template.New(name, text); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
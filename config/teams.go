@@ -0,0 +1,9 @@
+package config
+
+type Teams struct {
+	URL string `yaml:"url"`
+}
+
+func (t *Teams) Enabled() bool {
+	return t.URL != ""
+}
@@ -0,0 +1,27 @@
+package config
+
+import "errors"
+
+type Telegram struct {
+	BotToken string `yaml:"bot_token"`
+	ChatID   string `yaml:"chat_id"`
+
+	// APIURL overrides the default bot API host (e.g. for testing against a
+	// local stand-in, or for self-hosted bot API server).
+	APIURL string `yaml:"api_url"`
+}
+
+var (
+	ErrTelegramChatIDNotConfigured = errors.New("telegram chat id must be configured")
+)
+
+func (t *Telegram) Enabled() bool {
+	return t.BotToken != ""
+}
+
+func (t *Telegram) Validate() error {
+	if t.Enabled() && t.ChatID == "" {
+		return ErrTelegramChatIDNotConfigured
+	}
+	return nil
+}
@@ -1,11 +1,158 @@
 package config
 
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/flashbots/amp-alerts-sink/matcher"
+	"github.com/flashbots/amp-alerts-sink/template"
+	"gopkg.in/yaml.v3"
+)
+
 type Webhook struct {
-	URL      string `yaml:"url"`
-	Method   string `yaml:"method"`
-	SendBody bool   `yaml:"send_body"`
+	Name        string   `yaml:"name"`
+	URL         string   `yaml:"url"`
+	Method      string   `yaml:"method"`
+	SendBody    bool     `yaml:"send_body"`
+	MatchLabels []string `yaml:"match_labels"`
+
+	// Timeout limits the duration of each request (zero means no limit).
+	Timeout time.Duration `yaml:"timeout"`
+
+	// SuccessStatuses are the response codes that mean the alert was
+	// delivered (empty means any 2xx).
+	SuccessStatuses []int `yaml:"success_statuses"`
+
+	// Format is the format of request body (see WebhookFormat* constants).
+	Format string `yaml:"format"`
+
+	// Template and ContentType define the body for "template" format.
+	Template    string `yaml:"template"`
+	ContentType string `yaml:"content_type"`
+
+	// Headers are added to each request as-is.
+	Headers map[string]string `yaml:"headers"`
+
+	// BasicAuth* or BearerToken (only one of them) authenticate the requests.
+	BasicAuthUsername string `yaml:"basic_auth_username"`
+	BasicAuthPassword string `yaml:"basic_auth_password"`
+	BearerToken       string `yaml:"bearer_token"`
+
+	// SigningSecret enables HMAC-SHA256 signature of the requests.
+	SigningSecret string `yaml:"signing_secret"`
+
+	// ClientCert and ClientKey (PEM, or paths to PEM files) enable mTLS, and
+	// CACert (same) replaces the system's root CAs.
+	CACert     string `yaml:"ca_cert"`
+	ClientCert string `yaml:"client_cert"`
+	ClientKey  string `yaml:"client_key"`
+
+	// SecretKeySuffix is appended (after an underscore) to the keys that the
+	// secrets are stored under in the secret (when they are ARNs of secret
+	// manager).  The webhooks of the list default to their names.
+	SecretKeySuffix string `yaml:"secret_key_suffix"`
 }
 
+const (
+	WebhookFormatAlertmanager = "alertmanager"
+	WebhookFormatCloudEvents  = "cloudevents"
+	WebhookFormatRaw          = "raw"
+	WebhookFormatTemplate     = "template"
+)
+
+var (
+	ErrWebhookAuthAmbiguous         = errors.New("only one of webhook basic auth or bearer token must be configured")
+	ErrWebhookClientCertIncomplete  = errors.New("both webhook client certificate and its key must be configured")
+	ErrWebhookNameNotConfigured     = errors.New("webhook name must be configured")
+	ErrWebhookURLNotConfigured      = errors.New("webhook url must be configured")
+	ErrWebhookHeaderInvalid         = errors.New("invalid webhook header (must be in 'Name=value' format)")
+	ErrWebhookFormatInvalid         = errors.New("invalid webhook format (must be one of 'alertmanager', 'cloudevents', 'raw', or 'template')")
+	ErrWebhookTemplateNotConfigured = errors.New("webhook template must be configured for 'template' format")
+)
+
 func (w *Webhook) Enabled() bool {
 	return w.URL != ""
 }
+
+// UnmarshalYAML makes the webhooks from the config file send the body unless
+// told otherwise (same as with the flags).
+func (w *Webhook) UnmarshalYAML(value *yaml.Node) error {
+	w.SendBody = true
+
+	// node.Decode would silently ignore unknown fields
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	type webhook Webhook
+	return dec.Decode((*webhook)(w))
+}
+
+// PublisherName returns the name by which the routes can refer to the
+// webhook's publisher.
+func (w *Webhook) PublisherName() string {
+	if w.Name != "" {
+		return w.Name
+	}
+	return "webhook"
+}
+
+// DBNamespace returns the namespace in which the webhook keeps track of the
+// alerts it sent.
+func (w *Webhook) DBNamespace() string {
+	if w.Name != "" {
+		return "webhook-" + w.Name
+	}
+	urlHash := sha256.Sum256([]byte(w.URL))
+	return "webhook-" + hex.EncodeToString(urlHash[:])
+}
+
+// MatchLabelsMatchers parses label matchers that alerts must satisfy to be
+// sent to the webhook.
+func (w *Webhook) MatchLabelsMatchers() (matcher.Matchers, error) {
+	res, err := matcher.ParseList(w.MatchLabels)
+	if err != nil {
+		return nil, fmt.Errorf("%w: webhook %s: %w",
+			ErrProcessorInvalidLabelMatch, w.Name, err,
+		)
+	}
+	return res, nil
+}
+
+func (w *Webhook) Validate() error {
+	errs := []error{}
+
+	if _, err := w.MatchLabelsMatchers(); err != nil {
+		errs = append(errs, err)
+	}
+
+	switch w.Format {
+	case "", WebhookFormatAlertmanager, WebhookFormatCloudEvents, WebhookFormatRaw:
+	case WebhookFormatTemplate:
+		if w.Template == "" {
+			errs = append(errs, ErrWebhookTemplateNotConfigured)
+		} else if _, err := template.New("webhook", w.Template); err != nil {
+			errs = append(errs, err)
+		}
+	default:
+		errs = append(errs, fmt.Errorf("%w: %s",
+			ErrWebhookFormatInvalid, w.Format,
+		))
+	}
+
+	if (w.BasicAuthUsername != "" || w.BasicAuthPassword != "") && w.BearerToken != "" {
+		errs = append(errs, ErrWebhookAuthAmbiguous)
+	}
+	if (w.ClientCert == "") != (w.ClientKey == "") {
+		errs = append(errs, ErrWebhookClientCertIncomplete)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,263 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/flashbots/amp-alerts-sink/logutils"
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+type boltDb struct {
+	db        *bbolt.DB
+	lastPurge *atomic.Int64 // unix milliseconds (shared by all namespaces)
+	namespace string
+}
+
+type boltItem struct {
+	ExpireOn int64  `json:"expire_on"` // unix milliseconds
+	Value    string `json:"value,omitempty"`
+}
+
+const (
+	boltPurgeInterval = time.Minute
+	timeoutBoltOpen   = 5 * time.Second
+)
+
+func newBoltDb(path string) (*boltDb, error) {
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{
+		Timeout: timeoutBoltOpen,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bdb := &boltDb{
+		db:        db,
+		lastPurge: &atomic.Int64{},
+	}
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		return bdb.purge(tx, time.Now())
+	}); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return bdb, nil
+}
+
+func (bdb *boltDb) Lock(
+	ctx context.Context,
+	key string,
+	expireIn time.Duration,
+) (bool, error) {
+	didLock := false
+
+	err := bdb.db.Update(func(tx *bbolt.Tx) error {
+		now := time.Now()
+		if err := bdb.purge(tx, now); err != nil {
+			return err
+		}
+
+		b, err := tx.CreateBucketIfNotExists(bdb.bucket())
+		if err != nil {
+			return err
+		}
+
+		// same as conditional put in dynamo db: fail if the item exists
+		if item, exists := getBoltItem(b, key); exists && now.UnixMilli() < item.ExpireOn {
+			return nil
+		}
+
+		if err := putBoltItem(b, key, &boltItem{
+			ExpireOn: now.Add(expireIn).UnixMilli(),
+		}); err != nil {
+			return err
+		}
+
+		didLock = true
+		return nil
+	})
+
+	if err != nil {
+		logutils.LoggerFromContext(ctx).Error("Bolt DB failed to lock the key",
+			zap.Error(err),
+			zap.String("key", key),
+			zap.String("namespace", bdb.namespace),
+		)
+		return false, err
+	}
+
+	return didLock, nil
+}
+
+func (bdb *boltDb) Unlock(
+	ctx context.Context,
+	key string,
+) error {
+	err := bdb.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bdb.bucket())
+		if b == nil {
+			return nil
+		}
+
+		// only the locks are released, the items that were set stay as they are
+		if item, exists := getBoltItem(b, key); exists && item.Value == "" {
+			return b.Delete([]byte(key))
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		logutils.LoggerFromContext(ctx).Error("Bolt DB failed to unlock the key",
+			zap.Error(err),
+			zap.String("key", key),
+			zap.String("namespace", bdb.namespace),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (bdb *boltDb) Set(
+	ctx context.Context,
+	key string,
+	expireIn time.Duration,
+	value string,
+) error {
+	err := bdb.db.Update(func(tx *bbolt.Tx) error {
+		now := time.Now()
+		if err := bdb.purge(tx, now); err != nil {
+			return err
+		}
+
+		b, err := tx.CreateBucketIfNotExists(bdb.bucket())
+		if err != nil {
+			return err
+		}
+
+		return putBoltItem(b, key, &boltItem{
+			ExpireOn: now.Add(expireIn).UnixMilli(),
+			Value:    value,
+		})
+	})
+
+	if err != nil {
+		logutils.LoggerFromContext(ctx).Error("Bolt DB failed to set the key",
+			zap.Error(err),
+			zap.String("key", key),
+			zap.String("namespace", bdb.namespace),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (bdb *boltDb) Get(
+	ctx context.Context,
+	key string,
+) (string, error) {
+	var value string
+
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bdb.bucket())
+		if b == nil {
+			return nil
+		}
+
+		if item, exists := getBoltItem(b, key); exists && time.Now().UnixMilli() < item.ExpireOn {
+			value = item.Value
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		logutils.LoggerFromContext(ctx).Error("Bolt DB failed to get the key",
+			zap.Error(err),
+			zap.String("key", key),
+			zap.String("namespace", bdb.namespace),
+		)
+		return "", err
+	}
+
+	return value, nil
+}
+
+func (bdb *boltDb) WithNamespace(namespace string) DB {
+	return &boltDb{
+		db:        bdb.db,
+		lastPurge: bdb.lastPurge,
+		namespace: namespace,
+	}
+}
+
+// bucket returns the name of the bucket for the namespace (bolt doesn't
+// allow empty bucket names).
+func (bdb *boltDb) bucket() []byte {
+	return []byte("namespace/" + bdb.namespace)
+}
+
+// purge removes expired items from all namespaces (at most once per purge
+// interval).  It's done within the write transactions, so that the file does
+// not grow indefinitely while the sink keeps running.  The time of the purge
+// is only remembered once the transaction is committed (otherwise the next
+// write has to try again).
+func (bdb *boltDb) purge(tx *bbolt.Tx, now time.Time) error {
+	if now.UnixMilli()-bdb.lastPurge.Load() < boltPurgeInterval.Milliseconds() {
+		return nil
+	}
+
+	if err := tx.ForEach(func(_ []byte, b *bbolt.Bucket) error {
+		expired := [][]byte{}
+		if err := b.ForEach(func(k, v []byte) error {
+			item := &boltItem{}
+			if err := json.Unmarshal(v, item); err != nil || now.UnixMilli() >= item.ExpireOn {
+				expired = append(expired, k)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	tx.OnCommit(func() {
+		bdb.lastPurge.Store(now.UnixMilli())
+	})
+	return nil
+}
+
+func getBoltItem(b *bbolt.Bucket, key string) (*boltItem, bool) {
+	raw := b.Get([]byte(key))
+	if raw == nil {
+		return nil, false
+	}
+	item := &boltItem{}
+	if err := json.Unmarshal(raw, item); err != nil {
+		return nil, false
+	}
+	return item, true
+}
+
+func putBoltItem(b *bbolt.Bucket, key string, item *boltItem) error {
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(key), raw)
+}
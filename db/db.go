@@ -13,6 +13,7 @@ import (
 
 type DB interface {
 	Lock(ctx context.Context, key string, expireIn time.Duration) (bool, error)
+	Unlock(ctx context.Context, key string) error
 	Set(ctx context.Context, key string, expireIn time.Duration, value string) error
 	Get(ctx context.Context, key string) (string, error)
 
@@ -23,10 +24,16 @@ var (
 	ErrDbUndefined = errors.New("no database defined")
 )
 
-func New(cfg *config.DynamoDB) (DB, error) {
+func New(cfg *config.Config) (DB, error) {
 	switch {
-	case cfg.Name != "":
-		return newDynamoDb(cfg.Name)
+	case cfg.DynamoDB.Name != "":
+		return newDynamoDb(cfg.DynamoDB.Name)
+	case cfg.Redis.Address != "":
+		return newRedisDb(cfg.Redis)
+	case cfg.LocalDB.Path == config.LocalDBInMemory:
+		return newMemoryDb(), nil
+	case cfg.LocalDB.Path != "":
+		return newBoltDb(cfg.LocalDB.Path)
 	}
 
 	return nil, ErrDbUndefined
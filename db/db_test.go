@@ -0,0 +1,221 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/bbolt"
+)
+
+func testLocalDBs(t *testing.T) map[string]DB {
+	bdb, err := newBoltDb(filepath.Join(t.TempDir(), "test.db"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = bdb.db.Close() })
+
+	return map[string]DB{
+		"memory": newMemoryDb(),
+		"bolt":   bdb,
+	}
+}
+
+func TestLocalDBLock(t *testing.T) {
+	for name, db := range testLocalDBs(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			didLock, err := db.Lock(ctx, "key", time.Minute)
+			assert.NoError(t, err)
+			assert.True(t, didLock)
+
+			// locked item has no value
+			v, err := db.Get(ctx, "key")
+			assert.NoError(t, err)
+			assert.Empty(t, v)
+
+			didLock, err = db.Lock(ctx, "key", time.Minute)
+			assert.NoError(t, err)
+			assert.False(t, didLock)
+
+			// set items can not be locked either
+			assert.NoError(t, db.Set(ctx, "other", time.Minute, "value"))
+			didLock, err = db.Lock(ctx, "other", time.Minute)
+			assert.NoError(t, err)
+			assert.False(t, didLock)
+
+			// expired locks can be re-acquired
+			didLock, err = db.Lock(ctx, "short", 10*time.Millisecond)
+			assert.NoError(t, err)
+			assert.True(t, didLock)
+			time.Sleep(20 * time.Millisecond)
+			didLock, err = db.Lock(ctx, "short", time.Minute)
+			assert.NoError(t, err)
+			assert.True(t, didLock)
+		})
+	}
+}
+
+func TestLocalDBUnlock(t *testing.T) {
+	for name, db := range testLocalDBs(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			didLock, err := db.Lock(ctx, "key", time.Minute)
+			assert.NoError(t, err)
+			assert.True(t, didLock)
+
+			assert.NoError(t, db.Unlock(ctx, "key"))
+			didLock, err = db.Lock(ctx, "key", time.Minute)
+			assert.NoError(t, err)
+			assert.True(t, didLock)
+
+			// set items are not unlocked
+			assert.NoError(t, db.Set(ctx, "other", time.Minute, "value"))
+			assert.NoError(t, db.Unlock(ctx, "other"))
+			v, err := db.Get(ctx, "other")
+			assert.NoError(t, err)
+			assert.Equal(t, "value", v)
+
+			// missing items are fine too
+			assert.NoError(t, db.Unlock(ctx, "missing"))
+		})
+	}
+}
+
+func TestLocalDBSetGet(t *testing.T) {
+	for name, db := range testLocalDBs(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			v, err := db.Get(ctx, "key")
+			assert.NoError(t, err)
+			assert.Empty(t, v)
+
+			assert.NoError(t, db.Set(ctx, "key", time.Minute, "value"))
+			v, err = db.Get(ctx, "key")
+			assert.NoError(t, err)
+			assert.Equal(t, "value", v)
+
+			assert.NoError(t, db.Set(ctx, "expiring", 10*time.Millisecond, "value"))
+			time.Sleep(20 * time.Millisecond)
+			v, err = db.Get(ctx, "expiring")
+			assert.NoError(t, err)
+			assert.Empty(t, v)
+		})
+	}
+}
+
+func TestLocalDBNamespaces(t *testing.T) {
+	for name, db := range testLocalDBs(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			foo := db.WithNamespace("foo")
+			bar := db.WithNamespace("bar")
+
+			assert.NoError(t, foo.Set(ctx, "key", time.Minute, "foo"))
+
+			v, err := bar.Get(ctx, "key")
+			assert.NoError(t, err)
+			assert.Empty(t, v)
+
+			didLock, err := bar.Lock(ctx, "key", time.Minute)
+			assert.NoError(t, err)
+			assert.True(t, didLock)
+
+			v, err = db.WithNamespace("foo").Get(ctx, "key")
+			assert.NoError(t, err)
+			assert.Equal(t, "foo", v)
+		})
+	}
+}
+
+func TestBoltDBPurge(t *testing.T) {
+	bdb, err := newBoltDb(filepath.Join(t.TempDir(), "test.db"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = bdb.db.Close() })
+
+	ctx := context.Background()
+	foo := bdb.WithNamespace("foo").(*boltDb)
+	bar := bdb.WithNamespace("bar").(*boltDb)
+
+	exists := func(db *boltDb, key string) bool {
+		res := false
+		assert.NoError(t, db.db.View(func(tx *bbolt.Tx) error {
+			if b := tx.Bucket(db.bucket()); b != nil {
+				res = b.Get([]byte(key)) != nil
+			}
+			return nil
+		}))
+		return res
+	}
+
+	assert.NoError(t, foo.Set(ctx, "expiring", 10*time.Millisecond, "value"))
+	time.Sleep(20 * time.Millisecond)
+
+	// not yet the time to purge
+	assert.NoError(t, bar.Set(ctx, "other", time.Minute, "value"))
+	assert.True(t, exists(foo, "expiring"))
+
+	// writes to any namespace purge the expired items of all of them
+	bdb.lastPurge.Store(time.Now().Add(-boltPurgeInterval).UnixMilli())
+	assert.NoError(t, bar.Set(ctx, "other", time.Minute, "value"))
+	assert.False(t, exists(foo, "expiring"))
+	assert.True(t, exists(bar, "other"))
+}
+
+func TestBoltDBPurgeRollback(t *testing.T) {
+	bdb, err := newBoltDb(filepath.Join(t.TempDir(), "test.db"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = bdb.db.Close() })
+
+	ctx := context.Background()
+	foo := bdb.WithNamespace("foo").(*boltDb)
+
+	assert.NoError(t, foo.Set(ctx, "expiring", 10*time.Millisecond, "value"))
+	time.Sleep(20 * time.Millisecond)
+
+	// the purge of the rolled back transaction doesn't count
+	lastPurge := time.Now().Add(-boltPurgeInterval).UnixMilli()
+	bdb.lastPurge.Store(lastPurge)
+	errRollback := errors.New("rollback")
+	assert.ErrorIs(t, bdb.db.Update(func(tx *bbolt.Tx) error {
+		assert.NoError(t, bdb.purge(tx, time.Now()))
+		return errRollback
+	}), errRollback)
+	assert.Equal(t, lastPurge, bdb.lastPurge.Load())
+
+	// the next write purges again
+	assert.NoError(t, foo.Set(ctx, "other", time.Minute, "value"))
+	assert.Greater(t, bdb.lastPurge.Load(), lastPurge)
+	v, err := foo.Get(ctx, "expiring")
+	assert.NoError(t, err)
+	assert.Empty(t, v)
+}
+
+func TestDynamoDBItemValue(t *testing.T) {
+	now := time.Now()
+	item := func(value string, expireOn time.Time) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{
+			ddbKeyValue:    {S: aws.String(value)},
+			ddbKeyExpireOn: {N: aws.String(strconv.FormatInt(expireOn.Unix(), 10))},
+		}
+	}
+
+	assert.Equal(t, "value", ddbItemValue(item("value", now.Add(time.Minute)), now))
+	assert.Empty(t, ddbItemValue(nil, now))
+
+	// not yet purged by dynamo db, but expired already
+	assert.Empty(t, ddbItemValue(item("value", now.Add(-time.Minute)), now))
+
+	// locks have no value
+	assert.Empty(t, ddbItemValue(map[string]*dynamodb.AttributeValue{
+		ddbKeyExpireOn: {N: aws.String(strconv.FormatInt(now.Add(time.Minute).Unix(), 10))},
+	}, now))
+}
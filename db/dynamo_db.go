@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -45,6 +46,8 @@ func (ddb *dynamoDb) Lock(
 	ctx, cancel := context.WithTimeout(ctx, time.Second)
 	defer cancel()
 
+	now := time.Now()
+
 	input := &dynamodb.PutItemInput{
 		TableName: aws.String(ddb.name),
 
@@ -53,12 +56,20 @@ func (ddb *dynamoDb) Lock(
 			ddbKeyId:        {S: aws.String(key)},
 
 			ddbKeyExpireOn: {N: aws.String(fmt.Sprintf("%d",
-				time.Now().Add(expireIn).Unix(),
+				now.Add(expireIn).Unix(),
 			))},
 		},
 
-		ConditionExpression:      aws.String("attribute_not_exists(#id)"),
-		ExpressionAttributeNames: map[string]*string{"#id": aws.String(ddbKeyId)},
+		// dynamo db purges expired items eventually (which can take hours),
+		// so the expired ones must be treated as non-existent
+		ConditionExpression: aws.String("attribute_not_exists(#id) OR #expire_on < :now"),
+		ExpressionAttributeNames: map[string]*string{
+			"#id":        aws.String(ddbKeyId),
+			"#expire_on": aws.String(ddbKeyExpireOn),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(fmt.Sprintf("%d", now.Unix()))},
+		},
 	}
 	output, err := ddb.cli.PutItemWithContext(ctx, input)
 
@@ -80,6 +91,45 @@ func (ddb *dynamoDb) Lock(
 	return false, err
 }
 
+func (ddb *dynamoDb) Unlock(
+	ctx context.Context,
+	key string,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(ddb.name),
+
+		Key: map[string]*dynamodb.AttributeValue{
+			ddbKeyNamespace: {S: aws.String(ddb.namespace)},
+			ddbKeyId:        {S: aws.String(key)},
+		},
+
+		// only the locks are released, the items that were set stay as they are
+		ConditionExpression:      aws.String("attribute_not_exists(#value)"),
+		ExpressionAttributeNames: map[string]*string{"#value": aws.String(ddbKeyValue)},
+	}
+	output, err := ddb.cli.DeleteItemWithContext(ctx, input)
+
+	if err == nil {
+		return nil
+	}
+	if _, didCndChkFail := err.(*dynamodb.ConditionalCheckFailedException); didCndChkFail {
+		return nil
+	}
+
+	// error
+
+	logutils.LoggerFromContext(ctx).Error("Dynamo DB failed to unlock the key",
+		zap.Any("input", input),
+		zap.Any("output", output),
+		zap.Error(err),
+		zap.String("key", key),
+	)
+	return err
+}
+
 func (ddb *dynamoDb) Set(
 	ctx context.Context,
 	key string,
@@ -145,17 +195,30 @@ func (ddb *dynamoDb) Get(
 		return "", err
 	}
 
-	if len(output.Item) == 0 {
-		return "", nil
+	return ddbItemValue(output.Item, time.Now()), nil
+}
+
+// ddbItemValue returns the value of the item (or empty string, if there's no
+// such item or if it has expired).  Dynamo DB purges expired items eventually
+// (which can take hours), so the expired ones must be treated as non-existent.
+func ddbItemValue(item map[string]*dynamodb.AttributeValue, now time.Time) string {
+	if len(item) == 0 {
+		return ""
+	}
+
+	if expireOn := item[ddbKeyExpireOn]; expireOn != nil && expireOn.N != nil {
+		if ts, err := strconv.ParseInt(*expireOn.N, 10, 64); err == nil && ts < now.Unix() {
+			return ""
+		}
 	}
 
-	value := output.Item[ddbKeyValue]
+	value := item[ddbKeyValue]
 	switch {
 	// TODO: fill-in other case?
-	case value.S != nil:
-		return *value.S, nil
+	case value != nil && value.S != nil:
+		return *value.S
 	default:
-		return "", nil
+		return ""
 	}
 }
 
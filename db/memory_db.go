@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryDb struct {
+	items     *memoryItems
+	namespace string
+}
+
+type memoryItems struct {
+	mx sync.Mutex
+
+	items     map[memoryKey]memoryItem
+	lastPurge time.Time
+}
+
+type memoryKey struct {
+	namespace string
+	id        string
+}
+
+type memoryItem struct {
+	expireOn time.Time
+	value    string
+}
+
+const (
+	memoryPurgeInterval = time.Minute
+)
+
+func newMemoryDb() *memoryDb {
+	return &memoryDb{
+		items: &memoryItems{
+			items:     make(map[memoryKey]memoryItem),
+			lastPurge: time.Now(),
+		},
+	}
+}
+
+func (mdb *memoryDb) Lock(
+	_ context.Context,
+	key string,
+	expireIn time.Duration,
+) (bool, error) {
+	mdb.items.mx.Lock()
+	defer mdb.items.mx.Unlock()
+
+	now := time.Now()
+	mdb.items.purge(now)
+
+	k := memoryKey{namespace: mdb.namespace, id: key}
+
+	// same as conditional put in dynamo db: fail if the item exists
+	if item, exists := mdb.items.items[k]; exists && now.Before(item.expireOn) {
+		return false, nil
+	}
+
+	mdb.items.items[k] = memoryItem{
+		expireOn: now.Add(expireIn),
+	}
+
+	return true, nil
+}
+
+func (mdb *memoryDb) Unlock(
+	_ context.Context,
+	key string,
+) error {
+	mdb.items.mx.Lock()
+	defer mdb.items.mx.Unlock()
+
+	k := memoryKey{namespace: mdb.namespace, id: key}
+
+	// only the locks are released, the items that were set stay as they are
+	if item, exists := mdb.items.items[k]; exists && item.value == "" {
+		delete(mdb.items.items, k)
+	}
+
+	return nil
+}
+
+func (mdb *memoryDb) Set(
+	_ context.Context,
+	key string,
+	expireIn time.Duration,
+	value string,
+) error {
+	mdb.items.mx.Lock()
+	defer mdb.items.mx.Unlock()
+
+	now := time.Now()
+	mdb.items.purge(now)
+
+	mdb.items.items[memoryKey{namespace: mdb.namespace, id: key}] = memoryItem{
+		expireOn: now.Add(expireIn),
+		value:    value,
+	}
+
+	return nil
+}
+
+func (mdb *memoryDb) Get(
+	_ context.Context,
+	key string,
+) (string, error) {
+	mdb.items.mx.Lock()
+	defer mdb.items.mx.Unlock()
+
+	item, exists := mdb.items.items[memoryKey{namespace: mdb.namespace, id: key}]
+	if !exists || !time.Now().Before(item.expireOn) {
+		return "", nil
+	}
+
+	return item.value, nil
+}
+
+func (mdb *memoryDb) WithNamespace(namespace string) DB {
+	return &memoryDb{
+		items:     mdb.items,
+		namespace: namespace,
+	}
+}
+
+// purge removes expired items (at most once per purge interval).
+func (mi *memoryItems) purge(now time.Time) {
+	if now.Sub(mi.lastPurge) < memoryPurgeInterval {
+		return
+	}
+	for k, item := range mi.items {
+		if !now.Before(item.expireOn) {
+			delete(mi.items, k)
+		}
+	}
+	mi.lastPurge = now
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"time"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/logutils"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type redisDb struct {
+	cli       *redis.Client
+	keyPrefix string
+	namespace string
+}
+
+const (
+	redisDefaultKeyPrefix = "amp-alerts-sink"
+)
+
+// redisUnlock deletes the key only if it's a lock (that is, it has no value)
+var redisUnlock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == "" then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func newRedisDb(cfg *config.Redis) (*redisDb, error) {
+	opts := &redis.Options{
+		Addr:     cfg.Address,
+		DB:       cfg.DB,
+		Password: cfg.Password,
+		Username: cfg.Username,
+	}
+	if cfg.TLS {
+		opts.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	keyPrefix := cfg.KeyPrefix
+	if keyPrefix == "" {
+		keyPrefix = redisDefaultKeyPrefix
+	}
+
+	return &redisDb{
+		cli:       redis.NewClient(opts),
+		keyPrefix: keyPrefix,
+	}, nil
+}
+
+func (rdb *redisDb) Lock(
+	ctx context.Context,
+	key string,
+	expireIn time.Duration,
+) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	didLock, err := rdb.cli.SetNX(ctx, rdb.key(key), "", expireIn).Result()
+	if err != nil {
+		logutils.LoggerFromContext(ctx).Error("Redis failed to lock the key",
+			zap.Error(err),
+			zap.String("key", key),
+			zap.String("namespace", rdb.namespace),
+		)
+		return false, err
+	}
+
+	return didLock, nil
+}
+
+func (rdb *redisDb) Unlock(
+	ctx context.Context,
+	key string,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	if err := redisUnlock.Run(ctx, rdb.cli, []string{rdb.key(key)}).Err(); err != nil && !errors.Is(err, redis.Nil) {
+		logutils.LoggerFromContext(ctx).Error("Redis failed to unlock the key",
+			zap.Error(err),
+			zap.String("key", key),
+			zap.String("namespace", rdb.namespace),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (rdb *redisDb) Set(
+	ctx context.Context,
+	key string,
+	expireIn time.Duration,
+	value string,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	if err := rdb.cli.Set(ctx, rdb.key(key), value, expireIn).Err(); err != nil {
+		logutils.LoggerFromContext(ctx).Error("Redis failed to set the key",
+			zap.Error(err),
+			zap.String("key", key),
+			zap.String("namespace", rdb.namespace),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (rdb *redisDb) Get(
+	ctx context.Context,
+	key string,
+) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	value, err := rdb.cli.Get(ctx, rdb.key(key)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		logutils.LoggerFromContext(ctx).Error("Redis failed to get the key",
+			zap.Error(err),
+			zap.String("key", key),
+			zap.String("namespace", rdb.namespace),
+		)
+		return "", err
+	}
+
+	return value, nil
+}
+
+func (rdb *redisDb) WithNamespace(namespace string) DB {
+	return &redisDb{
+		cli:       rdb.cli,
+		keyPrefix: rdb.keyPrefix,
+		namespace: namespace,
+	}
+}
+
+func (rdb *redisDb) key(key string) string {
+	return rdb.keyPrefix + ":" + rdb.namespace + ":" + key
+}
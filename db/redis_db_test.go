@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupRedisDb(t *testing.T) (DB, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+
+	rdb, err := newRedisDb(&config.Redis{
+		Address: mr.Addr(),
+	})
+	assert.NoError(t, err)
+
+	return rdb, mr
+}
+
+func TestRedisDBLock(t *testing.T) {
+	db, mr := setupRedisDb(t)
+	ctx := context.Background()
+	ns := db.WithNamespace("slack-testChannelID")
+
+	didLock, err := ns.Lock(ctx, "key", time.Second)
+	assert.NoError(t, err)
+	assert.True(t, didLock)
+	assert.True(t, mr.Exists("amp-alerts-sink:slack-testChannelID:key"))
+	assert.Equal(t, time.Second, mr.TTL("amp-alerts-sink:slack-testChannelID:key"))
+
+	didLock, err = ns.Lock(ctx, "key", time.Second)
+	assert.NoError(t, err)
+	assert.False(t, didLock)
+
+	// other namespaces are not affected
+	didLock, err = db.WithNamespace("other").Lock(ctx, "key", time.Second)
+	assert.NoError(t, err)
+	assert.True(t, didLock)
+
+	// expired locks can be re-acquired
+	mr.FastForward(2 * time.Second)
+	didLock, err = ns.Lock(ctx, "key", time.Second)
+	assert.NoError(t, err)
+	assert.True(t, didLock)
+}
+
+func TestRedisDBUnlock(t *testing.T) {
+	db, mr := setupRedisDb(t)
+	ctx := context.Background()
+	ns := db.WithNamespace("webhook")
+
+	didLock, err := ns.Lock(ctx, "key", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, didLock)
+
+	assert.NoError(t, ns.Unlock(ctx, "key"))
+	assert.False(t, mr.Exists("amp-alerts-sink:webhook:key"))
+
+	// set items are not unlocked
+	assert.NoError(t, ns.Set(ctx, "other", time.Minute, "value"))
+	assert.NoError(t, ns.Unlock(ctx, "other"))
+	assert.True(t, mr.Exists("amp-alerts-sink:webhook:other"))
+
+	// missing items are fine too
+	assert.NoError(t, ns.Unlock(ctx, "missing"))
+}
+
+func TestRedisDBSetGet(t *testing.T) {
+	db, mr := setupRedisDb(t)
+	ctx := context.Background()
+	ns := db.WithNamespace("webhook")
+
+	v, err := ns.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Empty(t, v)
+
+	assert.NoError(t, ns.Set(ctx, "key", time.Hour, "value"))
+	v, err = ns.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", v)
+	assert.Equal(t, time.Hour, mr.TTL("amp-alerts-sink:webhook:key"))
+
+	// set items can not be locked
+	didLock, err := ns.Lock(ctx, "key", time.Second)
+	assert.NoError(t, err)
+	assert.False(t, didLock)
+
+	mr.FastForward(2 * time.Hour)
+	v, err = ns.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Empty(t, v)
+}
+
+func TestRedisDBUnavailable(t *testing.T) {
+	db, mr := setupRedisDb(t)
+	ctx := context.Background()
+	mr.Close()
+
+	_, err := db.Get(ctx, "key")
+	assert.Error(t, err)
+
+	didLock, err := db.Lock(ctx, "key", time.Second)
+	assert.Error(t, err)
+	assert.False(t, didLock)
+}
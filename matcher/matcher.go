@@ -0,0 +1,153 @@
+package matcher
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Type is the type of the label matcher (same as in prometheus).
+type Type int
+
+const (
+	MatchEqual Type = iota
+	MatchNotEqual
+	MatchRegexp
+	MatchNotRegexp
+)
+
+var operators = map[Type]string{
+	MatchEqual:     "=",
+	MatchNotEqual:  "!=",
+	MatchRegexp:    "=~",
+	MatchNotRegexp: "!~",
+}
+
+var (
+	ErrMatcherInvalid         = errors.New("invalid label matcher (must be 'label=value', 'label!=value', 'label=~regex' or 'label!~regex')")
+	ErrMatcherInvalidRegexp   = errors.New("invalid regular expression in label matcher")
+	ErrMatcherInvalidLabel    = errors.New("invalid label name in label matcher")
+	ErrMatcherInvalidQuotes   = errors.New("invalid quoting of the value in label matcher")
+	ErrMatcherUnknownOperator = errors.New("unknown operator in label matcher")
+)
+
+// Matcher matches the value of one label.
+type Matcher struct {
+	Name  string
+	Type  Type
+	Value string
+
+	re *regexp.Regexp
+}
+
+func New(t Type, name, value string) (*Matcher, error) {
+	if _, known := operators[t]; !known {
+		return nil, fmt.Errorf("%w: %d",
+			ErrMatcherUnknownOperator, t,
+		)
+	}
+
+	m := &Matcher{
+		Name:  name,
+		Type:  t,
+		Value: value,
+	}
+
+	if t == MatchRegexp || t == MatchNotRegexp {
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w",
+				ErrMatcherInvalidRegexp, value, err,
+			)
+		}
+		m.re = re
+	}
+
+	return m, nil
+}
+
+// Parse parses the matcher in prometheus syntax (e.g. `label=value`,
+// `label!="value"`, `label=~regex` or `label!~"regex"`).
+func Parse(s string) (*Matcher, error) {
+	s = strings.TrimSpace(s)
+
+	idx := strings.IndexAny(s, "=!")
+	if idx < 0 {
+		return nil, fmt.Errorf("%w: %s",
+			ErrMatcherInvalid, s,
+		)
+	}
+
+	name := strings.TrimSpace(s[:idx])
+	if !isValidLabelName(name) {
+		return nil, fmt.Errorf("%w: %s",
+			ErrMatcherInvalidLabel, s,
+		)
+	}
+
+	var t Type
+	rest := s[idx:]
+	switch {
+	case strings.HasPrefix(rest, "=~"):
+		t = MatchRegexp
+	case strings.HasPrefix(rest, "!~"):
+		t = MatchNotRegexp
+	case strings.HasPrefix(rest, "!="):
+		t = MatchNotEqual
+	case strings.HasPrefix(rest, "="):
+		t = MatchEqual
+	default:
+		return nil, fmt.Errorf("%w: %s",
+			ErrMatcherInvalid, s,
+		)
+	}
+
+	value := strings.TrimSpace(rest[len(operators[t]):])
+	if strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "`") {
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w",
+				ErrMatcherInvalidQuotes, s, err,
+			)
+		}
+		value = unquoted
+	}
+
+	return New(t, name, value)
+}
+
+// Matches returns true if the value satisfies the matcher.
+func (m *Matcher) Matches(value string) bool {
+	switch m.Type {
+	case MatchEqual:
+		return value == m.Value
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(value)
+	case MatchNotRegexp:
+		return !m.re.MatchString(value)
+	}
+	return false
+}
+
+func (m *Matcher) String() string {
+	return m.Name + operators[m.Type] + strconv.Quote(m.Value)
+}
+
+func isValidLabelName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for idx, char := range name {
+		if !(char == '_' ||
+			(char >= 'a' && char <= 'z') ||
+			(char >= 'A' && char <= 'Z') ||
+			(char >= '0' && char <= '9' && idx > 0)) {
+			return false
+		}
+	}
+	return true
+}
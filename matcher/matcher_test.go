@@ -0,0 +1,90 @@
+package matcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		input string
+		name  string
+		typ   Type
+		value string
+	}{
+		{input: "team=infra", name: "team", typ: MatchEqual, value: "infra"},
+		{input: ` team = "infra" `, name: "team", typ: MatchEqual, value: "infra"},
+		{input: "team!=infra", name: "team", typ: MatchNotEqual, value: "infra"},
+		{input: "team=~infra|payments", name: "team", typ: MatchRegexp, value: "infra|payments"},
+		{input: `team!~"infra.*"`, name: "team", typ: MatchNotRegexp, value: "infra.*"},
+		{input: "query=a=b", name: "query", typ: MatchEqual, value: "a=b"},
+		{input: "empty=", name: "empty", typ: MatchEqual, value: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			m, err := Parse(tc.input)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.name, m.Name)
+			assert.Equal(t, tc.typ, m.Type)
+			assert.Equal(t, tc.value, m.Value)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := map[string]error{
+		"team":         ErrMatcherInvalid,
+		"=infra":       ErrMatcherInvalidLabel,
+		"1team=infra":  ErrMatcherInvalidLabel,
+		"team=~(":      ErrMatcherInvalidRegexp,
+		`team="infra`:  ErrMatcherInvalidQuotes,
+		"team!infra":   ErrMatcherInvalid,
+		"te am=infra":  ErrMatcherInvalidLabel,
+		"team!~\"[a\"": ErrMatcherInvalidRegexp,
+	}
+
+	for input, expected := range testCases {
+		t.Run(input, func(t *testing.T) {
+			_, err := Parse(input)
+			assert.ErrorIs(t, err, expected)
+		})
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	ms, err := ParseList([]string{
+		"team=infra",
+		"severity=~critical|warning",
+		"env!=dev",
+		`instance!~"test-.*"`,
+	})
+	assert.NoError(t, err)
+
+	assert.True(t, ms.Matches(map[string]string{
+		"team":     "infra",
+		"severity": "critical",
+		"instance": "prod-1",
+	}))
+
+	assert.False(t, ms.Matches(map[string]string{
+		"team":     "infra",
+		"severity": "info",
+	}))
+
+	assert.False(t, ms.Matches(map[string]string{
+		"team":     "infra",
+		"severity": "warning",
+		"instance": "test-1",
+	}))
+
+	mismatch := ms.Mismatch(map[string]string{
+		"team":     "infra",
+		"severity": "warning",
+		"env":      "dev",
+	})
+	assert.Equal(t, "env", mismatch.Name)
+
+	assert.True(t, Matchers{}.Matches(nil))
+}
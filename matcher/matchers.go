@@ -0,0 +1,52 @@
+package matcher
+
+import (
+	"errors"
+	"strings"
+)
+
+// Matchers is a list of matchers that must all match for the labels to match.
+type Matchers []*Matcher
+
+// ParseList parses the list of matchers in prometheus syntax.
+func ParseList(ss []string) (Matchers, error) {
+	res := make(Matchers, 0, len(ss))
+	errs := []error{}
+	for _, s := range ss {
+		m, err := Parse(s)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		res = append(res, m)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return res, nil
+}
+
+// Matches returns true if all matchers match the labels.  Absent labels are
+// treated as if they had an empty value.
+func (ms Matchers) Matches(labels map[string]string) bool {
+	return ms.Mismatch(labels) == nil
+}
+
+// Mismatch returns the first matcher that does not match the labels (or nil,
+// if all of them match).
+func (ms Matchers) Mismatch(labels map[string]string) *Matcher {
+	for _, m := range ms {
+		if !m.Matches(labels[m.Name]) {
+			return m
+		}
+	}
+	return nil
+}
+
+func (ms Matchers) String() string {
+	parts := make([]string, 0, len(ms))
+	for _, m := range ms {
+		parts = append(parts, m.String())
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
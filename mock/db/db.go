@@ -86,6 +86,20 @@ func (mr *MockDBMockRecorder) Set(ctx, key, expireIn, value any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockDB)(nil).Set), ctx, key, expireIn, value)
 }
 
+// Unlock mocks base method.
+func (m *MockDB) Unlock(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unlock", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unlock indicates an expected call of Unlock.
+func (mr *MockDBMockRecorder) Unlock(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unlock", reflect.TypeOf((*MockDB)(nil).Unlock), ctx, key)
+}
+
 // WithNamespace mocks base method.
 func (m *MockDB) WithNamespace(namespace string) db.DB {
 	m.ctrl.T.Helper()
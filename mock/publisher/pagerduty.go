@@ -42,6 +42,21 @@ func (m *Mock_pagerDutyClient) EXPECT() *Mock_pagerDutyClientMockRecorder {
 	return m.recorder
 }
 
+// CreateChangeEventWithContext mocks base method.
+func (m *Mock_pagerDutyClient) CreateChangeEventWithContext(arg0 context.Context, arg1 pagerduty.ChangeEvent) (*pagerduty.ChangeEventResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateChangeEventWithContext", arg0, arg1)
+	ret0, _ := ret[0].(*pagerduty.ChangeEventResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateChangeEventWithContext indicates an expected call of CreateChangeEventWithContext.
+func (mr *Mock_pagerDutyClientMockRecorder) CreateChangeEventWithContext(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateChangeEventWithContext", reflect.TypeOf((*Mock_pagerDutyClient)(nil).CreateChangeEventWithContext), arg0, arg1)
+}
+
 // LastAPIResponse mocks base method.
 func (m *Mock_pagerDutyClient) LastAPIResponse() (*http.Response, bool) {
 	m.ctrl.T.Helper()
@@ -88,3 +88,25 @@ func (mr *Mock_slackApiMockRecorder) RemoveReaction(name, item any) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveReaction", reflect.TypeOf((*Mock_slackApi)(nil).RemoveReaction), name, item)
 }
+
+// UpdateMessage mocks base method.
+func (m *Mock_slackApi) UpdateMessage(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{channelID, timestamp}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "UpdateMessage", varargs...)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(string)
+	ret3, _ := ret[3].(error)
+	return ret0, ret1, ret2, ret3
+}
+
+// UpdateMessage indicates an expected call of UpdateMessage.
+func (mr *Mock_slackApiMockRecorder) UpdateMessage(channelID, timestamp any, options ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{channelID, timestamp}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateMessage", reflect.TypeOf((*Mock_slackApi)(nil).UpdateMessage), varargs...)
+}
@@ -0,0 +1,18 @@
+package processor
+
+import (
+	"context"
+
+	"github.com/flashbots/amp-alerts-sink/types"
+)
+
+// ProcessAlertmanagerWebhook processes the notification that was sent by
+// alertmanager's webhook receiver (raw is the request body it came with).
+func (p *Processor) ProcessAlertmanagerWebhook(
+	ctx context.Context,
+	source string,
+	message *types.AlertmanagerWebhook,
+	raw []byte,
+) error {
+	return p.processMessage(ctx, source, &message.AlertmanagerMessage, raw)
+}
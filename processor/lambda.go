@@ -0,0 +1,50 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var (
+	ErrLambdaUnsupportedEvent = errors.New("unsupported lambda event (must be either sns, or sqs)")
+)
+
+// lambdaEvent is the minimal subset of SNS and SQS lambda events that lets us
+// distinguish between the two.
+type lambdaEvent struct {
+	Records []struct {
+		EventSource string `json:"eventSource"` // SNS has it as "EventSource"
+	} `json:"Records"`
+}
+
+// ProcessLambdaEvent handles both SNS and SQS lambda events.
+func (p *Processor) ProcessLambdaEvent(ctx context.Context, raw json.RawMessage) (any, error) {
+	event := &lambdaEvent{}
+	if err := json.Unmarshal(raw, event); err != nil {
+		return nil, err
+	}
+	if len(event.Records) == 0 {
+		return nil, nil
+	}
+
+	switch event.Records[0].EventSource {
+	case "aws:sns":
+		snsEvent := events.SNSEvent{}
+		if err := json.Unmarshal(raw, &snsEvent); err != nil {
+			return nil, err
+		}
+		return nil, p.ProcessSnsEvent(ctx, snsEvent)
+
+	case "aws:sqs":
+		sqsEvent := events.SQSEvent{}
+		if err := json.Unmarshal(raw, &sqsEvent); err != nil {
+			return nil, err
+		}
+		return p.ProcessSqsEvent(ctx, sqsEvent)
+	}
+
+	return nil, ErrLambdaUnsupportedEvent
+}
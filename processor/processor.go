@@ -5,11 +5,14 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/flashbots/amp-alerts-sink/config"
 	"github.com/flashbots/amp-alerts-sink/db"
 	"github.com/flashbots/amp-alerts-sink/logutils"
+	"github.com/flashbots/amp-alerts-sink/matcher"
 	"github.com/flashbots/amp-alerts-sink/publisher"
 	"github.com/flashbots/amp-alerts-sink/types"
 	"go.uber.org/zap"
@@ -21,71 +24,251 @@ const (
 )
 
 var (
+	ErrPublisherDuplicate = errors.New("duplicate publisher name")
 	ErrPublisherUndefined = errors.New("no publishers defined")
 )
 
 type Processor struct {
-	ignoreRules map[string]struct{}
-	matchLabels map[string]string
+	ignoreRules []*matcher.Matcher
+	matchLabels matcher.Matchers
 	log         *zap.Logger
 	publishers  []publisher.Publisher
+	receivers   map[string][]int
+	route       *route
+
+	slackInteractions *publisher.SlackInteractions
 }
 
 func New(cfg *config.Config) (*Processor, error) {
-	db, err := db.New(cfg.DynamoDB)
+	db, err := db.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetter, err := publisher.NewDeadLetter(cfg.DeadLetter)
 	if err != nil {
 		return nil, err
 	}
 
 	publishers := make([]publisher.Publisher, 0)
-	if cfg.Slack.Enabled() {
+	publisherNames := make(map[string]int)
+	addPublisher := func(name string, pub publisher.Publisher) error {
+		if _, duplicate := publisherNames[name]; duplicate {
+			return fmt.Errorf("%w: %s",
+				ErrPublisherDuplicate, name,
+			)
+		}
+		if cfg.Retry.Enabled() || deadLetter != nil {
+			pub = publisher.NewRetrying(name, cfg.Retry, pub, deadLetter)
+		}
+		publisherNames[name] = len(publishers)
+		publishers = append(publishers, pub)
+		return nil
+	}
+
+	for _, ch := range cfg.Slack.EnabledChannels() {
 		slack, err := publisher.NewSlackChannel(
-			cfg.Slack,
-			db.WithNamespace("slack-"+cfg.Slack.Channel.ID),
+			cfg.Slack.ForChannel(ch),
+			db.WithNamespace("slack-"+ch.ID),
 		)
 		if err != nil {
 			return nil, err
 		}
-		publishers = append(publishers, slack)
+		if err := addPublisher(ch.PublisherName(), slack); err != nil {
+			return nil, err
+		}
 	}
 
 	if cfg.PagerDuty.Enabled() {
-		publishers = append(publishers, publisher.NewPagerDuty(cfg.PagerDuty))
+		pagerDuty, err := publisher.NewPagerDuty(
+			cfg.PagerDuty,
+			db.WithNamespace("pagerduty"),
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := addPublisher("pagerduty", pagerDuty); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Opsgenie.Enabled() {
+		if err := addPublisher("opsgenie", publisher.NewOpsgenie(
+			cfg.Opsgenie,
+			db.WithNamespace("opsgenie"),
+		)); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Telegram.Enabled() {
+		if err := addPublisher("telegram", publisher.NewTelegram(
+			cfg.Telegram,
+			db.WithNamespace("telegram"),
+		)); err != nil {
+			return nil, err
+		}
 	}
 
-	if cfg.Webhook.Enabled() {
-		urlHash := sha256.Sum256([]byte(cfg.Webhook.URL))
+	if cfg.Discord.Enabled() {
+		if err := addPublisher("discord", publisher.NewDiscord(
+			cfg.Discord,
+			db.WithNamespace("discord"),
+		)); err != nil {
+			return nil, err
+		}
+	}
 
-		publishers = append(publishers, publisher.NewWebhook(
-			cfg.Webhook,
-			db.WithNamespace("webhook-"+hex.EncodeToString(urlHash[:])),
-		))
+	if cfg.Mattermost.Enabled() {
+		if err := addPublisher("mattermost", publisher.NewMattermost(
+			cfg.Mattermost,
+			db.WithNamespace("mattermost"),
+		)); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Email.Enabled() {
+		email, err := publisher.NewEmail(
+			cfg.Email,
+			db.WithNamespace("email"),
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := addPublisher("email", email); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Teams.Enabled() {
+		urlHash := sha256.Sum256([]byte(cfg.Teams.URL))
+
+		if err := addPublisher("teams", publisher.NewTeams(
+			cfg.Teams,
+			db.WithNamespace("teams-"+hex.EncodeToString(urlHash[:])),
+		)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, w := range cfg.EnabledWebhooks() {
+		webhook, err := publisher.NewWebhook(w, db.WithNamespace(w.DBNamespace()))
+		if err != nil {
+			return nil, err
+		}
+		if err := addPublisher(w.PublisherName(), webhook); err != nil {
+			return nil, err
+		}
 	}
 
 	if len(publishers) == 0 {
 		return nil, ErrPublisherUndefined
 	}
 
-	ignoreRules := make(map[string]struct{}, len(cfg.Processor.IgnoreRules))
-	for _, r := range cfg.Processor.IgnoreRules {
-		ignoreRules[r] = struct{}{}
+	var slackInteractions *publisher.SlackInteractions
+	if cfg.Slack.Enabled() && cfg.Slack.InteractiveEnabled() {
+		slackInteractions = publisher.NewSlackInteractions(cfg.Slack, db)
+	}
+
+	ignoreRules, err := cfg.Processor.IgnoreRuleMatchers()
+	if err != nil {
+		return nil, err
+	}
+
+	matchLabels, err := cfg.Processor.MatchLabelsMatchers()
+	if err != nil {
+		return nil, err
+	}
+
+	receivers, err := newReceivers(cfg.Processor.Receivers, publisherNames)
+	if err != nil {
+		return nil, err
+	}
+
+	var rootRoute *route
+	if cfg.Processor.Route != nil {
+		rootRoute, err = newRoute(cfg.Processor.Route, "route", nil, receivers)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &Processor{
 		ignoreRules: ignoreRules,
-		matchLabels: cfg.Processor.MatchLabels,
+		matchLabels: matchLabels,
 		log:         zap.L(),
 		publishers:  publishers,
+		receivers:   receivers,
+		route:       rootRoute,
+
+		slackInteractions: slackInteractions,
 	}, nil
 }
 
+// SlackInteractions returns the handler of slack's interactive buttons (or
+// nil, if they are not enabled).
+func (p *Processor) SlackInteractions() http.Handler {
+	if p.slackInteractions == nil {
+		return nil
+	}
+	return p.slackInteractions
+}
+
+// ignoredBy returns the first ignore-rule that matches the alert (if any).
+func (p *Processor) ignoredBy(alert *types.AlertmanagerAlert) *matcher.Matcher {
+	for _, rule := range p.ignoreRules {
+		if rule.Matches(alert.Labels[rule.Name]) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// routeAlert returns the publishers that the alert must be published with.
+func (p *Processor) routeAlert(alert *types.AlertmanagerAlert) []publisher.Publisher {
+	if p.route == nil {
+		return p.publishers
+	}
+
+	res := make([]publisher.Publisher, 0, len(p.publishers))
+	seen := make(map[int]struct{}, len(p.publishers))
+	for _, receiver := range p.route.match(alert.Labels) {
+		for _, idx := range p.receivers[receiver] {
+			if _, published := seen[idx]; published {
+				continue
+			}
+			seen[idx] = struct{}{}
+			res = append(res, p.publishers[idx])
+		}
+	}
+	return res
+}
+
+// processMessage dispatches the alerts of the message to the publishers.  The
+// raw is the message as it was received (or nil, if there's no such thing).
 func (p *Processor) processMessage(
 	ctx context.Context,
 	source string,
 	message *types.AlertmanagerMessage,
+	raw []byte,
 ) error {
+	// keep the original message around (e.g. for the webhooks that forward it
+	// as-is), as the processing below modifies the alerts
+	if raw != nil {
+		ctx = publisher.ContextWithRawMessage(ctx, raw)
+	}
+
 	errs := []error{}
 	for _, alert := range message.Alerts {
+		// the alerts come from untrusted json, and might lack either
+		if alert.Labels == nil {
+			alert.Labels = make(map[string]string, len(message.CommonLabels))
+		}
+		if alert.Annotations == nil {
+			alert.Annotations = make(map[string]string, len(message.CommonAnnotations))
+		}
+
 		// merge common labels into alert's labels
 		for k, v := range message.CommonLabels {
 			if _, present := alert.Labels[k]; !present {
@@ -100,6 +283,12 @@ func (p *Processor) processMessage(
 			}
 		}
 
+		// remember where the alert came from (e.g. to silence it)
+		if alert.ExternalURL == "" {
+			alert.ExternalURL = message.ExternalURL
+		}
+		alert.GroupLabels = message.GroupLabels
+
 		// create alert-specific logger
 		l := logutils.LoggerFromContext(ctx).With(
 			zap.String("alert_fingerprint", alert.MessageDedupKey()),
@@ -108,25 +297,21 @@ func (p *Processor) processMessage(
 		ctx = logutils.ContextWithLogger(ctx, l)
 
 		// skip ignored alerts
-		if _, ignore := p.ignoreRules[alert.Labels["alertname"]]; ignore {
+		if rule := p.ignoredBy(&alert); rule != nil {
 			l.Info("Skipped the alert according to ignore-rules configuration",
 				zap.Any("alert", alert),
+				zap.String("rule", rule.String()),
 			)
-			return nil
+			continue
 		}
 
 		// skip un-matched alerts
-		if len(p.matchLabels) > 0 {
-			for label, value := range p.matchLabels {
-				if alert.Labels[label] != value {
-					l.Info("Skipped the alert due to label mismatch",
-						zap.Any("alert", alert),
-						zap.String("label", label),
-						zap.String("expected", value),
-					)
-					return nil
-				}
-			}
+		if mismatch := p.matchLabels.Mismatch(alert.Labels); mismatch != nil {
+			l.Info("Skipped the alert due to label mismatch",
+				zap.Any("alert", alert),
+				zap.String("matcher", mismatch.String()),
+			)
+			continue
 		}
 
 		// normalise alert's timestamp
@@ -137,9 +322,23 @@ func (p *Processor) processMessage(
 		if err == nil {
 			alert.StartsAt = _timestamp.Format(timeFormatGrafana)
 		}
+		_timestamp, err = time.Parse(timeFormatGrafana, alert.EndsAt)
+		if err != nil {
+			_timestamp, err = time.Parse(timeFormatPrometheus, alert.EndsAt)
+		}
+		if err == nil {
+			alert.EndsAt = _timestamp.Format(timeFormatGrafana)
+		}
 
 		// publish
-		for _, pub := range p.publishers {
+		publishers := p.routeAlert(&alert)
+		if len(publishers) == 0 {
+			l.Info("Skipped the alert as it was not routed to any publisher",
+				zap.Any("alert", alert),
+			)
+			continue
+		}
+		for _, pub := range publishers {
 			if err := pub.Publish(ctx, source, &alert); err != nil {
 				errs = append(errs, err)
 			}
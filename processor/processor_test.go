@@ -0,0 +1,154 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/db"
+	"github.com/flashbots/amp-alerts-sink/publisher"
+	"github.com/flashbots/amp-alerts-sink/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type recordingPublisher struct {
+	alerts  []string
+	failing string
+}
+
+func (r *recordingPublisher) Publish(
+	_ context.Context,
+	_ string,
+	alert *types.AlertmanagerAlert,
+) error {
+	r.alerts = append(r.alerts, alert.Labels["alertname"])
+	if alert.Labels["alertname"] == r.failing {
+		return assert.AnError
+	}
+	return nil
+}
+
+func newTestProcessor(t *testing.T, cfg *config.Processor) (*Processor, *recordingPublisher) {
+	ignoreRules, err := cfg.IgnoreRuleMatchers()
+	assert.NoError(t, err)
+
+	matchLabels, err := cfg.MatchLabelsMatchers()
+	assert.NoError(t, err)
+
+	pub := &recordingPublisher{}
+
+	return &Processor{
+		ignoreRules: ignoreRules,
+		matchLabels: matchLabels,
+		log:         zap.NewNop(),
+		publishers:  []publisher.Publisher{pub},
+	}, pub
+}
+
+func newTestMessage(alerts ...map[string]string) *types.AlertmanagerMessage {
+	m := &types.AlertmanagerMessage{}
+	for _, labels := range alerts {
+		m.Alerts = append(m.Alerts, types.AlertmanagerAlert{
+			Status:      "firing",
+			Labels:      labels,
+			Annotations: map[string]string{},
+		})
+	}
+	return m
+}
+
+func TestProcessorIgnoreRules(t *testing.T) {
+	p, pub := newTestProcessor(t, &config.Processor{
+		IgnoreRules: []string{
+			"DatasourceError",
+			"namespace=~test-.*",
+		},
+	})
+
+	err := p.processMessage(context.Background(), "testSource", newTestMessage(
+		map[string]string{"alertname": "DatasourceError"},
+		map[string]string{"alertname": "TestNamespace", "namespace": "test-1"},
+		map[string]string{"alertname": "Published", "namespace": "prod"},
+	), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Published"}, pub.alerts)
+}
+
+func TestProcessorMatchLabels(t *testing.T) {
+	p, pub := newTestProcessor(t, &config.Processor{
+		MatchLabels: []string{
+			"team=~infra|platform",
+			"env!=dev",
+		},
+	})
+
+	err := p.processMessage(context.Background(), "testSource", newTestMessage(
+		map[string]string{"alertname": "WrongTeam", "team": "payments"},
+		map[string]string{"alertname": "Infra", "team": "infra"},
+		map[string]string{"alertname": "Dev", "team": "platform", "env": "dev"},
+		map[string]string{"alertname": "Platform", "team": "platform", "env": "prod"},
+	), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Infra", "Platform"}, pub.alerts)
+}
+
+func TestProcessorInvalidMatchers(t *testing.T) {
+	cfg := &config.Processor{
+		IgnoreRules: []string{"alertname=~("},
+		MatchLabels: []string{"foo"},
+	}
+	err := cfg.Validate()
+	assert.ErrorIs(t, err, config.ErrProcessorInvalidIgnoreRule)
+	assert.ErrorIs(t, err, config.ErrProcessorInvalidLabelMatch)
+}
+
+func TestProcessorAlertWithoutLabels(t *testing.T) {
+	p, pub := newTestProcessor(t, &config.Processor{})
+
+	raw := []byte(`{"receiver": "sink", "status": "firing", ` +
+		`"commonLabels": {"alertname": "Common"}, "commonAnnotations": {"summary": "text"}, ` +
+		`"alerts": [{"status": "firing"}]}`)
+	message := &types.AlertmanagerWebhook{}
+	assert.NoError(t, json.Unmarshal(raw, message))
+
+	assert.NotPanics(t, func() {
+		err := p.ProcessAlertmanagerWebhook(context.Background(), "testSource", message, raw)
+		assert.NoError(t, err)
+	})
+	assert.Equal(t, []string{"Common"}, pub.alerts)
+}
+
+func TestProcessorKeepsRawMessage(t *testing.T) {
+	bodies := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		bodies = append(bodies, string(body))
+	}))
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.LocalDB.Path = config.LocalDBInMemory
+	db, err := db.New(cfg)
+	assert.NoError(t, err)
+	wh, err := publisher.NewWebhook(&config.Webhook{
+		URL:      srv.URL,
+		SendBody: true,
+		Format:   config.WebhookFormatRaw,
+	}, db)
+	assert.NoError(t, err)
+
+	p, _ := newTestProcessor(t, &config.Processor{})
+	p.publishers = []publisher.Publisher{wh}
+
+	// the fields that are not modelled by the types must be forwarded as well
+	message := `{"receiver": "sns", "status": "firing", "x-custom": {"k": "v"}, ` +
+		`"alerts": [{"status": "firing", "labels": {"alertname": "Raw"}}]}`
+	assert.NoError(t, p.ProcessSnsMessage(context.Background(), "testSource", message))
+	assert.Equal(t, []string{message}, bodies)
+}
@@ -0,0 +1,116 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/matcher"
+)
+
+var (
+	ErrReceiverDuplicate        = config.ErrReceiverDuplicate
+	ErrReceiverUnknownPublisher = errors.New("receiver refers to unknown publisher")
+	ErrRouteEmpty               = config.ErrRouteEmpty
+	ErrRouteReceiverUndefined   = config.ErrRouteReceiverUndefined
+	ErrRouteUnknownReceiver     = config.ErrRouteUnknownReceiver
+)
+
+// route is the node of alertmanager-style routing tree.
+type route struct {
+	receiver string
+	matchers matcher.Matchers
+	cont     bool
+	routes   []*route
+}
+
+// newRoute builds the routing tree (path is where the route is in the config,
+// e.g. `route.routes[1]`, so that the errors tell which route is wrong).
+func newRoute(cfg *config.Route, path string, parent *route, receivers map[string][]int) (*route, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("%s: %w", path, ErrRouteEmpty)
+	}
+
+	matchers, err := cfg.MatchersList()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	r := &route{
+		receiver: cfg.Receiver,
+		matchers: matchers,
+		cont:     cfg.Continue,
+	}
+
+	// inherit the receiver from the parent
+	if r.receiver == "" {
+		if parent == nil {
+			return nil, fmt.Errorf("%s: %w", path, ErrRouteReceiverUndefined)
+		}
+		r.receiver = parent.receiver
+	}
+	if _, known := receivers[r.receiver]; !known {
+		return nil, fmt.Errorf("%s: %w: %s",
+			path, ErrRouteUnknownReceiver, r.receiver,
+		)
+	}
+
+	r.routes = make([]*route, 0, len(cfg.Routes))
+	for idx, child := range cfg.Routes {
+		childRoute, err := newRoute(child, fmt.Sprintf("%s.routes[%d]", path, idx), r, receivers)
+		if err != nil {
+			return nil, err
+		}
+		r.routes = append(r.routes, childRoute)
+	}
+
+	return r, nil
+}
+
+// match returns the names of the receivers the alert with the labels must be
+// dispatched to (in the same way as alertmanager does).
+func (r *route) match(labels map[string]string) []string {
+	if !r.matchers.Matches(labels) {
+		return nil
+	}
+
+	res := []string{}
+	for _, child := range r.routes {
+		matches := child.match(labels)
+		res = append(res, matches...)
+		if len(matches) > 0 && !child.cont {
+			break
+		}
+	}
+
+	// none of the children matched, so it's this route that does
+	if len(res) == 0 {
+		res = append(res, r.receiver)
+	}
+
+	return res
+}
+
+// newReceivers maps receivers onto the indices of processor's publishers.
+func newReceivers(cfg []*config.Receiver, publishers map[string]int) (map[string][]int, error) {
+	res := make(map[string][]int, len(cfg))
+	for _, receiver := range cfg {
+		if _, duplicate := res[receiver.Name]; duplicate {
+			return nil, fmt.Errorf("%w: %s",
+				ErrReceiverDuplicate, receiver.Name,
+			)
+		}
+		indices := make([]int, 0, len(receiver.Publishers))
+		for _, name := range receiver.Publishers {
+			idx, known := publishers[name]
+			if !known {
+				return nil, fmt.Errorf("%w: %s: %s",
+					ErrReceiverUnknownPublisher, receiver.Name, name,
+				)
+			}
+			indices = append(indices, idx)
+		}
+		res[receiver.Name] = indices
+	}
+	return res, nil
+}
@@ -0,0 +1,107 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteMatch(t *testing.T) {
+	receivers, err := newReceivers([]*config.Receiver{
+		{Name: "default", Publishers: []string{"slack-default"}},
+		{Name: "infra", Publishers: []string{"slack-infra"}},
+		{Name: "oncall", Publishers: []string{"slack-infra", "pagerduty"}},
+	}, map[string]int{
+		"slack-default": 0,
+		"slack-infra":   1,
+		"pagerduty":     2,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, receivers["oncall"])
+
+	r, err := newRoute(&config.Route{
+		Receiver: "default",
+		Routes: []*config.Route{
+			{
+				Receiver: "oncall",
+				Matchers: []string{"severity=critical"},
+				Continue: true,
+			},
+			{
+				Receiver: "infra",
+				Matchers: []string{"team=~infra|platform"},
+				Routes: []*config.Route{
+					{
+						Matchers: []string{"env!=prod"},
+						Receiver: "default",
+					},
+				},
+			},
+			{
+				Receiver: "oncall",
+				Matchers: []string{"team=infra"}, // never reached
+			},
+		},
+	}, "route", nil, receivers)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		labels   map[string]string
+		expected []string
+	}{
+		{
+			labels:   map[string]string{"team": "payments"},
+			expected: []string{"default"},
+		},
+		{
+			labels:   map[string]string{"team": "infra", "env": "prod"},
+			expected: []string{"infra"},
+		},
+		{
+			labels:   map[string]string{"team": "platform", "env": "dev"},
+			expected: []string{"default"},
+		},
+		{
+			labels:   map[string]string{"team": "infra", "env": "prod", "severity": "critical"},
+			expected: []string{"oncall", "infra"},
+		},
+		{
+			labels:   map[string]string{"severity": "critical"},
+			expected: []string{"oncall"},
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, r.match(tc.labels), "labels: %v", tc.labels)
+	}
+}
+
+func TestRouteErrors(t *testing.T) {
+	receivers := map[string][]int{"default": {0}}
+
+	_, err := newRoute(&config.Route{}, "route", nil, receivers)
+	assert.ErrorIs(t, err, ErrRouteReceiverUndefined)
+
+	_, err = newRoute(&config.Route{
+		Receiver: "default",
+		Routes:   []*config.Route{{Receiver: "unknown"}},
+	}, "route", nil, receivers)
+	assert.ErrorIs(t, err, ErrRouteUnknownReceiver)
+	assert.ErrorContains(t, err, "route.routes[0]: ")
+
+	_, err = newRoute(&config.Route{
+		Receiver: "default",
+		Routes: []*config.Route{
+			{},
+			{Matchers: []string{`severity=~"(critical"`}},
+		},
+	}, "route", nil, receivers)
+	assert.ErrorIs(t, err, config.ErrRouteInvalidMatcher)
+	assert.ErrorContains(t, err, "route.routes[1]: ")
+
+	_, err = newReceivers([]*config.Receiver{
+		{Name: "default", Publishers: []string{"unknown"}},
+	}, map[string]int{})
+	assert.ErrorIs(t, err, ErrReceiverUnknownPublisher)
+}
@@ -19,51 +19,80 @@ func (p *Processor) ProcessSnsEvent(ctx context.Context, event events.SNSEvent)
 
 	errs := []error{}
 	for _, r := range event.Records {
-		raw := []byte(r.SNS.Message)
-		m := &types.AlertmanagerMessage{}
-
-		err := json.Unmarshal(raw, m)
-		if err != nil && err.Error() == "invalid character '\\'' in string escape code" {
-			raw = sanitiseJsEscapedApostrophes(raw)
-			err = json.Unmarshal(raw, m)
-		}
+		m, raw, err := p.parseMessage(r.SNS.Message)
 		if err != nil {
-			l.Error("Error un-marshalling message",
-				zap.String("message", strings.ReplaceAll(r.SNS.Message, "\n", " ")),
-				zap.Error(err),
-			)
 			errs = append(errs, err)
 			continue
 		}
 
-		if err := p.processMessage(ctx, r.SNS.TopicArn, m); err != nil {
+		if err := p.processMessage(ctx, r.SNS.TopicArn, m, raw); err != nil {
 			errs = append(errs, err)
 		}
 	}
 
 	if len(errs) > 0 {
-		alert := &types.AlertmanagerMessage{
-			Alerts: []types.AlertmanagerAlert{{
-				Status:   "firing",
-				StartsAt: time.Now().UTC().Format(timeFormatPrometheus),
-				Labels: map[string]string{
-					"alertname": "AMPAlertsSinkParseError",
-					"severity":  "critical",
-				},
-				Annotations: map[string]string{
-					"summary": "Failed to parse SNS messages",
-					"description": "amp-alerts-sink failed to process some alerts. " +
-						"Check Lambda logs for more details.",
-				},
-			}},
-		}
-		if err := p.processMessage(ctx, "amp-alerts-sink", alert); err != nil {
-			l.Error("Failed to send parse error alert", zap.Error(err))
-		}
+		p.publishParseError(ctx)
 	}
 	return errors.Join(errs...)
 }
 
+// ProcessSnsMessage processes the message of a single SNS notification (e.g.
+// the one that was delivered via HTTP subscription).
+func (p *Processor) ProcessSnsMessage(ctx context.Context, topicArn, message string) error {
+	m, raw, err := p.parseMessage(message)
+	if err != nil {
+		p.publishParseError(ctx)
+		return err
+	}
+
+	return p.processMessage(ctx, topicArn, m, raw)
+}
+
+// parseMessage parses alertmanager message, and returns it along with its raw
+// bytes (that are the same as received, unless they had to be sanitised).
+func (p *Processor) parseMessage(message string) (*types.AlertmanagerMessage, []byte, error) {
+	raw := []byte(message)
+	m := &types.AlertmanagerMessage{}
+
+	err := json.Unmarshal(raw, m)
+	if err != nil && err.Error() == "invalid character '\\'' in string escape code" {
+		raw = sanitiseJsEscapedApostrophes(raw)
+		err = json.Unmarshal(raw, m)
+	}
+	if err != nil {
+		p.log.Error("Error un-marshalling message",
+			zap.String("message", strings.ReplaceAll(message, "\n", " ")),
+			zap.Error(err),
+		)
+		return nil, nil, err
+	}
+
+	return m, raw, nil
+}
+
+// publishParseError publishes an alert notifying that some of the messages
+// could not be processed.
+func (p *Processor) publishParseError(ctx context.Context) {
+	alert := &types.AlertmanagerMessage{
+		Alerts: []types.AlertmanagerAlert{{
+			Status:   "firing",
+			StartsAt: time.Now().UTC().Format(timeFormatPrometheus),
+			Labels: map[string]string{
+				"alertname": "AMPAlertsSinkParseError",
+				"severity":  "critical",
+			},
+			Annotations: map[string]string{
+				"summary": "Failed to parse SNS messages",
+				"description": "amp-alerts-sink failed to process some alerts. " +
+					"Check amp-alerts-sink logs for more details.",
+			},
+		}},
+	}
+	if err := p.processMessage(ctx, "amp-alerts-sink", alert, nil); err != nil {
+		p.log.Error("Failed to send parse error alert", zap.Error(err))
+	}
+}
+
 // sanitiseJsEscapedApostrophes replaces escaped apostrophes (\') for just
 // apostrophes (') in the byte slice so that we could try to parse strings
 // that were processes by `js` function of AMP's alertmanager template engine
@@ -0,0 +1,64 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.uber.org/zap"
+)
+
+// snsEnvelope is the shape of SNS notification when it's delivered into SQS
+// queue without raw message delivery.
+type snsEnvelope struct {
+	Type     string `json:"Type"`
+	TopicArn string `json:"TopicArn"`
+	Message  string `json:"Message"`
+}
+
+// ProcessSqsEvent processes the batch of SQS messages (each of which is either
+// alertmanager message, or SNS notification wrapping one) and reports back
+// only the messages that failed, so that only they are re-delivered.
+func (p *Processor) ProcessSqsEvent(ctx context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
+	l := p.log
+	defer l.Sync() //nolint:errcheck
+
+	res := events.SQSEventResponse{
+		BatchItemFailures: []events.SQSBatchItemFailure{},
+	}
+
+	// failures to publish are re-delivered by sqs, so only the messages that
+	// could not be parsed are worth raising the alarm about
+	hasParseErrors := false
+
+	for _, r := range event.Records {
+		source, message := r.EventSourceARN, r.Body
+
+		envelope := &snsEnvelope{}
+		if err := json.Unmarshal([]byte(r.Body), envelope); err == nil &&
+			envelope.Type == "Notification" && envelope.Message != "" {
+			source, message = envelope.TopicArn, envelope.Message
+		}
+
+		m, raw, err := p.parseMessage(message)
+		if err != nil {
+			hasParseErrors = true
+		} else {
+			err = p.processMessage(ctx, source, m, raw)
+		}
+		if err != nil {
+			l.Warn("Failed to process sqs message",
+				zap.Error(err),
+				zap.String("sqs_message_id", r.MessageId),
+			)
+			res.BatchItemFailures = append(res.BatchItemFailures, events.SQSBatchItemFailure{
+				ItemIdentifier: r.MessageId,
+			})
+		}
+	}
+
+	if hasParseErrors {
+		p.publishParseError(ctx)
+	}
+	return res, nil
+}
@@ -0,0 +1,104 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessSqsEventPartialFailure(t *testing.T) {
+	p, pub := newTestProcessor(t, &config.Processor{})
+	pub.failing = "Failing"
+
+	snsWrapped, err := json.Marshal(map[string]string{
+		"Type":     "Notification",
+		"TopicArn": "arn:aws:sns:us-east-1:123456789012:alerts",
+		"Message":  `{"alerts": [{"status": "firing", "labels": {"alertname": "Wrapped"}}]}`,
+	})
+	assert.NoError(t, err)
+
+	res, err := p.ProcessSqsEvent(context.Background(), events.SQSEvent{
+		Records: []events.SQSMessage{
+			{
+				MessageId: "raw",
+				Body:      `{"alerts": [{"status": "firing", "labels": {"alertname": "Raw"}}]}`,
+			},
+			{
+				MessageId: "wrapped",
+				Body:      string(snsWrapped),
+			},
+			{
+				MessageId: "failing",
+				Body:      `{"alerts": [{"status": "firing", "labels": {"alertname": "Failing"}}]}`,
+			},
+			{
+				MessageId: "garbage",
+				Body:      `{`,
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []events.SQSBatchItemFailure{
+		{ItemIdentifier: "failing"},
+		{ItemIdentifier: "garbage"},
+	}, res.BatchItemFailures)
+	assert.Equal(t, []string{"Raw", "Wrapped", "Failing", "AMPAlertsSinkParseError"}, pub.alerts)
+}
+
+func TestProcessSqsEventPublishFailureIsNotParseError(t *testing.T) {
+	p, pub := newTestProcessor(t, &config.Processor{})
+	pub.failing = "Failing"
+
+	res, err := p.ProcessSqsEvent(context.Background(), events.SQSEvent{
+		Records: []events.SQSMessage{
+			{
+				MessageId: "failing",
+				Body:      `{"alerts": [{"status": "firing", "labels": {"alertname": "Failing"}}]}`,
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []events.SQSBatchItemFailure{
+		{ItemIdentifier: "failing"},
+	}, res.BatchItemFailures)
+
+	// the message is re-delivered by sqs, no need for parse-error alert
+	assert.Equal(t, []string{"Failing"}, pub.alerts)
+}
+
+func TestProcessLambdaEventDispatch(t *testing.T) {
+	p, pub := newTestProcessor(t, &config.Processor{})
+
+	res, err := p.ProcessLambdaEvent(context.Background(), json.RawMessage(`{
+		"Records": [{
+			"eventSource": "aws:sqs",
+			"messageId": "id",
+			"body": "{\"alerts\": [{\"status\": \"firing\", \"labels\": {\"alertname\": \"FromSqs\"}}]}"
+		}]
+	}`))
+	assert.NoError(t, err)
+	assert.Empty(t, res.(events.SQSEventResponse).BatchItemFailures)
+
+	res, err = p.ProcessLambdaEvent(context.Background(), json.RawMessage(`{
+		"Records": [{
+			"EventSource": "aws:sns",
+			"Sns": {
+				"TopicArn": "arn:aws:sns:us-east-1:123456789012:alerts",
+				"Message": "{\"alerts\": [{\"status\": \"firing\", \"labels\": {\"alertname\": \"FromSns\"}}]}"
+			}
+		}]
+	}`))
+	assert.NoError(t, err)
+	assert.Nil(t, res)
+
+	assert.Equal(t, []string{"FromSqs", "FromSns"}, pub.alerts)
+
+	_, err = p.ProcessLambdaEvent(context.Background(), json.RawMessage(`{
+		"Records": [{"eventSource": "aws:s3"}]
+	}`))
+	assert.ErrorIs(t, err, ErrLambdaUnsupportedEvent)
+}
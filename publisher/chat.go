@@ -0,0 +1,107 @@
+package publisher
+
+import (
+	"context"
+
+	"github.com/flashbots/amp-alerts-sink/db"
+	"github.com/flashbots/amp-alerts-sink/logutils"
+	"github.com/flashbots/amp-alerts-sink/template"
+	"github.com/flashbots/amp-alerts-sink/types"
+	"go.uber.org/zap"
+)
+
+// chat publishes alerts to the chat platform that supports replies (or
+// threads).  Just like with slack, the follow-ups of the alert (e.g. its
+// resolution) are posted as replies to the first message about it.
+type chat struct {
+	name      string
+	channelID string
+
+	poster chatPoster
+	db     db.DB
+}
+
+type chatPoster interface {
+	// post publishes the message (as a reply to the message with threadID,
+	// if it's not empty) and returns the ID of published message.
+	post(ctx context.Context, data *template.Data, threadID string) (string, error)
+}
+
+const (
+	// chatTitle is the title of the alert on the chat platforms.
+	chatTitle = "{{ .Status | toUpper }}: {{ .Labels.alertname }}"
+
+	// chatMarkdownDetails is the part of the markdown text that describes the
+	// alert (that is, everything but its title and links).
+	chatMarkdownDetails = "{{ with .Labels.severity }}Severity: `{{ . }}`\n{{ end }}" +
+		"{{ with .Annotations.summary }}Summary: `{{ . }}`\n{{ end }}" +
+		"{{ with .Annotations.description }}\n{{ . }}\n\n{{ end }}" +
+		"{{ with .Annotations.message }}\n{{ . }}\n\n{{ end }}" +
+		"{{ with .StartsAt }}Started at: `{{ . }}`\n{{ end }}"
+)
+
+// chatMarkdownText is the default text of the messages on the platforms that
+// support markdown.
+var chatMarkdownText = template.Must(template.New("chat-markdown-text",
+	`{{ if eq .Status "firing" }}🔥{{ else }}✅{{ end }} `+
+		"**"+chatTitle+"**\n"+
+		chatMarkdownDetails+
+		"{{ with .Links }}\n"+
+		"{{ range $i, $link := . }}{{ if $i }} | {{ end }}[{{ $link.Text }}]({{ $link.Href }}){{ end }}\n"+
+		"{{ end }}",
+))
+
+func (c *chat) Publish(
+	ctx context.Context,
+	source string,
+	alert *types.AlertmanagerAlert,
+) error {
+	l := logutils.LoggerFromContext(ctx)
+
+	dbKeyThreadID := source + "/" + c.channelID + "/" + alert.IncidentDedupKey()
+	dbKeyMessageID := source + "/" + c.channelID + "/" + alert.MessageDedupKey()
+
+	// check if this is a follow-up message
+	threadID, err := c.db.Get(ctx, dbKeyThreadID)
+	if err != nil {
+		l.Error("Failed to get thread id, publishing to "+c.name+" without it", zap.Error(err))
+	}
+
+	l = l.With(
+		zap.String("channel_id", c.channelID),
+		zap.String("thread_id", threadID),
+	)
+	ctx = logutils.ContextWithLogger(ctx, l)
+
+	// the id of the message is not what marks it as sent, as some platforms
+	// do not return it
+	return publishOnce(ctx, c.db, dbKeyMessageID, timeoutThreadExpiry, c.name, func() error {
+		messageID, err := c.poster.post(ctx, template.NewData(source, alert), threadID)
+		if err != nil {
+			return err
+		}
+		l.Info("Published alert to "+c.name,
+			zap.Any("alert", alert),
+		)
+
+		if threadID == "" && messageID != "" {
+			// follow-ups will be replies to this message
+			_ = c.db.Set(ctx, dbKeyThreadID, timeoutThreadExpiry, messageID)
+		}
+
+		return nil
+	})
+}
+
+// renderChatText renders the message text, falling back to the plain alert
+// name if the template fails.
+func renderChatText(ctx context.Context, tmpl *template.Template, data *template.Data) string {
+	text, err := tmpl.Execute(data)
+	if err != nil {
+		logutils.LoggerFromContext(ctx).Error("Failed to render chat message",
+			zap.Error(err),
+		)
+		return data.Status + ": " + data.Labels["alertname"]
+	}
+	return text
+}
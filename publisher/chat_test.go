@@ -0,0 +1,40 @@
+package publisher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/flashbots/amp-alerts-sink/template"
+	"github.com/stretchr/testify/assert"
+)
+
+// chatPosterFunc adapts the function to chatPoster.
+type chatPosterFunc func(ctx context.Context, data *template.Data, threadID string) (string, error)
+
+func (f chatPosterFunc) post(ctx context.Context, data *template.Data, threadID string) (string, error) {
+	return f(ctx, data, threadID)
+}
+
+func TestChatWithoutMessageIDs(t *testing.T) {
+	threadIDs := []string{}
+	p := &chat{
+		name:      "test",
+		channelID: "testChannelID",
+		poster: chatPosterFunc(func(_ context.Context, _ *template.Data, threadID string) (string, error) {
+			threadIDs = append(threadIDs, threadID)
+			return "", nil // e.g. discord webhook without ?wait=true
+		}),
+		db: newMemoryDB(t),
+	}
+
+	ctx := context.Background()
+	assert.NoError(t, p.Publish(ctx, "testSource", alertFiring))
+	assert.NoError(t, p.Publish(ctx, "testSource", alertFiring), "duplicate")
+
+	resolved := *alertFiring
+	resolved.Status = "resolved"
+	assert.NoError(t, p.Publish(ctx, "testSource", &resolved))
+
+	// the duplicate is detected, and there's no thread to reply to
+	assert.Equal(t, []string{"", ""}, threadIDs)
+}
@@ -0,0 +1,177 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/types"
+)
+
+// DeadLetter is the sink for the alerts that could not be published.
+type DeadLetter interface {
+	Send(ctx context.Context, publisher, source string, alert *types.AlertmanagerAlert, cause error) error
+}
+
+// deadLetterRecord is what gets written into the dead-letter sink.
+type deadLetterRecord struct {
+	Publisher string                   `json:"publisher"`
+	Source    string                   `json:"source"`
+	Error     string                   `json:"error"`
+	FailedAt  string                   `json:"failedAt"`
+	Alert     *types.AlertmanagerAlert `json:"alert"`
+}
+
+type deadLetterFile struct {
+	mx   sync.Mutex
+	path string
+}
+
+type deadLetterSqs struct {
+	cli      *sqs.SQS
+	queueURL string
+}
+
+type deadLetterWebhook struct {
+	client httpClient
+	url    string
+}
+
+const (
+	timeoutDeadLetter = 5 * time.Second
+)
+
+// NewDeadLetter returns the dead-letter sink according to the config (or nil,
+// if none is configured).
+func NewDeadLetter(cfg *config.DeadLetter) (DeadLetter, error) {
+	switch {
+	case cfg.File != "":
+		return &deadLetterFile{path: cfg.File}, nil
+
+	case cfg.SQSQueueURL != "":
+		awsCfg := aws.NewConfig()
+		if cfg.SQSEndpoint != "" {
+			awsCfg = awsCfg.WithEndpoint(cfg.SQSEndpoint)
+		}
+		s, err := session.NewSession(awsCfg)
+		if err != nil {
+			return nil, err
+		}
+		return &deadLetterSqs{
+			cli:      sqs.New(s),
+			queueURL: cfg.SQSQueueURL,
+		}, nil
+
+	case cfg.WebhookURL != "":
+		return &deadLetterWebhook{
+			client: http.DefaultClient,
+			url:    cfg.WebhookURL,
+		}, nil
+	}
+
+	return nil, nil
+}
+
+func newDeadLetterRecord(
+	publisher, source string,
+	alert *types.AlertmanagerAlert,
+	cause error,
+) ([]byte, error) {
+	return json.Marshal(&deadLetterRecord{
+		Publisher: publisher,
+		Source:    source,
+		Error:     cause.Error(),
+		FailedAt:  time.Now().UTC().Format(time.RFC3339),
+		Alert:     alert,
+	})
+}
+
+func (d *deadLetterFile) Send(
+	_ context.Context,
+	publisher, source string,
+	alert *types.AlertmanagerAlert,
+	cause error,
+) error {
+	record, err := newDeadLetterRecord(publisher, source, alert, cause)
+	if err != nil {
+		return err
+	}
+
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	f, err := os.OpenFile(d.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(record, '\n'))
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
+func (d *deadLetterSqs) Send(
+	ctx context.Context,
+	publisher, source string,
+	alert *types.AlertmanagerAlert,
+	cause error,
+) error {
+	record, err := newDeadLetterRecord(publisher, source, alert, cause)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeoutDeadLetter)
+	defer cancel()
+
+	_, err = d.cli.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(d.queueURL),
+		MessageBody: aws.String(string(record)),
+	})
+	return err
+}
+
+func (d *deadLetterWebhook) Send(
+	ctx context.Context,
+	publisher, source string,
+	alert *types.AlertmanagerAlert,
+	cause error,
+) error {
+	record, err := newDeadLetterRecord(publisher, source, alert, cause)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeoutDeadLetter)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(record))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("dead-letter webhook returned status %d: %s", resp.StatusCode, resp.Status)
+	}
+	return nil
+}
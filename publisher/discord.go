@@ -0,0 +1,149 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/db"
+	"github.com/flashbots/amp-alerts-sink/template"
+)
+
+const (
+	discordAPIURL = "https://discord.com/api/v10"
+
+	// discordErrThreadAlreadyCreated is discord's error code for the attempt
+	// to start a thread from the message that already has one
+	discordErrThreadAlreadyCreated = 160004
+
+	// discordMessageLimit and discordThreadNameLimit are the max lengths of
+	// message content and of thread name as per discord API docs
+	discordMessageLimit    = 2000
+	discordThreadNameLimit = 100
+
+	// discordThreadArchiveDuration is for how long (in minutes) the thread
+	// stays active without new messages (7 days)
+	discordThreadArchiveDuration = 10080
+)
+
+type discord struct {
+	apiURL    string
+	botToken  string
+	channelID string
+
+	client httpClient
+}
+
+type discordCreateMessage struct {
+	Content         string                 `json:"content"`
+	AllowedMentions discordAllowedMentions `json:"allowed_mentions"`
+}
+
+type discordAllowedMentions struct {
+	Parse []string `json:"parse"`
+}
+
+type discordStartThread struct {
+	Name                string `json:"name"`
+	AutoArchiveDuration int    `json:"auto_archive_duration"`
+}
+
+type discordMessage struct {
+	ID string `json:"id"`
+}
+
+type discordError struct {
+	Code int `json:"code"`
+}
+
+func NewDiscord(cfg *config.Discord, db db.DB) Publisher {
+	apiURL := cfg.APIURL
+	if apiURL == "" {
+		apiURL = discordAPIURL
+	}
+
+	return &chat{
+		name:      "discord",
+		channelID: cfg.ChannelID,
+
+		poster: &discord{
+			apiURL:    strings.TrimSuffix(apiURL, "/"),
+			botToken:  cfg.BotToken,
+			channelID: cfg.ChannelID,
+
+			client: http.DefaultClient,
+		},
+		db: db,
+	}
+}
+
+func (d *discord) post(
+	ctx context.Context,
+	data *template.Data,
+	threadID string,
+) (string, error) {
+	header := http.Header{}
+	header.Set("Authorization", "Bot "+d.botToken)
+
+	// the thread started from a message shares its id with that message
+	channelID := d.channelID
+	if threadID != "" {
+		if err := d.startThread(ctx, header, data, threadID); err != nil {
+			return "", err
+		}
+		channelID = threadID
+	}
+
+	msg := discordCreateMessage{
+		Content: truncate(renderChatText(ctx, chatMarkdownText, data), discordMessageLimit),
+		// the alerts should not ping anyone by accident
+		AllowedMentions: discordAllowedMentions{Parse: []string{}},
+	}
+
+	res := &discordMessage{}
+	if err := postJSON(ctx, d.client, "discord",
+		d.apiURL+"/channels/"+channelID+"/messages", header, msg, res,
+	); err != nil {
+		return "", err
+	}
+
+	return res.ID, nil
+}
+
+// startThread starts the thread from the message with threadID (unless there
+// is one already).
+func (d *discord) startThread(
+	ctx context.Context,
+	header http.Header,
+	data *template.Data,
+	threadID string,
+) error {
+	name := data.Labels["alertname"]
+	if name == "" {
+		name = "alert"
+	}
+
+	err := postJSON(ctx, d.client, "discord",
+		d.apiURL+"/channels/"+d.channelID+"/messages/"+threadID+"/threads",
+		header,
+		discordStartThread{
+			Name:                truncate(name, discordThreadNameLimit),
+			AutoArchiveDuration: discordThreadArchiveDuration,
+		},
+		nil,
+	)
+
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.statusCode == http.StatusBadRequest {
+		discordErr := &discordError{}
+		if json.Unmarshal([]byte(apiErr.body), discordErr) == nil &&
+			discordErr.Code == discordErrThreadAlreadyCreated {
+			return nil
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,66 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscordThreads(t *testing.T) {
+	paths := []string{}
+	threads := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bot theToken", r.Header.Get("Authorization"))
+		paths = append(paths, r.URL.Path)
+
+		if r.URL.Path == "/channels/1000/messages/1001/threads" {
+			threads++
+			if threads > 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, `{"code":%d,"message":"A thread has already been created for this message"}`,
+					discordErrThreadAlreadyCreated,
+				)
+				return
+			}
+			fmt.Fprint(w, `{"id":"1001"}`)
+			return
+		}
+
+		msg := discordCreateMessage{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+		assert.NotEmpty(t, msg.Content)
+		fmt.Fprintf(w, `{"id":"%d"}`, 1000+len(paths))
+	}))
+	defer srv.Close()
+
+	p := NewDiscord(&config.Discord{
+		APIURL:    srv.URL,
+		BotToken:  "theToken",
+		ChannelID: "1000",
+	}, newMemoryDB(t))
+
+	ctx := context.Background()
+	assert.NoError(t, p.Publish(ctx, "testSource", alertFiring))
+
+	updated := *alertFiring
+	updated.Annotations = map[string]string{"summary": "Still failing"}
+	assert.NoError(t, p.Publish(ctx, "testSource", &updated))
+
+	resolved := *alertFiring
+	resolved.Status = "resolved"
+	assert.NoError(t, p.Publish(ctx, "testSource", &resolved))
+
+	assert.Equal(t, []string{
+		"/channels/1000/messages",
+		"/channels/1000/messages/1001/threads",
+		"/channels/1001/messages",
+		"/channels/1000/messages/1001/threads",
+		"/channels/1001/messages",
+	}, paths)
+}
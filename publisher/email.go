@@ -0,0 +1,325 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/db"
+	"github.com/flashbots/amp-alerts-sink/logutils"
+	"github.com/flashbots/amp-alerts-sink/matcher"
+	"github.com/flashbots/amp-alerts-sink/template"
+	"github.com/flashbots/amp-alerts-sink/types"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultEmailPort    = 587
+	defaultEmailTimeout = 30 * time.Second
+)
+
+var (
+	ErrEmailStartTLSUnsupported = errors.New("smtp server does not support starttls")
+)
+
+type email struct {
+	addr string
+	host string
+	tls  string
+	auth smtp.Auth
+
+	from       *mail.Address
+	to         []string
+	recipients []emailRecipient
+
+	templates *emailTemplates
+	db        db.DB
+}
+
+type emailRecipient struct {
+	matchLabels matcher.Matchers
+	to          []string
+}
+
+func NewEmail(cfg *config.Email, db db.DB) (Publisher, error) {
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w",
+			config.ErrEmailFromNotConfigured, err,
+		)
+	}
+
+	recipients := make([]emailRecipient, 0, len(cfg.Recipients))
+	for _, r := range cfg.Recipients {
+		matchLabels, err := r.MatchLabelsMatchers()
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, emailRecipient{
+			matchLabels: matchLabels,
+			to:          r.To,
+		})
+	}
+
+	templates, err := newEmailTemplates(cfg.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = defaultEmailPort
+	}
+	tlsMode := cfg.TLS
+	if tlsMode == "" {
+		tlsMode = config.EmailTLSStartTLS
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	return &email{
+		addr: net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+		host: cfg.Host,
+		tls:  tlsMode,
+		auth: auth,
+
+		from:       from,
+		to:         cfg.To,
+		recipients: recipients,
+
+		templates: templates,
+		db:        db,
+	}, nil
+}
+
+func (e *email) Publish(
+	ctx context.Context,
+	source string,
+	alert *types.AlertmanagerAlert,
+) error {
+	l := logutils.LoggerFromContext(ctx)
+
+	to := e.recipientsFor(alert)
+	if len(to) == 0 {
+		l.Debug("Skipped the alert as it matches none of email recipients")
+		return nil
+	}
+
+	dbKeyThread := source + "/" + alert.IncidentDedupKey()
+	dbKeyMessage := source + "/" + alert.MessageDedupKey()
+
+	l = l.With(zap.Strings("to", to))
+	ctx = logutils.ContextWithLogger(ctx, l)
+
+	return publishOnce(ctx, e.db, dbKeyMessage, timeoutEmailExpiry, "email", func() error {
+		// the message id of the first email about the incident is stored, so
+		// that the follow-ups can reference it
+		threadID, err := e.db.Get(ctx, dbKeyThread)
+		if err != nil {
+			l.Error("Failed to check for email thread, sending email without it", zap.Error(err))
+		}
+		startsThread := err == nil && threadID == "" && alert.Status == "firing"
+
+		data := template.NewData(source, alert)
+		messageID := e.newMessageID(data.MessageDedupKey())
+		msg, err := e.newMessage(ctx, data, to, messageID, threadID)
+		if err != nil {
+			return err
+		}
+
+		if err := e.sendMail(ctx, to, msg); err != nil {
+			return err
+		}
+		l.Info("Sent alert by email",
+			zap.Any("alert", alert),
+		)
+
+		if startsThread {
+			_ = e.db.Set(ctx, dbKeyThread, timeoutEmailExpiry, messageID)
+		}
+
+		return nil
+	})
+}
+
+// recipientsFor returns the addresses of the first recipient rule that
+// matches the alert (or the default addresses, if none does).
+func (e *email) recipientsFor(alert *types.AlertmanagerAlert) []string {
+	for _, r := range e.recipients {
+		if r.matchLabels.Matches(alert.Labels) {
+			return r.to
+		}
+	}
+	return e.to
+}
+
+// newMessageID returns the value for Message-ID header.  The ids must be
+// unique, so the key (that repeats once the incident fires again after its
+// thread expires) is suffixed with the timestamp.
+func (e *email) newMessageID(key string) string {
+	domain := "amp-alerts-sink"
+	if at := strings.LastIndex(e.from.Address, "@"); at >= 0 {
+		domain = e.from.Address[at+1:]
+	}
+	nonce := strconv.FormatInt(time.Now().UnixNano(), 36)
+	return "<" + key + "." + nonce + "@" + domain + ">"
+}
+
+func (e *email) newMessage(
+	ctx context.Context,
+	data *template.Data,
+	to []string,
+	messageID string,
+	threadID string,
+) ([]byte, error) {
+	subject := renderEmailTemplate(ctx,
+		e.templates.subject, emailDefaultTemplates.subject, data,
+	)
+	subject = strings.Join(strings.Fields(subject), " ")
+
+	buf := &bytes.Buffer{}
+	body := multipart.NewWriter(buf)
+
+	header := textproto.MIMEHeader{}
+	header.Set("From", e.from.String())
+	header.Set("To", strings.Join(to, ", "))
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header.Set("Date", time.Now().Format(time.RFC1123Z))
+	header.Set("Message-ID", messageID)
+	if threadID != "" {
+		header.Set("In-Reply-To", threadID)
+		header.Set("References", threadID)
+	}
+	header.Set("MIME-Version", "1.0")
+	header.Set("Content-Type", "multipart/alternative; boundary="+body.Boundary())
+
+	res := &bytes.Buffer{}
+	for _, k := range slices.Sorted(maps.Keys(header)) {
+		fmt.Fprintf(res, "%s: %s\r\n", k, header.Get(k))
+	}
+	res.WriteString("\r\n")
+
+	// plain-text goes first, as the clients pick the last alternative they
+	// are able to display
+	for _, part := range []struct {
+		contentType string
+		tmpl        *template.Template
+		fallback    *template.Template
+	}{
+		{"text/plain", e.templates.text, emailDefaultTemplates.text},
+		{"text/html", e.templates.html, emailDefaultTemplates.html},
+	} {
+		w, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create email part: %w", err)
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(renderEmailTemplate(ctx, part.tmpl, part.fallback, data))); err != nil {
+			return nil, fmt.Errorf("failed to write email part: %w", err)
+		}
+		if err := qp.Close(); err != nil {
+			return nil, fmt.Errorf("failed to write email part: %w", err)
+		}
+	}
+	if err := body.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write email body: %w", err)
+	}
+
+	res.Write(buf.Bytes())
+	return res.Bytes(), nil
+}
+
+func (e *email) sendMail(ctx context.Context, to []string, msg []byte) error {
+	dialer := &net.Dialer{}
+	tlsConfig := &tls.Config{ServerName: e.host}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if e.tls == config.EmailTLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", e.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", e.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultEmailTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to set smtp deadline: %w", err)
+	}
+
+	c, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start smtp session: %w", err)
+	}
+	defer c.Close()
+
+	if e.tls == config.EmailTLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return ErrEmailStartTLSUnsupported
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("failed to starttls: %w", err)
+		}
+	}
+
+	if e.auth != nil {
+		if err := c.Auth(e.auth); err != nil {
+			return fmt.Errorf("failed to authenticate with smtp server: %w", err)
+		}
+	}
+
+	if err := c.Mail(e.from.Address); err != nil {
+		return fmt.Errorf("smtp server rejected the sender: %w", err)
+	}
+	for _, rcpt := range to {
+		if addr, err := mail.ParseAddress(rcpt); err == nil {
+			rcpt = addr.Address
+		}
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp server rejected the recipient %s: %w", rcpt, err)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return c.Quit()
+}
@@ -0,0 +1,109 @@
+package publisher
+
+import (
+	"context"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/logutils"
+	"github.com/flashbots/amp-alerts-sink/template"
+	"go.uber.org/zap"
+)
+
+type emailTemplates struct {
+	subject *template.Template
+	html    *template.Template
+	text    *template.Template
+}
+
+var emailDefaultTemplates = &emailTemplates{
+	subject: template.Must(template.New("email-subject",
+		`[{{ .Status | toUpper }}] {{ .Labels.alertname }}`+
+			`{{ with .Annotations.summary }}: {{ . }}{{ end }}`,
+	)),
+
+	html: template.Must(template.New("email-html",
+		`<!DOCTYPE html>`+"\n"+
+			`<html><body style="font-family: sans-serif">`+"\n"+
+			`<h2 style="color: {{ if eq .Status "firing" }}#d00000{{ else }}#008000{{ end }}">`+
+			`{{ .Status | toUpper }}: {{ .Labels.alertname | html }}</h2>`+"\n"+
+			`<table>`+"\n"+
+			`{{ with .Labels.severity }}<tr><td><b>Severity</b></td><td><code>{{ . | html }}</code></td></tr>`+"\n"+`{{ end }}`+
+			`{{ with .Annotations.summary }}<tr><td><b>Summary</b></td><td>{{ . | html }}</td></tr>`+"\n"+`{{ end }}`+
+			`{{ with .StartsAt }}<tr><td><b>Started at</b></td><td><code>{{ . | html }}</code></td></tr>`+"\n"+`{{ end }}`+
+			`{{ if eq .Status "resolved" }}{{ with .EndsAt }}<tr><td><b>Resolved at</b></td><td><code>{{ . | html }}</code></td></tr>`+"\n"+`{{ end }}{{ end }}`+
+			`</table>`+"\n"+
+			`{{ with .Annotations.description }}<p>{{ . | html }}</p>`+"\n"+`{{ end }}`+
+			`{{ with .Annotations.message }}<p>{{ . | html }}</p>`+"\n"+`{{ end }}`+
+			`<h3>Labels</h3>`+"\n"+
+			`<ul>`+"\n"+
+			`{{ range $k, $v := .Labels }}<li><code>{{ $k | html }}={{ $v | html }}</code></li>`+"\n"+`{{ end }}`+
+			`</ul>`+"\n"+
+			`{{ with .Links }}<p>{{ range $i, $link := . }}{{ if $i }} | {{ end }}`+
+			`<a href="{{ $link.Href | html }}">{{ $link.Text }}</a>{{ end }}</p>`+"\n"+`{{ end }}`+
+			`</body></html>`+"\n",
+	)),
+
+	text: template.Must(template.New("email-text",
+		"{{ .Status | toUpper }}: {{ .Labels.alertname }}\n\n"+
+			"{{ with .Labels.severity }}Severity: {{ . }}\n{{ end }}"+
+			"{{ with .Annotations.summary }}Summary: {{ . }}\n{{ end }}"+
+			"{{ with .StartsAt }}Started at: {{ . }}\n{{ end }}"+
+			`{{ if eq .Status "resolved" }}{{ with .EndsAt }}Resolved at: {{ . }}`+"\n{{ end }}{{ end }}"+
+			"{{ with .Annotations.description }}\n{{ . }}\n{{ end }}"+
+			"{{ with .Annotations.message }}\n{{ . }}\n{{ end }}"+
+			"\nLabels:\n"+
+			"{{ range $k, $v := .Labels }}  {{ $k }}={{ $v }}\n{{ end }}"+
+			"{{ with .Links }}\n{{ range . }}{{ .Text }}: {{ .Href }}\n{{ end }}{{ end }}",
+	)),
+}
+
+// newEmailTemplates parses the templates from the config, falling back to the
+// default ones for those that are not configured.
+func newEmailTemplates(cfg *config.EmailTemplates) (*emailTemplates, error) {
+	res := *emailDefaultTemplates
+	if cfg == nil {
+		return &res, nil
+	}
+
+	for _, t := range []struct {
+		name string
+		text string
+		dst  **template.Template
+	}{
+		{"email-subject", cfg.Subject, &res.subject},
+		{"email-html", cfg.HTML, &res.html},
+		{"email-text", cfg.Text, &res.text},
+	} {
+		if t.text == "" {
+			continue
+		}
+		tmpl, err := template.New(t.name, t.text)
+		if err != nil {
+			return nil, err
+		}
+		*t.dst = tmpl
+	}
+
+	return &res, nil
+}
+
+// renderEmailTemplate executes the template, and falls back to the default
+// one if that fails (so that the alert is sent no matter what).
+func renderEmailTemplate(
+	ctx context.Context,
+	tmpl, fallback *template.Template,
+	data *template.Data,
+) string {
+	res, err := tmpl.Execute(data)
+	if err == nil {
+		return res
+	}
+
+	l := logutils.LoggerFromContext(ctx)
+	l.Error("Failed to render email template, falling back to the default one",
+		zap.Error(err),
+	)
+
+	res, _ = fallback.Execute(data)
+	return res
+}
@@ -0,0 +1,174 @@
+package publisher
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// newSMTPServer starts minimal smtp server (without tls and auth) that
+// collects the messages it receives.
+func newSMTPServer(t *testing.T) (string, int, func() []smtpMessage) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	mx := sync.Mutex{}
+	messages := []smtpMessage{}
+
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) {
+			fmt.Fprintf(conn, "%s\r\n", line)
+		}
+
+		msg := smtpMessage{}
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimSpace(line)
+			switch verb := strings.ToUpper(strings.SplitN(cmd, " ", 2)[0]); verb {
+			case "EHLO", "HELO":
+				reply("250 localhost")
+			case "MAIL":
+				msg.from = strings.Trim(strings.TrimPrefix(cmd[5:], "FROM:"), "<>")
+				reply("250 OK")
+			case "RCPT":
+				msg.to = append(msg.to, strings.Trim(strings.TrimPrefix(cmd[5:], "TO:"), "<>"))
+				reply("250 OK")
+			case "DATA":
+				reply("354 go ahead")
+				data := &strings.Builder{}
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(strings.TrimPrefix(line, "."))
+				}
+				msg.data = data.String()
+				mx.Lock()
+				messages = append(messages, msg)
+				mx.Unlock()
+				msg = smtpMessage{}
+				reply("250 OK")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, func() []smtpMessage {
+		mx.Lock()
+		defer mx.Unlock()
+		return append([]smtpMessage{}, messages...)
+	}
+}
+
+func TestEmailThreading(t *testing.T) {
+	host, port, messages := newSMTPServer(t)
+
+	p, err := NewEmail(&config.Email{
+		Host: host,
+		Port: port,
+		TLS:  config.EmailTLSNone,
+		From: "Alerts <alerts@example.com>",
+		To:   []string{"oncall@example.com"},
+		Recipients: []*config.EmailRecipient{
+			{MatchLabels: []string{"team=payments"}, To: []string{"payments@example.com", "cfo@example.com"}},
+		},
+	}, newMemoryDB(t))
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, p.Publish(ctx, "testSource", alertFiring))
+
+	resolved := *alertFiring
+	resolved.Status = "resolved"
+	assert.NoError(t, p.Publish(ctx, "testSource", &resolved))
+
+	// another HA replica delivers the same alert
+	assert.NoError(t, p.Publish(ctx, "testSource", &resolved))
+
+	assert.NoError(t, p.Publish(ctx, "testSource", &types.AlertmanagerAlert{
+		Status: "firing",
+		Labels: map[string]string{"alertname": "PaymentsDown", "team": "payments"},
+	}))
+
+	msgs := messages()
+	if !assert.Len(t, msgs, 3) {
+		return
+	}
+
+	assert.Equal(t, "alerts@example.com", msgs[0].from)
+	assert.Equal(t, []string{"oncall@example.com"}, msgs[0].to)
+	assert.Equal(t, []string{"payments@example.com", "cfo@example.com"}, msgs[2].to)
+
+	firing, err := mail.ReadMessage(strings.NewReader(msgs[0].data))
+	if !assert.NoError(t, err) {
+		return
+	}
+	threadID := firing.Header.Get("Message-Id")
+	assert.True(t, strings.HasPrefix(threadID, "<"+alertFiring.MessageDedupKey()+"."), threadID)
+	assert.True(t, strings.HasSuffix(threadID, "@example.com>"), threadID)
+	assert.Empty(t, firing.Header.Get("In-Reply-To"))
+	assert.Contains(t, firing.Header.Get("Content-Type"), "multipart/alternative")
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(firing.Header.Get("Subject"))
+	assert.NoError(t, err)
+	assert.Equal(t, "[FIRING] TestAlert: Notification test", subject)
+
+	body, err := io.ReadAll(firing.Body)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "Content-Type: text/plain; charset=utf-8")
+	assert.Contains(t, string(body), "Content-Type: text/html; charset=utf-8")
+
+	resolvedMsg, err := mail.ReadMessage(strings.NewReader(msgs[1].data))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, threadID, resolvedMsg.Header.Get("In-Reply-To"))
+	assert.Equal(t, threadID, resolvedMsg.Header.Get("References"))
+	assert.NotEqual(t, threadID, resolvedMsg.Header.Get("Message-Id"))
+}
@@ -0,0 +1,115 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/flashbots/amp-alerts-sink/logutils"
+	"go.uber.org/zap"
+)
+
+// apiError is returned when the API responds with unexpected status.
+type apiError struct {
+	name       string
+	statusCode int
+	body       string
+}
+
+// sendRequest sends the request to the API, and returns the body of the
+// response.  The statuses that are not successful (any non-2xx, unless
+// isSuccess is given) are returned as apiError, wrapped into retryAfterError
+// when the response tells when to retry.
+func sendRequest(
+	ctx context.Context,
+	client httpClient,
+	name string,
+	req *http.Request,
+	isSuccess func(statusCode int) bool,
+) ([]byte, error) {
+	l := logutils.LoggerFromContext(ctx)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// the url might carry the secrets (e.g. telegram bot token)
+		if urlErr := (*url.Error)(nil); errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("%s request failed: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		l.Warn("Failed to read "+name+" response body", zap.Error(err))
+	}
+
+	if isSuccess == nil {
+		isSuccess = func(statusCode int) bool {
+			return statusCode >= 200 && statusCode < 300
+		}
+	}
+	if !isSuccess(resp.StatusCode) {
+		err := &apiError{
+			name:       name,
+			statusCode: resp.StatusCode,
+			body:       string(respBody),
+		}
+		if after := parseRetryAfter(resp.Header.Get("Retry-After")); after > 0 {
+			return nil, &retryAfterError{err: err, after: after}
+		}
+		return nil, err
+	}
+
+	return respBody, nil
+}
+
+// postJSON posts the payload to the API and decodes the response into the
+// result (unless it's nil).
+func postJSON(
+	ctx context.Context,
+	client httpClient,
+	name string,
+	endpoint string,
+	header http.Header,
+	payload any,
+	result any,
+) error {
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(payload); err != nil {
+		return fmt.Errorf("failed to marshal %s payload: %w", name, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, buf)
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", name, err)
+	}
+	for k, vs := range header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	respBody, err := sendRequest(ctx, client, name, req, nil)
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", name, err)
+	}
+	return nil
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s returned status %d: %s", e.name, e.statusCode, e.body)
+}
@@ -0,0 +1,79 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			_, _ = w.Write([]byte("ok"))
+		case "/conflict":
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte("exists"))
+		case "/throttled":
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	send := func(path string, isSuccess func(int) bool) ([]byte, error) {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, srv.URL+path, nil)
+		assert.NoError(t, err)
+		return sendRequest(context.Background(), srv.Client(), "test", req, isSuccess)
+	}
+
+	body, err := send("/ok", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", string(body))
+
+	_, err = send("/conflict", nil)
+	var apiErr *apiError
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, http.StatusConflict, apiErr.statusCode)
+		assert.Equal(t, "exists", apiErr.body)
+	}
+	assert.EqualError(t, err, "test returned status 409: exists")
+
+	// custom success statuses
+	body, err = send("/conflict", func(statusCode int) bool { return statusCode == http.StatusConflict })
+	assert.NoError(t, err)
+	assert.Equal(t, "exists", string(body))
+
+	_, err = send("/throttled", nil)
+	var retryErr *retryAfterError
+	if assert.True(t, errors.As(err, &retryErr)) {
+		assert.Equal(t, 3*time.Second, retryErr.after)
+	}
+	assert.True(t, errors.As(err, &apiErr))
+}
+
+func TestTeamsAndOpsgenieHonourRetryAfter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	for name, p := range map[string]Publisher{
+		"teams":    &teams{url: srv.URL, client: srv.Client(), db: newMemoryDB(t)},
+		"opsgenie": &opsgenie{apiURL: srv.URL, client: srv.Client(), db: newMemoryDB(t)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := p.Publish(context.Background(), "testSource", alertFiring)
+			var retryErr *retryAfterError
+			if assert.True(t, errors.As(err, &retryErr)) {
+				assert.Equal(t, 2*time.Second, retryErr.after)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package publisher
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/db"
+	"github.com/flashbots/amp-alerts-sink/template"
+)
+
+// mattermostMessageLimit is the max length of post message as per mattermost
+// API docs
+const mattermostMessageLimit = 16383
+
+type mattermost struct {
+	url       string
+	token     string
+	channelID string
+
+	client httpClient
+}
+
+type mattermostCreatePost struct {
+	ChannelID string `json:"channel_id"`
+	Message   string `json:"message"`
+	RootID    string `json:"root_id,omitempty"`
+}
+
+type mattermostPost struct {
+	ID string `json:"id"`
+}
+
+func NewMattermost(cfg *config.Mattermost, db db.DB) Publisher {
+	return &chat{
+		name:      "mattermost",
+		channelID: cfg.ChannelID,
+
+		poster: &mattermost{
+			url:       strings.TrimSuffix(cfg.URL, "/"),
+			token:     cfg.Token,
+			channelID: cfg.ChannelID,
+
+			client: http.DefaultClient,
+		},
+		db: db,
+	}
+}
+
+func (m *mattermost) post(
+	ctx context.Context,
+	data *template.Data,
+	threadID string,
+) (string, error) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+m.token)
+
+	post := mattermostCreatePost{
+		ChannelID: m.channelID,
+		Message:   truncate(renderChatText(ctx, chatMarkdownText, data), mattermostMessageLimit),
+		RootID:    threadID,
+	}
+
+	res := &mattermostPost{}
+	if err := postJSON(ctx, m.client, "mattermost",
+		m.url+"/api/v4/posts", header, post, res,
+	); err != nil {
+		return "", err
+	}
+
+	return res.ID, nil
+}
@@ -0,0 +1,52 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMattermostReplies(t *testing.T) {
+	posts := []mattermostCreatePost{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v4/posts", r.URL.Path)
+		assert.Equal(t, "Bearer theToken", r.Header.Get("Authorization"))
+		post := mattermostCreatePost{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&post))
+		posts = append(posts, post)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id":"post%d"}`, len(posts))
+	}))
+	defer srv.Close()
+
+	p := NewMattermost(&config.Mattermost{
+		URL:       srv.URL + "/",
+		Token:     "theToken",
+		ChannelID: "theChannel",
+	}, newMemoryDB(t))
+
+	ctx := context.Background()
+	assert.NoError(t, p.Publish(ctx, "testSource", alertFiring))
+
+	resolved := *alertFiring
+	resolved.Status = "resolved"
+	assert.NoError(t, p.Publish(ctx, "testSource", &resolved))
+
+	// another HA replica delivers the same alert
+	assert.NoError(t, p.Publish(ctx, "testSource", &resolved))
+
+	if !assert.Len(t, posts, 2) {
+		return
+	}
+	assert.Equal(t, "theChannel", posts[0].ChannelID)
+	assert.Empty(t, posts[0].RootID)
+	assert.Contains(t, posts[0].Message, "**FIRING: TestAlert**")
+	assert.Equal(t, "post1", posts[1].RootID)
+	assert.Contains(t, posts[1].Message, "**RESOLVED: TestAlert**")
+}
@@ -0,0 +1,198 @@
+package publisher
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/db"
+	"github.com/flashbots/amp-alerts-sink/logutils"
+	"github.com/flashbots/amp-alerts-sink/types"
+	"go.uber.org/zap"
+)
+
+const (
+	opsgenieAPIURLEU = "https://api.eu.opsgenie.com"
+	opsgenieAPIURLUS = "https://api.opsgenie.com"
+
+	// opsgenieMessageLimit is the max length of alert's message as per
+	// opsgenie API docs
+	opsgenieMessageLimit = 130
+
+	// opsgenieMaxTags and opsgenieTagLimit are the max count and length of
+	// alert's tags (opsgenie rejects the alerts that exceed them)
+	opsgenieMaxTags  = 20
+	opsgenieTagLimit = 50
+
+	opsgenieDefaultPriority = "P3"
+)
+
+// opsgeniePriorities maps the severity label onto opsgenie priority.
+//
+// Alertmanager conventionally has only 4 severities, while opsgenie has 5
+// priorities: P4 ("low") is left out, so that "info" alerts land on P5
+// ("informational") and are not mistaken for the ones that need a look.
+// Unknown severities get P3 (which is also opsgenie's own default).
+var opsgeniePriorities = map[string]string{
+	"critical": "P1",
+	"error":    "P2",
+	"warning":  "P3",
+	"info":     "P5",
+}
+
+type opsgenie struct {
+	apiKey string
+	apiURL string
+
+	tagLabels []string
+
+	client httpClient
+	db     db.DB
+}
+
+type opsgenieCreateAlert struct {
+	Alias       string            `json:"alias"`
+	Message     string            `json:"message"`
+	Description string            `json:"description,omitempty"`
+	Priority    string            `json:"priority"`
+	Source      string            `json:"source,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+}
+
+type opsgenieCloseAlert struct {
+	Source string `json:"source,omitempty"`
+	Note   string `json:"note,omitempty"`
+}
+
+func NewOpsgenie(cfg *config.Opsgenie, db db.DB) Publisher {
+	apiURL := cfg.APIURL
+	if apiURL == "" {
+		apiURL = opsgenieAPIURLUS
+		if cfg.Region == config.OpsgenieRegionEU {
+			apiURL = opsgenieAPIURLEU
+		}
+	}
+
+	return &opsgenie{
+		apiKey: cfg.APIKey,
+		apiURL: strings.TrimSuffix(apiURL, "/"),
+
+		tagLabels: cfg.TagLabels,
+
+		client: http.DefaultClient,
+		db:     db,
+	}
+}
+
+func (o *opsgenie) Publish(
+	ctx context.Context,
+	source string,
+	alert *types.AlertmanagerAlert,
+) error {
+	l := logutils.LoggerFromContext(ctx)
+
+	// Use SNS topic ARN, unless the "source" label is set
+	if src := alert.Labels["source"]; src != "" {
+		source = src
+	}
+
+	var (
+		path string
+		body any
+	)
+	switch alert.Status {
+	case "resolved":
+		path = "/v2/alerts/" + url.PathEscape(alert.IncidentDedupKey()) + "/close?identifierType=alias"
+		body = opsgenieCloseAlert{
+			Source: source,
+			Note:   "Resolved in alertmanager",
+		}
+	default:
+		path = "/v2/alerts"
+		body = o.newAlert(source, alert)
+	}
+
+	return publishOnce(ctx, o.db, alert.MessageDedupKey(), timeoutOpsgenieExpiry, "opsgenie", func() error {
+		if err := o.post(ctx, path, body); err != nil {
+			return err
+		}
+		l.Info("Successfully published to opsgenie",
+			zap.Any("alert", alert),
+		)
+		return nil
+	})
+}
+
+func (o *opsgenie) newAlert(source string, alert *types.AlertmanagerAlert) opsgenieCreateAlert {
+	message := alert.Labels["alertname"]
+	if summary := alert.Annotations["summary"]; summary != "" {
+		message += ": " + summary
+	}
+	message = truncate(message, opsgenieMessageLimit)
+
+	priority, ok := opsgeniePriorities[alert.Labels["severity"]]
+	if !ok {
+		priority = opsgenieDefaultPriority
+	}
+
+	tags := o.tags(alert)
+
+	details := maps.Clone(alert.Labels)
+	if details == nil {
+		details = map[string]string{}
+	}
+	maps.Copy(details, alert.Annotations)
+	delete(details, "summary")
+	delete(details, "description")
+	if alert.GeneratorURL != "" {
+		details["generator_url"] = alert.GeneratorURL
+	}
+	if alert.SilenceURL != "" {
+		details["silence_url"] = alert.SilenceURL
+	}
+
+	return opsgenieCreateAlert{
+		Alias:       alert.IncidentDedupKey(),
+		Message:     message,
+		Description: alert.Annotations["description"],
+		Priority:    priority,
+		Source:      source,
+		Tags:        tags,
+		Details:     details,
+	}
+}
+
+// tags returns the alert's tags (either the configured labels, or all of them)
+// within the opsgenie limits.
+func (o *opsgenie) tags(alert *types.AlertmanagerAlert) []string {
+	labels := o.tagLabels
+	if len(labels) == 0 {
+		labels = slices.Sorted(maps.Keys(alert.Labels))
+	}
+
+	tags := make([]string, 0, min(len(labels), opsgenieMaxTags))
+	for _, k := range labels {
+		if len(tags) == opsgenieMaxTags {
+			break
+		}
+		v, ok := alert.Labels[k]
+		if !ok {
+			continue
+		}
+		tags = append(tags, truncate(k+"="+v, opsgenieTagLimit))
+	}
+	return tags
+}
+
+func (o *opsgenie) post(ctx context.Context, path string, body any) error {
+	return postJSON(ctx, o.client, "opsgenie", o.apiURL+path,
+		http.Header{"Authorization": {"GenieKey " + o.apiKey}},
+		body,
+		nil,
+	)
+}
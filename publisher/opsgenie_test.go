@@ -0,0 +1,146 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type opsgenieRequest struct {
+	path  string
+	query string
+	auth  string
+	body  map[string]any
+}
+
+func setupOpsgenie(t *testing.T, status int) (Publisher, *[]opsgenieRequest) {
+	requests := []opsgenieRequest{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := opsgenieRequest{
+			path:  r.URL.Path,
+			query: r.URL.RawQuery,
+			auth:  r.Header.Get("Authorization"),
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req.body))
+		requests = append(requests, req)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+
+	p := NewOpsgenie(&config.Opsgenie{
+		APIKey: "theKey",
+		APIURL: srv.URL,
+	}, newMemoryDB(t))
+
+	return p, &requests
+}
+
+func TestOpsgenieCreateAndClose(t *testing.T) {
+	p, requests := setupOpsgenie(t, http.StatusAccepted)
+	ctx := context.Background()
+
+	assert.NoError(t, p.Publish(ctx, "testSource", alertFiring))
+
+	resolved := *alertFiring
+	resolved.Status = "resolved"
+	assert.NoError(t, p.Publish(ctx, "testSource", &resolved))
+
+	if !assert.Len(t, *requests, 2) {
+		return
+	}
+
+	create := (*requests)[0]
+	assert.Equal(t, "/v2/alerts", create.path)
+	assert.Equal(t, "GenieKey theKey", create.auth)
+	assert.Equal(t, alertFiring.IncidentDedupKey(), create.body["alias"])
+	assert.Equal(t, "TestAlert: Notification test", create.body["message"])
+	assert.Equal(t, "P1", create.body["priority"])
+	assert.Equal(t, "testSource", create.body["source"])
+	assert.Contains(t, create.body["tags"], "instance=Grafana")
+	assert.Equal(t, "Grafana", create.body["details"].(map[string]any)["instance"])
+
+	closing := (*requests)[1]
+	assert.Equal(t, "/v2/alerts/"+alertFiring.IncidentDedupKey()+"/close", closing.path)
+	assert.Equal(t, "identifierType=alias", closing.query)
+}
+
+func TestOpsgeniePriority(t *testing.T) {
+	p, requests := setupOpsgenie(t, http.StatusAccepted)
+	ctx := context.Background()
+
+	for _, severity := range []string{"error", "warning", "info", "unknown"} {
+		assert.NoError(t, p.Publish(ctx, "testSource", &types.AlertmanagerAlert{
+			Status: "firing",
+			Labels: map[string]string{"alertname": "TestAlert", "severity": severity},
+		}))
+	}
+
+	priorities := []any{}
+	for _, req := range *requests {
+		priorities = append(priorities, req.body["priority"])
+	}
+	assert.Equal(t, []any{"P2", "P3", "P5", "P3"}, priorities)
+}
+
+func TestOpsgenieDuplicate(t *testing.T) {
+	p, requests := setupOpsgenie(t, http.StatusAccepted)
+	ctx := context.Background()
+
+	// another HA replica delivers the same alert
+	assert.NoError(t, p.Publish(ctx, "testSource", alertFiring))
+	assert.NoError(t, p.Publish(ctx, "anotherSource", alertFiring))
+	assert.Len(t, *requests, 1)
+}
+
+func TestOpsgenieError(t *testing.T) {
+	p, _ := setupOpsgenie(t, http.StatusUnprocessableEntity)
+
+	assert.Error(t, p.Publish(context.Background(), "testSource", alertFiring))
+}
+
+func TestOpsgenieRegion(t *testing.T) {
+	eu := NewOpsgenie(&config.Opsgenie{Region: config.OpsgenieRegionEU}, nil).(*opsgenie)
+	assert.Equal(t, opsgenieAPIURLEU, eu.apiURL)
+
+	us := NewOpsgenie(&config.Opsgenie{}, nil).(*opsgenie)
+	assert.Equal(t, opsgenieAPIURLUS, us.apiURL)
+}
+
+func TestOpsgenieLimits(t *testing.T) {
+	o := NewOpsgenie(&config.Opsgenie{APIKey: "theKey"}, nil).(*opsgenie)
+
+	labels := map[string]string{
+		"alertname": strings.Repeat("ж", 2*opsgenieMessageLimit),
+		"long":      strings.Repeat("x", 2*opsgenieTagLimit),
+	}
+	for i := range 2 * opsgenieMaxTags {
+		labels[fmt.Sprintf("label%02d", i)] = "value"
+	}
+
+	a := o.newAlert("testSource", &types.AlertmanagerAlert{Status: "firing", Labels: labels})
+	assert.True(t, utf8.ValidString(a.Message))
+	assert.Equal(t, opsgenieMessageLimit, utf8.RuneCountInString(a.Message))
+	assert.Len(t, a.Tags, opsgenieMaxTags)
+	for _, tag := range a.Tags {
+		assert.LessOrEqual(t, utf8.RuneCountInString(tag), opsgenieTagLimit)
+	}
+}
+
+func TestOpsgenieTagLabels(t *testing.T) {
+	o := NewOpsgenie(&config.Opsgenie{
+		APIKey:    "theKey",
+		TagLabels: []string{"severity", "missing", "instance"},
+	}, nil).(*opsgenie)
+
+	a := o.newAlert("testSource", alertFiring)
+	assert.Equal(t, []string{"severity=critical", "instance=Grafana"}, a.Tags)
+}
@@ -4,6 +4,8 @@ package publisher
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"maps"
@@ -12,28 +14,94 @@ import (
 	"time"
 
 	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/db"
 	"github.com/flashbots/amp-alerts-sink/logutils"
+	"github.com/flashbots/amp-alerts-sink/matcher"
 	"github.com/flashbots/amp-alerts-sink/types"
 
 	"github.com/PagerDuty/go-pagerduty"
 	"go.uber.org/zap"
 )
 
-func NewPagerDuty(cfg *config.PagerDuty) Publisher {
+const (
+	// pagerDutyErrorDedupKey makes all failures to publish to be collected
+	// into one incident (per service) instead of spawning a new one each time
+	pagerDutyErrorDedupKey = "amp-alerts-sink/failed-to-post"
+
+	// pagerDutyHintAction (annotation) set to "acknowledge" makes the firing
+	// alert to acknowledge the incident instead of triggering it (it can not
+	// be a label, as labels are part of incident's dedup key)
+	pagerDutyHintAction = "pagerduty_action"
+
+	// pagerDutyHintEventType (label or annotation) set to "change" makes the
+	// firing alert to be published as a change event
+	pagerDutyHintEventType = "pagerduty_event_type"
+
+	defaultPagerDutyClassLabel = "alertname"
+)
+
+func NewPagerDuty(cfg *config.PagerDuty, db db.DB) (Publisher, error) {
+	routingKeys := make([]pagerDutyRoutingKey, 0, len(cfg.RoutingKeys))
+	for _, rk := range cfg.RoutingKeys {
+		matchLabels, err := rk.MatchLabelsMatchers()
+		if err != nil {
+			return nil, err
+		}
+		routingKeys = append(routingKeys, pagerDutyRoutingKey{
+			key:         rk.Key,
+			matchLabels: matchLabels,
+		})
+	}
+
 	c := pagerduty.NewClient("auth-token-unused")
 	c.SetDebugFlag(pagerduty.DebugCaptureLastResponse)
+	classLabel := cfg.ClassLabel
+	if classLabel == "" {
+		classLabel = defaultPagerDutyClassLabel
+	}
+
+	// where to look for the details of the failures
+	logsHint := "Check amp-alerts-sink logs for more details"
+	if cfg.RunMode == config.RunModeLambda {
+		logsHint = "Check AWS lambda logs for more details"
+	}
+
 	return pagerDuty{
 		integrationKey: cfg.IntegrationKey,
-		client:         c,
-	}
+		routingKeys:    routingKeys,
+
+		classLabel:     classLabel,
+		componentLabel: cfg.ComponentLabel,
+		groupLabel:     cfg.GroupLabel,
+
+		logsHint: logsHint,
+
+		client: c,
+		db:     db,
+	}, nil
 }
 
 type pagerDuty struct {
 	integrationKey string
-	client         pagerDutyClient
+	routingKeys    []pagerDutyRoutingKey
+
+	classLabel     string
+	componentLabel string
+	groupLabel     string
+
+	logsHint string
+
+	client pagerDutyClient
+	db     db.DB
+}
+
+type pagerDutyRoutingKey struct {
+	key         string
+	matchLabels matcher.Matchers
 }
 
 type pagerDutyClient interface {
+	CreateChangeEventWithContext(context.Context, pagerduty.ChangeEvent) (*pagerduty.ChangeEventResponse, error)
 	ManageEventWithContext(context.Context, *pagerduty.V2Event) (*pagerduty.V2EventResponse, error)
 	LastAPIResponse() (*http.Response, bool)
 }
@@ -42,81 +110,69 @@ func (p pagerDuty) Publish(
 	ctx context.Context,
 	source string,
 	alert *types.AlertmanagerAlert,
-) (err error) {
+) error {
 	l := logutils.LoggerFromContext(ctx)
 
-	defer func() {
+	routingKey := p.routingKey(alert)
+	if routingKey == "" {
+		l.Debug("Skipped the alert as it matches none of pagerduty routing keys")
+		return nil
+	}
+
+	// change events are just the markers (e.g. of deployments), there's
+	// nothing to resolve about them
+	isChangeEvent := pagerDutyHint(alert, pagerDutyHintEventType) == "change"
+	if isChangeEvent && alert.Status != "firing" {
+		l.Debug("Skipped non-firing pagerduty change event")
+		return nil
+	}
+
+	return publishOnce(ctx, p.db, alert.MessageDedupKey(), timeoutPagerDutyExpiry, "pagerduty", func() error {
+		event := p.newEvent(source, alert, routingKey)
+
+		var err error
+		if isChangeEvent {
+			err = p.publishChangeEvent(ctx, alert, event)
+		} else {
+			err = p.publishEvent(ctx, alert, event)
+		}
 		if err != nil {
-			l.Error("Failed to publish alert to pagerduty", zap.Error(err))
-
-			errResp, ok := p.client.LastAPIResponse()
-			if ok && errResp != nil {
-				body, err := io.ReadAll(errResp.Body)
-
-				logFields := []zap.Field{
-					zap.String("status", errResp.Status),
-					zap.String("headers", fmt.Sprintf("%+v", errResp.Header)),
-					zap.String("body", string(body)),
-				}
-				if err != nil {
-					logFields = append(logFields, zap.Error(err))
-				}
-
-				l.Error("PagerDuty API response", logFields...)
-			}
-
-			// If we fail to publish the alert, we should publish another alert
-			// to notify that something is wrong.
-			// Not including dedup_key so to spawn a new incident.
-
-			errStr := err.Error()
-			if len(errStr) > 1024 {
-				errStr = errStr[:1024] // so that we don't accidentally exceed the size limit
-			}
-			errEvent := &pagerduty.V2Event{
-				RoutingKey: p.integrationKey,
-				Action:     "trigger",
-				Payload: &pagerduty.V2Payload{
-					Summary:  "Failed to post alert to pagerduty",
-					Source:   "amp-alerts-sink",
-					Severity: "critical",
-					Details: map[string]string{
-						"err":  errStr,
-						"text": "Check AWS lambda logs for more details",
-					},
-				},
-			}
-
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
-
-			resp, eerr := p.client.ManageEventWithContext(ctx, errEvent)
-			if eerr != nil {
-				l.Error("Failed to publish error alert to pagerduty",
-					zap.Error(eerr),
-				)
-			} else if len(resp.Errors) > 0 {
-				l.Error("Failed to publish error alert to pagerduty",
-					zap.Any("errors", resp.Errors),
-				)
-			} else {
-				l.Info("Published error alert to pagerduty")
-			}
+			p.reportFailure(ctx, routingKey, err)
+			return err
 		}
-	}()
+		l.Info("Successfully published to pagerduty")
+
+		return nil
+	})
+}
 
+// newEvent returns the event for the alert.
+func (p pagerDuty) newEvent(
+	source string,
+	alert *types.AlertmanagerAlert,
+	routingKey string,
+) *pagerduty.V2Event {
 	event := &pagerduty.V2Event{
-		RoutingKey: p.integrationKey,
+		RoutingKey: routingKey,
 		DedupKey:   alert.IncidentDedupKey(),
 		Payload: &pagerduty.V2Payload{
 			Timestamp: alert.StartsAt,
-			Class:     alert.Labels["alertname"],
+			Class:     alert.Labels[p.classLabel],
 		},
 	}
+	if p.componentLabel != "" {
+		event.Payload.Component = alert.Labels[p.componentLabel]
+	}
+	if p.groupLabel != "" {
+		event.Payload.Group = alert.Labels[p.groupLabel]
+	}
 
 	event.Action = "trigger"
-	if alert.Status == "resolved" {
+	switch {
+	case alert.Status == "resolved":
 		event.Action = "resolve"
+	case alert.Annotations[pagerDutyHintAction] == "acknowledge":
+		event.Action = "acknowledge"
 	}
 
 	addLink := func(href, text string) {
@@ -173,6 +229,94 @@ func (p pagerDuty) Publish(
 	delete(details, "summary")
 	event.Payload.Details = details
 
+	return event
+}
+
+// reportFailure logs the details of failed publishing, and (unless there
+// will be another attempt) raises the incident about it with the routing key.
+func (p pagerDuty) reportFailure(ctx context.Context, routingKey string, err error) {
+	l := logutils.LoggerFromContext(ctx)
+
+	errResp, ok := p.client.LastAPIResponse()
+	if ok && errResp != nil {
+		body, err := io.ReadAll(errResp.Body)
+
+		logFields := []zap.Field{
+			zap.String("status", errResp.Status),
+			zap.String("headers", fmt.Sprintf("%+v", errResp.Header)),
+			zap.String("body", string(body)),
+		}
+		if err != nil {
+			logFields = append(logFields, zap.Error(err))
+		}
+
+		l.Error("PagerDuty API response", logFields...)
+	}
+
+	if !isLastAttempt(ctx) && isRetriable(err) {
+		// there will be another attempt, no need to raise the alarm yet
+		return
+	}
+
+	// If we fail to publish the alert, we should publish another alert
+	// to notify that something is wrong.
+	// Using the stable dedup_key so that the failures accumulate in the
+	// same incident, and not sending it more often than once in a while.
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// routing keys are secrets, so only their hashes go into the db
+	routingKeyHash := sha256.Sum256([]byte(routingKey))
+	dbKeyError := pagerDutyErrorDedupKey + "/" + hex.EncodeToString(routingKeyHash[:])
+	if publishedAt, err := p.db.Get(ctx, dbKeyError); err == nil && publishedAt != "" {
+		l.Info("Skipped publishing error alert to pagerduty as it was published recently",
+			zap.String("published_at", publishedAt),
+		)
+		return
+	}
+
+	errStr := err.Error()
+	if len(errStr) > 1024 {
+		errStr = errStr[:1024] // so that we don't accidentally exceed the size limit
+	}
+	errEvent := &pagerduty.V2Event{
+		RoutingKey: routingKey,
+		DedupKey:   pagerDutyErrorDedupKey,
+		Action:     "trigger",
+		Payload: &pagerduty.V2Payload{
+			Summary:  "Failed to post alert to pagerduty",
+			Source:   "amp-alerts-sink",
+			Severity: "critical",
+			Details: map[string]string{
+				"err":  errStr,
+				"text": p.logsHint,
+			},
+		},
+	}
+
+	resp, eerr := p.client.ManageEventWithContext(ctx, errEvent)
+	if eerr != nil {
+		l.Error("Failed to publish error alert to pagerduty",
+			zap.Error(eerr),
+		)
+	} else if len(resp.Errors) > 0 {
+		l.Error("Failed to publish error alert to pagerduty",
+			zap.Any("errors", resp.Errors),
+		)
+	} else {
+		l.Info("Published error alert to pagerduty")
+		_ = p.db.Set(ctx, dbKeyError, timeoutPagerDutyErrorPeriod, time.Now().UTC().Format(time.RFC3339))
+	}
+}
+
+func (p pagerDuty) publishEvent(
+	ctx context.Context,
+	alert *types.AlertmanagerAlert,
+	event *pagerduty.V2Event,
+) error {
+	l := logutils.LoggerFromContext(ctx)
+
 	l.Info(
 		"Publishing alert to pagerduty",
 		zap.Any("alert", alert),
@@ -185,6 +329,70 @@ func (p pagerDuty) Publish(
 	if len(resp.Errors) > 0 {
 		return fmt.Errorf("pagerduty: %v", resp.Errors)
 	}
-	l.Info("Successfully published to pagerduty")
 	return nil
 }
+
+func (p pagerDuty) publishChangeEvent(
+	ctx context.Context,
+	alert *types.AlertmanagerAlert,
+	event *pagerduty.V2Event,
+) error {
+	l := logutils.LoggerFromContext(ctx)
+
+	changeEvent := pagerduty.ChangeEvent{
+		RoutingKey: event.RoutingKey,
+		Payload: pagerduty.ChangeEventPayload{
+			Summary:       event.Payload.Summary,
+			Source:        event.Payload.Source,
+			Timestamp:     event.Payload.Timestamp,
+			CustomDetails: map[string]any{},
+		},
+	}
+	if details, ok := event.Payload.Details.(map[string]string); ok {
+		for k, v := range details {
+			changeEvent.Payload.CustomDetails[k] = v
+		}
+	}
+	for _, link := range event.Links {
+		if link, ok := link.(map[string]string); ok {
+			changeEvent.Links = append(changeEvent.Links, pagerduty.ChangeEventLink{
+				Href: link["href"],
+				Text: link["text"],
+			})
+		}
+	}
+
+	l.Info(
+		"Publishing change event to pagerduty",
+		zap.Any("alert", alert),
+		zap.Any("event", changeEvent),
+	)
+	resp, err := p.client.CreateChangeEventWithContext(ctx, changeEvent)
+	if err != nil {
+		return err
+	}
+	if len(resp.Errors) > 0 {
+		return fmt.Errorf("pagerduty: %v", resp.Errors)
+	}
+	return nil
+}
+
+// pagerDutyHint returns the value of the label (or, if there's no such
+// label, of the annotation) that controls how the alert is published.
+func pagerDutyHint(alert *types.AlertmanagerAlert, name string) string {
+	if value, ok := alert.Labels[name]; ok {
+		return value
+	}
+	return alert.Annotations[name]
+}
+
+// routingKey returns the key of the first routing rule that matches the alert
+// (or the default integration key, if none does).
+func (p pagerDuty) routingKey(alert *types.AlertmanagerAlert) string {
+	for _, rk := range p.routingKeys {
+		if rk.matchLabels.Matches(alert.Labels) {
+			return rk.key
+		}
+	}
+	return p.integrationKey
+}
@@ -2,9 +2,14 @@ package publisher
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/db"
 	"github.com/flashbots/amp-alerts-sink/types"
 	"github.com/stretchr/testify/assert"
 
@@ -14,14 +19,24 @@ import (
 	"go.uber.org/mock/gomock"
 )
 
+// newMemoryDB returns in-memory db that is not shared with other tests.
+func newMemoryDB(t *testing.T) db.DB {
+	cfg := config.New()
+	cfg.LocalDB.Path = config.LocalDBInMemory
+	memoryDB, err := db.New(cfg)
+	assert.NoError(t, err)
+	return memoryDB.WithNamespace(t.Name())
+}
+
 func setupPagerDutyPublisher(t *testing.T) (Publisher, *mock_publisher.Mock_pagerDutyClient) {
 	ctrl := gomock.NewController(t)
 
 	pdMock := mock_publisher.NewMock_pagerDutyClient(ctrl)
 
-	pd := NewPagerDuty(&config.PagerDuty{
+	pd, err := NewPagerDuty(&config.PagerDuty{
 		IntegrationKey: "theKey",
-	})
+	}, newMemoryDB(t))
+	assert.NoError(t, err)
 
 	_pd := pd.(pagerDuty)
 	_pd.client = pdMock
@@ -140,3 +155,213 @@ func TestPagerDutyResolveAlert(t *testing.T) {
 	err = p.Publish(ctx, "testSource", alertResolved)
 	assert.NoError(t, err)
 }
+
+func TestPagerDutyRoutingKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	pdMock := mock_publisher.NewMock_pagerDutyClient(ctrl)
+
+	newPublisher := func(integrationKey string) Publisher {
+		pd, err := NewPagerDuty(&config.PagerDuty{
+			IntegrationKey: integrationKey,
+			RoutingKeys: []*config.PagerDutyRoutingKey{
+				{Key: "paymentsKey", MatchLabels: []string{"team=payments"}},
+				{Key: "infraKey", MatchLabels: []string{"team=infra"}},
+			},
+		}, newMemoryDB(t))
+		assert.NoError(t, err)
+		_pd := pd.(pagerDuty)
+		_pd.client = pdMock
+		return _pd
+	}
+
+	newAlert := func(team string) *types.AlertmanagerAlert {
+		return &types.AlertmanagerAlert{
+			Status: "firing",
+			Labels: map[string]string{"alertname": "TestAlert", "team": team},
+		}
+	}
+
+	ctx := context.Background()
+	keys := []string{}
+	pdMock.EXPECT().
+		ManageEventWithContext(ctx, gomock.Any()).
+		DoAndReturn(func(_ context.Context, event *pagerduty.V2Event) (*pagerduty.V2EventResponse, error) {
+			keys = append(keys, event.RoutingKey)
+			return &pagerduty.V2EventResponse{}, nil
+		}).
+		Times(3)
+
+	p := newPublisher("defaultKey")
+	assert.NoError(t, p.Publish(ctx, "testSource", newAlert("infra")))
+	assert.NoError(t, p.Publish(ctx, "testSource", newAlert("payments")))
+	assert.NoError(t, p.Publish(ctx, "testSource", newAlert("frontend")))
+	assert.Equal(t, []string{"infraKey", "paymentsKey", "defaultKey"}, keys)
+
+	// without the default key un-matched alerts are skipped
+	p = newPublisher("")
+	assert.NoError(t, p.Publish(ctx, "testSource", newAlert("frontend")))
+}
+
+func TestPagerDutyHADuplicate(t *testing.T) {
+	p, pdMock := setupPagerDutyPublisher(t)
+	ctx := context.Background()
+
+	pdMock.EXPECT().
+		ManageEventWithContext(ctx, gomock.Any()).
+		Return(&pagerduty.V2EventResponse{}, nil).
+		Times(1)
+
+	// another HA replica delivers the same alert
+	assert.NoError(t, p.Publish(ctx, "testSource", alertResolved))
+	assert.NoError(t, p.Publish(ctx, "anotherSource", alertResolved))
+}
+
+func TestPagerDutyErrorIncident(t *testing.T) {
+	p, pdMock := setupPagerDutyPublisher(t)
+	ctx := context.Background()
+
+	errorEvents := 0
+	pdMock.EXPECT().
+		ManageEventWithContext(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, event *pagerduty.V2Event) (*pagerduty.V2EventResponse, error) {
+			if event.DedupKey == pagerDutyErrorDedupKey {
+				errorEvents++
+				assert.Equal(t, "Check amp-alerts-sink logs for more details", event.Payload.Details.(map[string]string)["text"])
+				return &pagerduty.V2EventResponse{}, nil
+			}
+			return nil, errors.New("boom")
+		}).
+		AnyTimes()
+	pdMock.EXPECT().
+		LastAPIResponse().
+		Return(nil, false).
+		AnyTimes()
+
+	newAlert := func(name string) *types.AlertmanagerAlert {
+		return &types.AlertmanagerAlert{
+			Status: "firing",
+			Labels: map[string]string{"alertname": name},
+		}
+	}
+
+	assert.Error(t, p.Publish(ctx, "testSource", newAlert("first")))
+	assert.Error(t, p.Publish(ctx, "testSource", newAlert("second")))
+	assert.Equal(t, 1, errorEvents, "error incidents must be rate-limited")
+
+	// the record that outlived its period must not block the error incident
+	routingKeyHash := sha256.Sum256([]byte("theKey"))
+	dbKeyError := pagerDutyErrorDedupKey + "/" + hex.EncodeToString(routingKeyHash[:])
+	published := time.Now().Add(-timeoutPagerDutyErrorPeriod).UTC().Format(time.RFC3339)
+	assert.NoError(t, p.(pagerDuty).db.Set(ctx, dbKeyError, time.Millisecond, published))
+	time.Sleep(10 * time.Millisecond)
+
+	assert.Error(t, p.Publish(ctx, "testSource", newAlert("third")))
+	assert.Equal(t, 2, errorEvents)
+}
+
+func TestPagerDutyLogsHint(t *testing.T) {
+	for mode, expected := range map[string]string{
+		config.RunModeLambda: "Check AWS lambda logs for more details",
+		config.RunModeServe:  "Check amp-alerts-sink logs for more details",
+	} {
+		pd, err := NewPagerDuty(&config.PagerDuty{IntegrationKey: "theKey", RunMode: mode}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, pd.(pagerDuty).logsHint, mode)
+	}
+}
+
+func TestPagerDutyAcknowledgeAndMapping(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	pdMock := mock_publisher.NewMock_pagerDutyClient(ctrl)
+
+	pd, err := NewPagerDuty(&config.PagerDuty{
+		IntegrationKey: "theKey",
+		ComponentLabel: "service",
+		GroupLabel:     "cluster",
+	}, newMemoryDB(t))
+	assert.NoError(t, err)
+	_pd := pd.(pagerDuty)
+	_pd.client = pdMock
+
+	ctx := context.Background()
+	pdMock.EXPECT().
+		ManageEventWithContext(ctx, gomock.Any()).
+		DoAndReturn(func(_ context.Context, event *pagerduty.V2Event) (*pagerduty.V2EventResponse, error) {
+			assert.Equal(t, "acknowledge", event.Action)
+			assert.Equal(t, "TestAlert", event.Payload.Class)
+			assert.Equal(t, "api", event.Payload.Component)
+			assert.Equal(t, "prod", event.Payload.Group)
+			return &pagerduty.V2EventResponse{}, nil
+		})
+
+	err = _pd.Publish(ctx, "testSource", &types.AlertmanagerAlert{
+		Status:      "firing",
+		Labels:      map[string]string{"alertname": "TestAlert", "service": "api", "cluster": "prod"},
+		Annotations: map[string]string{"pagerduty_action": "acknowledge"},
+	})
+	assert.NoError(t, err)
+}
+
+func TestPagerDutyTriggerThenAcknowledge(t *testing.T) {
+	p, pdMock := setupPagerDutyPublisher(t)
+	ctx := context.Background()
+
+	events := []*pagerduty.V2Event{}
+	pdMock.EXPECT().
+		ManageEventWithContext(ctx, gomock.Any()).
+		DoAndReturn(func(_ context.Context, event *pagerduty.V2Event) (*pagerduty.V2EventResponse, error) {
+			events = append(events, event)
+			return &pagerduty.V2EventResponse{}, nil
+		}).
+		Times(2)
+
+	firing := &types.AlertmanagerAlert{
+		Status:   "firing",
+		StartsAt: "2023-07-15T21:37:23.977957594Z",
+		Labels:   map[string]string{"alertname": "TestAlert", "severity": "critical"},
+	}
+	assert.NoError(t, p.Publish(ctx, "testSource", firing))
+
+	acknowledged := *firing
+	acknowledged.Annotations = map[string]string{"pagerduty_action": "acknowledge"}
+	assert.NoError(t, p.Publish(ctx, "testSource", &acknowledged))
+
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "trigger", events[0].Action)
+		assert.Equal(t, "acknowledge", events[1].Action)
+		assert.Equal(t, events[0].DedupKey, events[1].DedupKey)
+	}
+}
+
+func TestPagerDutyChangeEvent(t *testing.T) {
+	p, pdMock := setupPagerDutyPublisher(t)
+	ctx := context.Background()
+
+	pdMock.EXPECT().
+		CreateChangeEventWithContext(ctx, gomock.Any()).
+		DoAndReturn(func(_ context.Context, event pagerduty.ChangeEvent) (*pagerduty.ChangeEventResponse, error) {
+			assert.Equal(t, "theKey", event.RoutingKey)
+			assert.Equal(t, "Deployed: api v1.2.3", event.Payload.Summary)
+			assert.Equal(t, "v1.2.3", event.Payload.CustomDetails["version"])
+			if assert.Len(t, event.Links, 1) {
+				assert.Equal(t, "https://ci/build/1", event.Links[0].Href)
+			}
+			return &pagerduty.ChangeEventResponse{}, nil
+		})
+
+	alert := &types.AlertmanagerAlert{
+		Status:       "firing",
+		GeneratorURL: "https://ci/build/1",
+		Labels: map[string]string{
+			"alertname":            "Deployed",
+			"pagerduty_event_type": "change",
+			"version":              "v1.2.3",
+		},
+		Annotations: map[string]string{"summary": "api v1.2.3"},
+	}
+	assert.NoError(t, p.Publish(ctx, "testSource", alert))
+
+	// change events are never resolved
+	alert.Status = "resolved"
+	assert.NoError(t, p.Publish(ctx, "testSource", alert))
+}
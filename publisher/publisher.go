@@ -2,17 +2,117 @@ package publisher
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"time"
 
+	"github.com/flashbots/amp-alerts-sink/db"
+	"github.com/flashbots/amp-alerts-sink/logutils"
 	"github.com/flashbots/amp-alerts-sink/types"
+	"go.uber.org/zap"
 )
 
 type Publisher interface {
 	Publish(ctx context.Context, source string, alert *types.AlertmanagerAlert) error
 }
 
+type rawMessageContextKey struct{}
+
 const (
-	timeoutLock          = time.Second
-	timeoutThreadExpiry  = 30 * 24 * time.Hour
-	timeoutWebhookExpiry = 30 * 24 * time.Hour
+	timeoutLock                 = time.Second
+	timeoutEmailExpiry          = 30 * 24 * time.Hour
+	timeoutOpsgenieExpiry       = 30 * 24 * time.Hour
+	timeoutPagerDutyErrorPeriod = 15 * time.Minute
+	timeoutPagerDutyExpiry      = 30 * 24 * time.Hour
+	timeoutSlackGroupLock       = 10 * time.Second
+	timeoutTeamsExpiry          = 30 * 24 * time.Hour
+	timeoutThreadExpiry         = 30 * 24 * time.Hour
+	timeoutWebhookExpiry        = 30 * 24 * time.Hour
 )
+
+// checkDupAndLock returns true if the message with the key was already
+// published, or if another instance is about to publish it (in which case
+// ErrAlreadyLocked is returned as well, so that the publishing is retried).
+func checkDupAndLock(ctx context.Context, db db.DB, key string) (isDup bool, err error) {
+	v, err := db.Get(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for duplicate alert: %w", err)
+	}
+	if v != "" {
+		return true, nil
+	}
+
+	didLock, err := db.Lock(ctx, key, timeoutLock)
+	if err != nil {
+		return false, fmt.Errorf("failed to lock alert: %w", err)
+	}
+	if !didLock {
+		// another instance is about to publish
+		return true, ErrAlreadyLocked
+	}
+
+	return false, nil
+}
+
+// publishOnce publishes the alert (unless it was already published with the
+// same key, by this or another instance), and marks it as sent with the key
+// for the duration of expiry.  The name of the destination is only used in
+// the logs.
+func publishOnce(
+	ctx context.Context,
+	db db.DB,
+	key string,
+	expiry time.Duration,
+	name string,
+	publish func() error,
+) error {
+	l := logutils.LoggerFromContext(ctx)
+
+	isDup, err := checkDupAndLock(ctx, db, key)
+	if isDup {
+		// Enter this branch even with non-nil err;
+		// Only for ErrAlreadyLocked, so that lambda execution will be restarted
+		l.Info("Duplicate alert detected", zap.Error(err))
+		return err
+	}
+	// not a duplicate
+	if err != nil {
+		l.Error("Failed to check for duplicate alert, publishing to "+name, zap.Error(err))
+	}
+
+	if err := publish(); err != nil {
+		l.Error("Failed to publish alert to "+name, zap.Error(err))
+		releaseLock(ctx, db, key)
+		return err
+	}
+
+	// sent correctly, prevent other instances from sending
+	_ = db.Set(ctx, key, expiry, "1")
+
+	return nil
+}
+
+// releaseLock releases the lock taken by checkDupAndLock when publishing has
+// failed, so that the retries are not mistaken for duplicates.
+func releaseLock(ctx context.Context, db db.DB, key string) {
+	if err := db.Unlock(ctx, key); err != nil {
+		logutils.LoggerFromContext(ctx).Warn("Failed to release the lock",
+			zap.Error(err),
+			zap.String("key", key),
+		)
+	}
+}
+
+// ContextWithRawMessage returns the context that carries the original
+// alertmanager message (as it was before the processing) that the published
+// alerts came with.
+func ContextWithRawMessage(ctx context.Context, raw json.RawMessage) context.Context {
+	return context.WithValue(ctx, rawMessageContextKey{}, raw)
+}
+
+// rawMessageFromContext returns the original alertmanager message (or nil, if
+// there is none).
+func rawMessageFromContext(ctx context.Context) json.RawMessage {
+	raw, _ := ctx.Value(rawMessageContextKey{}).(json.RawMessage)
+	return raw
+}
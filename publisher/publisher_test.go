@@ -0,0 +1,37 @@
+package publisher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishOnce(t *testing.T) {
+	db := newMemoryDB(t)
+	ctx := context.Background()
+
+	calls := 0
+	publish := func(err error) func() error {
+		return func() error {
+			calls++
+			return err
+		}
+	}
+
+	// failed attempt releases the lock, so that the retry goes through
+	assert.ErrorIs(t, publishOnce(ctx, db, "testKey", timeoutLock, "test", publish(assert.AnError)), assert.AnError)
+	assert.NoError(t, publishOnce(ctx, db, "testKey", timeoutLock, "test", publish(nil)))
+	assert.Equal(t, 2, calls)
+
+	// once sent, it's a duplicate
+	assert.NoError(t, publishOnce(ctx, db, "testKey", timeoutLock, "test", publish(nil)))
+	assert.Equal(t, 2, calls)
+
+	// while another instance holds the lock, it's to be retried
+	locked, err := db.Lock(ctx, "otherKey", timeoutLock)
+	assert.NoError(t, err)
+	assert.True(t, locked)
+	assert.ErrorIs(t, publishOnce(ctx, db, "otherKey", timeoutLock, "test", publish(nil)), ErrAlreadyLocked)
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,230 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/PagerDuty/go-pagerduty"
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/logutils"
+	"github.com/flashbots/amp-alerts-sink/types"
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+)
+
+type retrying struct {
+	name       string
+	publisher  Publisher
+	deadLetter DeadLetter
+
+	initialBackoff time.Duration
+	maxAttempts    int
+	maxBackoff     time.Duration
+
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// retryAfterError is the error that carries destination's hint on when it's
+// ok to retry (e.g. from http Retry-After header).
+type retryAfterError struct {
+	err   error
+	after time.Duration
+}
+
+type attemptContextKey struct{}
+
+type attempt struct {
+	number int
+	max    int
+}
+
+const (
+	defaultRetryInitialBackoff = 500 * time.Millisecond
+	defaultRetryMaxBackoff     = 10 * time.Second
+)
+
+// NewRetrying wraps the publisher so that failed publishes are retried with
+// exponential backoff, and the alerts that exhaust the retries are sent to the
+// dead-letter sink (if there's one).
+func NewRetrying(name string, cfg *config.Retry, publisher Publisher, deadLetter DeadLetter) Publisher {
+	initialBackoff := cfg.InitialBackoff
+	if initialBackoff <= 0 {
+		initialBackoff = defaultRetryInitialBackoff
+	}
+	maxBackoff := cfg.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryMaxBackoff
+	}
+	maxAttempts := max(cfg.MaxAttempts, 1)
+
+	return &retrying{
+		name:       name,
+		publisher:  publisher,
+		deadLetter: deadLetter,
+
+		initialBackoff: initialBackoff,
+		maxAttempts:    maxAttempts,
+		maxBackoff:     maxBackoff,
+
+		sleep: sleep,
+	}
+}
+
+func (r *retrying) Publish(
+	ctx context.Context,
+	source string,
+	alert *types.AlertmanagerAlert,
+) error {
+	l := logutils.LoggerFromContext(ctx)
+
+	var err error
+	for n := 1; n <= r.maxAttempts; n++ {
+		err = r.publisher.Publish(
+			context.WithValue(ctx, attemptContextKey{}, attempt{number: n, max: r.maxAttempts}),
+			source,
+			alert,
+		)
+		if err == nil || n == r.maxAttempts || !isRetriable(err) {
+			break
+		}
+
+		delay := r.backoff(n)
+		if after := retryAfter(err); after > 0 {
+			delay = after
+		}
+
+		// don't wait for longer than allowed (or than there's time left)
+		if !r.canWait(ctx, delay) {
+			l.Warn("Failed to publish the alert, and can not wait to retry",
+				zap.Error(err),
+				zap.String("publisher", r.name),
+				zap.Int("attempt", n),
+				zap.Duration("delay", delay),
+			)
+			break
+		}
+
+		l.Warn("Failed to publish the alert, will retry",
+			zap.Error(err),
+			zap.String("publisher", r.name),
+			zap.Int("attempt", n),
+			zap.Duration("delay", delay),
+		)
+
+		if errSleep := r.sleep(ctx, delay); errSleep != nil {
+			break
+		}
+	}
+
+	if err == nil || r.deadLetter == nil {
+		return err
+	}
+	if errors.Is(err, ErrAlreadyLocked) {
+		// another instance is publishing the alert, and if it fails the alert
+		// must come back to us (and not get parked in the dead-letter sink)
+		return err
+	}
+
+	if errDeadLetter := r.deadLetter.Send(ctx, r.name, source, alert, err); errDeadLetter != nil {
+		l.Error("Failed to send the alert to dead-letter sink",
+			zap.Error(errDeadLetter),
+			zap.String("publisher", r.name),
+		)
+		return errors.Join(err, errDeadLetter)
+	}
+
+	// the alert is parked in the dead-letter sink, no need for the caller to
+	// re-deliver it again
+	l.Error("Sent the alert to dead-letter sink after exhausting retries",
+		zap.Error(err),
+		zap.String("publisher", r.name),
+		zap.Any("alert", alert),
+	)
+	return nil
+}
+
+// backoff returns exponential backoff with jitter for the n-th attempt.
+func (r *retrying) backoff(n int) time.Duration {
+	delay := r.maxBackoff
+	if shift := n - 1; shift < 32 && r.initialBackoff<<shift < r.maxBackoff {
+		delay = r.initialBackoff << shift
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// canWait returns true if the delay is within max backoff, and the retry will
+// still happen before the context's deadline.
+func (r *retrying) canWait(ctx context.Context, delay time.Duration) bool {
+	if delay > r.maxBackoff {
+		return false
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+		return false
+	}
+	return true
+}
+
+func (e *retryAfterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
+
+// isRetriable returns false for the errors that won't go away on retry (or
+// that must be handled by the caller's re-delivery, as with the alerts that
+// another instance is busy publishing).
+func isRetriable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, ErrAlreadyLocked) {
+		return false
+	}
+
+	var pdErr pagerduty.EventsAPIV2Error
+	if errors.As(err, &pdErr) && pdErr.BadRequest() {
+		return false
+	}
+
+	return true
+}
+
+// retryAfter returns the delay that destination asked to wait before retry
+// (or 0, if there's no such hint in the error).
+func retryAfter(err error) time.Duration {
+	var raErr *retryAfterError
+	if errors.As(err, &raErr) {
+		return raErr.after
+	}
+
+	var slackErr *slack.RateLimitedError
+	if errors.As(err, &slackErr) {
+		return slackErr.RetryAfter
+	}
+
+	return 0
+}
+
+// isLastAttempt returns true unless the publisher was invoked by retrying
+// wrapper that is going to make more attempts.
+func isLastAttempt(ctx context.Context) bool {
+	a, ok := ctx.Value(attemptContextKey{}).(attempt)
+	return !ok || a.number >= a.max
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
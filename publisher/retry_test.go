@@ -0,0 +1,232 @@
+package publisher
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/types"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+)
+
+type flakyPublisher struct {
+	errs     []error
+	attempts int
+	last     []bool
+}
+
+func (f *flakyPublisher) Publish(ctx context.Context, _ string, _ *types.AlertmanagerAlert) error {
+	f.attempts++
+	f.last = append(f.last, isLastAttempt(ctx))
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+type recordingDeadLetter struct {
+	causes []error
+}
+
+func (r *recordingDeadLetter) Send(
+	_ context.Context,
+	_, _ string,
+	_ *types.AlertmanagerAlert,
+	cause error,
+) error {
+	r.causes = append(r.causes, cause)
+	return nil
+}
+
+func setupRetryingPublisher(
+	pub Publisher, deadLetter DeadLetter, maxAttempts int,
+) (*retrying, *[]time.Duration) {
+	r := NewRetrying("test", &config.Retry{
+		InitialBackoff: time.Second,
+		MaxAttempts:    maxAttempts,
+		MaxBackoff:     4 * time.Second,
+	}, pub, deadLetter).(*retrying)
+
+	delays := &[]time.Duration{}
+	r.sleep = func(_ context.Context, d time.Duration) error {
+		*delays = append(*delays, d)
+		return nil
+	}
+
+	return r, delays
+}
+
+func TestRetryEventuallySucceeds(t *testing.T) {
+	pub := &flakyPublisher{errs: []error{assert.AnError, assert.AnError}}
+	r, delays := setupRetryingPublisher(pub, nil, 5)
+
+	err := r.Publish(context.Background(), "testSource", alertFiring)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, pub.attempts)
+	assert.Equal(t, []bool{false, false, false}, pub.last)
+	assert.Len(t, *delays, 2)
+	assert.True(t, (*delays)[0] >= 500*time.Millisecond && (*delays)[0] <= time.Second)
+	assert.True(t, (*delays)[1] >= time.Second && (*delays)[1] <= 2*time.Second)
+}
+
+func TestRetryAfterFailedPublishIsNotDuplicate(t *testing.T) {
+	statuses := []int{http.StatusInternalServerError, http.StatusOK}
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statuses[min(requests, len(statuses)-1)])
+		requests++
+	}))
+	defer srv.Close()
+
+	wh, err := NewWebhook(&config.Webhook{URL: srv.URL, SendBody: true}, newMemoryDB(t))
+	assert.NoError(t, err)
+
+	deadLetter := &recordingDeadLetter{}
+	r, _ := setupRetryingPublisher(wh, deadLetter, 3)
+
+	// the lock taken by the failed attempt must not block the retry
+	assert.NoError(t, r.Publish(context.Background(), "testSource", alertFiring))
+	assert.Equal(t, 2, requests)
+	assert.Empty(t, deadLetter.causes)
+
+	// once delivered, it's a duplicate indeed
+	assert.NoError(t, r.Publish(context.Background(), "testSource", alertFiring))
+	assert.Equal(t, 2, requests)
+}
+
+func TestRetryBackoffIsCapped(t *testing.T) {
+	r, _ := setupRetryingPublisher(&flakyPublisher{}, nil, 10)
+
+	for n := 1; n <= 10; n++ {
+		assert.LessOrEqual(t, r.backoff(n), 4*time.Second)
+	}
+	assert.GreaterOrEqual(t, r.backoff(10), 2*time.Second)
+}
+
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	pub := &flakyPublisher{errs: []error{
+		&slack.RateLimitedError{RetryAfter: 4 * time.Second},
+		&retryAfterError{err: assert.AnError, after: 3 * time.Second},
+	}}
+	r, delays := setupRetryingPublisher(pub, nil, 3)
+
+	err := r.Publish(context.Background(), "testSource", alertFiring)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Duration{4 * time.Second, 3 * time.Second}, *delays)
+}
+
+func TestRetryAfterBeyondMaxBackoffGoesToDeadLetter(t *testing.T) {
+	pub := &flakyPublisher{errs: []error{
+		&retryAfterError{err: assert.AnError, after: time.Hour},
+	}}
+	deadLetter := &recordingDeadLetter{}
+	r, delays := setupRetryingPublisher(pub, deadLetter, 3)
+
+	err := r.Publish(context.Background(), "testSource", alertFiring)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pub.attempts)
+	assert.Empty(t, *delays)
+	assert.Len(t, deadLetter.causes, 1)
+}
+
+func TestRetryAfterBeyondDeadlineGoesToDeadLetter(t *testing.T) {
+	pub := &flakyPublisher{errs: []error{
+		&retryAfterError{err: assert.AnError, after: 3 * time.Second},
+	}}
+	deadLetter := &recordingDeadLetter{}
+	r, delays := setupRetryingPublisher(pub, deadLetter, 3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err := r.Publish(ctx, "testSource", alertFiring)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pub.attempts)
+	assert.Empty(t, *delays)
+	assert.Len(t, deadLetter.causes, 1)
+}
+
+func TestRetryExhaustedGoesToDeadLetter(t *testing.T) {
+	pub := &flakyPublisher{errs: []error{assert.AnError, assert.AnError, assert.AnError}}
+	deadLetter := &recordingDeadLetter{}
+	r, _ := setupRetryingPublisher(pub, deadLetter, 3)
+
+	err := r.Publish(context.Background(), "testSource", alertFiring)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, pub.attempts)
+	assert.Equal(t, []bool{false, false, true}, pub.last)
+	assert.Equal(t, []error{assert.AnError}, deadLetter.causes)
+}
+
+func TestRetryExhaustedWithoutDeadLetter(t *testing.T) {
+	pub := &flakyPublisher{errs: []error{assert.AnError, assert.AnError}}
+	r, _ := setupRetryingPublisher(pub, nil, 2)
+
+	err := r.Publish(context.Background(), "testSource", alertFiring)
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, 2, pub.attempts)
+}
+
+func TestRetryAlreadyLockedIsNotDeadLettered(t *testing.T) {
+	pub := &flakyPublisher{errs: []error{ErrAlreadyLocked}}
+	deadLetter := &recordingDeadLetter{}
+	r, delays := setupRetryingPublisher(pub, deadLetter, 3)
+
+	// another instance is publishing, so the alert must be re-delivered to us
+	// (in case that instance fails)
+	err := r.Publish(context.Background(), "testSource", alertFiring)
+	assert.ErrorIs(t, err, ErrAlreadyLocked)
+	assert.Equal(t, 1, pub.attempts)
+	assert.Empty(t, *delays)
+	assert.Empty(t, deadLetter.causes)
+}
+
+func TestRetryStopsOnCancelledContext(t *testing.T) {
+	pub := &flakyPublisher{errs: []error{context.Canceled}}
+	r, delays := setupRetryingPublisher(pub, nil, 3)
+
+	err := r.Publish(context.Background(), "testSource", alertFiring)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, 1, pub.attempts)
+	assert.Empty(t, *delays)
+}
+
+func TestDeadLetterFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead-letter.jsonl")
+	deadLetter, err := NewDeadLetter(&config.DeadLetter{File: path})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, deadLetter.Send(ctx, "slack-testChannelID", "testSource", alertFiring, assert.AnError))
+	assert.NoError(t, deadLetter.Send(ctx, "webhook", "testSource", alertResolved, assert.AnError))
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	records := []deadLetterRecord{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		record := deadLetterRecord{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+
+	assert.Len(t, records, 2)
+	assert.Equal(t, "slack-testChannelID", records[0].Publisher)
+	assert.Equal(t, "testSource", records[0].Source)
+	assert.Equal(t, assert.AnError.Error(), records[0].Error)
+	assert.Equal(t, "firing", records[0].Alert.Status)
+	assert.Equal(t, "resolved", records[1].Alert.Status)
+}
@@ -14,22 +14,34 @@ import (
 	"github.com/flashbots/amp-alerts-sink/config"
 	"github.com/flashbots/amp-alerts-sink/db"
 	"github.com/flashbots/amp-alerts-sink/logutils"
+	"github.com/flashbots/amp-alerts-sink/matcher"
+	"github.com/flashbots/amp-alerts-sink/template"
 	"github.com/flashbots/amp-alerts-sink/types"
 	"github.com/slack-go/slack"
 	"go.uber.org/zap"
 )
 
 type slackChannel struct {
-	channelID string
-
-	cli slackApi
-	db  db.DB
+	channelID       string
+	format          string
+	interactive     bool
+	matchLabels     matcher.Matchers
+	mentions        []*slackMention
+	updateOnResolve bool
+
+	group   bool
+	groupBy []string
+
+	cli       slackApi
+	db        db.DB
+	templates *slackTemplates
 }
 
 type slackApi interface {
 	AddReaction(name string, item slack.ItemRef) error
 	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
 	RemoveReaction(name string, item slack.ItemRef) error
+	UpdateMessage(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error)
 }
 
 var (
@@ -37,11 +49,35 @@ var (
 )
 
 func NewSlackChannel(cfg *config.Slack, db db.DB) (Publisher, error) {
-	return &slackChannel{
-		channelID: cfg.Channel.ID,
+	matchLabels, err := cfg.Channel.MatchLabelsMatchers()
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := newSlackTemplates(cfg.Templates, cfg.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	mentions, err := newSlackMentions(cfg.Mentions)
+	if err != nil {
+		return nil, err
+	}
 
-		cli: slack.New(cfg.Token),
-		db:  db,
+	return &slackChannel{
+		channelID:       cfg.Channel.ID,
+		format:          cfg.Format,
+		interactive:     cfg.InteractiveEnabled(),
+		matchLabels:     matchLabels,
+		mentions:        mentions,
+		updateOnResolve: cfg.UpdateOnResolve,
+
+		group:   cfg.Group,
+		groupBy: cfg.GroupBy,
+
+		cli:       slack.New(cfg.Token),
+		db:        db,
+		templates: templates,
 	}, nil
 }
 
@@ -52,6 +88,15 @@ func (s *slackChannel) Publish(
 ) (err error) {
 	l := logutils.LoggerFromContext(ctx)
 
+	// skip alerts that are not meant for this channel
+	if mismatch := s.matchLabels.Mismatch(alert.Labels); mismatch != nil {
+		l.Debug("Skipped the alert due to slack channel's label mismatch",
+			zap.String("slack_channel_id", s.channelID),
+			zap.String("matcher", mismatch.String()),
+		)
+		return nil
+	}
+
 	dbKeyThreadTS := source + "/" + s.channelID + "/" + alert.IncidentDedupKey()
 	dbKeyMessageTS := source + "/" + s.channelID + "/" + alert.MessageDedupKey()
 
@@ -59,14 +104,19 @@ func (s *slackChannel) Publish(
 
 	// whatever the issues with DB we will try to publish to slack at least once
 	alreadyPublished := false
-	message := s.newMessage(alert)
+	didLock := false
+	data := template.NewData(source, alert)
+	ackedBy := s.ackedBy(ctx, dbKeyThreadTS)
+	message := s.newMessage(ctx, data, ackedBy)
 	defer func() {
 		if !alreadyPublished {
-			_, err2 := s.publishMessage(ctx, message, threadTS)
+			_, err2 := s.publishMessage(ctx, data, message, threadTS)
 			if err2 == nil {
 				l.Warn("Emergency-published the alert to slack",
 					zap.Any("alert", alert),
 				)
+			} else if didLock {
+				releaseLock(ctx, s.db, dbKeyMessageTS)
 			}
 			err = errors.Join(err, err2)
 		}
@@ -85,21 +135,44 @@ func (s *slackChannel) Publish(
 	}
 
 	// try to lock the db
-	didLock, err := s.db.Lock(ctx, dbKeyMessageTS, timeoutLock)
+	lockTimeout := timeoutLock
+	if s.group {
+		lockTimeout = timeoutSlackGroupedMessageLock
+	}
+	didLock, err = s.db.Lock(ctx, dbKeyMessageTS, lockTimeout)
 	if !didLock && err == nil {
 		// another grafana's HA instance is about to publish
 		alreadyPublished = true
 		return ErrAlreadyLocked
 	}
 
-	// check if this is a follow-up message
-	threadTS, err = s.db.Get(ctx, dbKeyThreadTS)
-	if err != nil {
-		return err
+	// check if this is a follow-up message (in grouping mode all alerts of the
+	// group are follow-ups to group's summary message)
+	var group *slackGroup
+	finishGroup := func(bool) {}
+	if groupLabels := s.groupLabels(alert); len(groupLabels) > 0 {
+		group, finishGroup, err = s.updateGroup(ctx, source, groupLabels, alert)
+		if errors.Is(err, ErrAlreadyLocked) {
+			// another instance is busy updating the group, let the alert be
+			// retried later (instead of emergency-publishing it)
+			alreadyPublished = true
+			releaseLock(ctx, s.db, dbKeyMessageTS)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		threadTS = group.ThreadTS
+	} else {
+		threadTS, err = s.db.Get(ctx, dbKeyThreadTS)
+		if err != nil {
+			return err
+		}
 	}
 
 	// send message to slack
-	messageTS, err = s.publishMessage(ctx, message, threadTS)
+	messageTS, err = s.publishMessage(ctx, data, message, threadTS)
+	finishGroup(err == nil)
 	if err != nil {
 		return err
 	}
@@ -111,6 +184,14 @@ func (s *slackChannel) Publish(
 	// make sure we don't re-publish it from another HA instance
 	_ = s.db.Set(ctx, dbKeyMessageTS, timeoutThreadExpiry, messageTS)
 
+	if group != nil {
+		// reaction emoji on the summary reflects the status of the whole group
+		if len(threadTS) > 0 {
+			s.updateReaction(ctx, group.status(), threadTS)
+		}
+		return nil
+	}
+
 	if len(threadTS) == 0 {
 		// set thread's timestamp to be the same as the timestamp of its first message
 		threadTS = messageTS
@@ -119,73 +200,51 @@ func (s *slackChannel) Publish(
 
 	// update reaction emoji on the thread-starting message
 	if len(threadTS) > 0 {
-		s.updateReaction(ctx, alert, threadTS)
+		s.updateReaction(ctx, alert.Status, threadTS)
+	}
+
+	// reflect the resolution in the thread-starting message as well
+	if s.updateOnResolve && alert.Status == "resolved" && threadTS != messageTS {
+		s.updateThreadMessage(ctx, data, ackedBy, threadTS)
 	}
 
 	return nil
 }
 
+// newMessage renders the alert (with the note about its acknowledgement, if
+// it was acknowledged via slack).
 func (s *slackChannel) newMessage(
-	alert *types.AlertmanagerAlert,
+	ctx context.Context,
+	data *template.Data,
+	ackedBy string,
 ) slack.Attachment {
-	msg := slack.Attachment{}
-
-	if alert.Status == "firing" {
-		switch alert.Labels["severity"] {
-		case "critical":
-			msg.Color = "danger"
-		case "warning":
-			msg.Color = "warning"
-		default:
-			msg.Color = "good"
+	tmplData := &slackTemplateData{Data: data}
+
+	if s.format == config.SlackFormatBlocks {
+		msg := s.newBlocksMessage(ctx, tmplData, ackedBy != "")
+		if ackedBy != "" {
+			msg.Blocks = appendSlackFooterBlock(msg.Blocks, slackAckNote(ackedBy))
 		}
-	} else {
-		msg.Color = "good"
+		return msg
 	}
 
-	msg.Title = fmt.Sprintf("%s: %s",
-		strings.ToUpper(alert.Status),
-		alert.Labels["alertname"],
-	)
-
-	if alertSeverity, ok := alert.Labels["severity"]; ok {
-		msg.Text += fmt.Sprintf("Severity: `%s`\n", alertSeverity)
-	}
-	if alertSummary, ok := alert.Annotations["summary"]; ok {
-		msg.Text += fmt.Sprintf("Summary: `%s`\n", alertSummary)
-	}
-	if alertDescription, ok := alert.Annotations["description"]; ok {
-		msg.Text += fmt.Sprintf("\n%s\n\n", alertDescription)
-	}
-	if alertMessage, ok := alert.Annotations["message"]; ok {
-		msg.Text += fmt.Sprintf("\n%s\n\n", alertMessage)
-	}
-	if len(alert.StartsAt) > 0 {
-		msg.Text += fmt.Sprintf("Started at: `%s`\n", alert.StartsAt)
-	}
-	if awsAccount, ok := alert.Labels["aws_account"]; ok {
-		msg.Text += fmt.Sprintf("AWS account: `%s`\n", awsAccount)
-	}
-	if cluster, ok := alert.Labels["cluster"]; ok {
-		msg.Text += fmt.Sprintf("Kubernetes cluster: `%s`\n", cluster)
-	}
-	if namespace, ok := alert.Labels["namespace"]; ok {
-		msg.Text += fmt.Sprintf("Kubernetes namespace: `%s`\n", namespace)
+	msg := slack.Attachment{
+		Color: strings.TrimSpace(renderSlackTemplate(ctx,
+			s.templates.color, slackDefaultTemplates.color, tmplData,
+		)),
+		Title: strings.TrimSpace(renderSlackTemplate(ctx,
+			s.templates.title, slackDefaultTemplates.title, tmplData,
+		)),
+		Text: renderSlackTemplate(ctx,
+			s.templates.text, slackDefaultTemplates.text, tmplData,
+		),
 	}
 
-	var links []string
-	addLink := func(href, text string) {
-		if href == "" {
-			return
-		}
-		links = append(links, fmt.Sprintf("<%s|%s>", href, text))
+	if ackedBy != "" {
+		msg.Text += "\n" + slackAckNote(ackedBy)
 	}
-	addLink(alert.Annotations["runbook_url"], "📕 Runbook")
-	addLink(alert.GeneratorURL, "📈 Expr")
-	addLink(alert.SilenceURL, "🔕 Silence")
-
-	if len(links) > 0 {
-		msg.Text += fmt.Sprintf("\n%s\n", strings.Join(links, " | "))
+	if s.interactive && data.Status == "firing" {
+		s.addAttachmentActions(&msg, data, ackedBy != "")
 	}
 
 	return msg
@@ -193,20 +252,26 @@ func (s *slackChannel) newMessage(
 
 func (s *slackChannel) publishMessage(
 	ctx context.Context,
+	data *template.Data,
 	message slack.Attachment,
 	threadTS string,
 ) (string, error) {
 	l := logutils.LoggerFromContext(ctx)
 
 	if len(threadTS) > 0 {
+		tmplData := &slackTemplateData{Data: data, FollowUp: true}
 		if floatThreadTS, err := strconv.ParseFloat(threadTS, 64); err == nil {
 			sec, dec := math.Modf(floatThreadTS)
 			timeSlackThreadTS := time.Unix(int64(sec), int64(dec*(1e9)))
-			message.Footer = fmt.Sprintf("(follow-up to the alert published at %s)",
-				timeSlackThreadTS.Format("2006-01-02T15:04:05Z07:00"),
-			)
+			tmplData.FollowUpTo = timeSlackThreadTS.Format("2006-01-02T15:04:05Z07:00")
+		}
+		footer := strings.TrimSpace(renderSlackTemplate(ctx,
+			s.templates.footer, slackDefaultTemplates.footer, tmplData,
+		))
+		if s.format == config.SlackFormatBlocks {
+			message.Blocks = appendSlackFooterBlock(message.Blocks, footer)
 		} else {
-			message.Footer = "(follow-up)"
+			message.Footer = footer
 		}
 	}
 
@@ -218,6 +283,12 @@ func (s *slackChannel) publishMessage(
 			slack.MsgOptionTS(threadTS),
 		)
 	}
+	if mentions := s.mentionsFor(data.AlertmanagerAlert, threadTS); mentions != "" {
+		// mentions only notify when they are in the text of the message
+		opts = append(opts,
+			slack.MsgOptionText(mentions, false),
+		)
+	}
 
 	_, messageTS, err := s.cli.PostMessage(s.channelID, opts...)
 	if err != nil {
@@ -233,15 +304,58 @@ func (s *slackChannel) publishMessage(
 	return messageTS, nil
 }
 
+func (s *slackChannel) updateThreadMessage(
+	ctx context.Context,
+	data *template.Data,
+	ackedBy string,
+	threadTS string,
+) {
+	l := logutils.LoggerFromContext(ctx)
+
+	message := s.newMessage(ctx, data, ackedBy)
+	note := slackResolvedNote(data.AlertmanagerAlert)
+	if s.format == config.SlackFormatBlocks {
+		message.Blocks = appendSlackFooterBlock(message.Blocks, note)
+	} else {
+		message.Text += "\n" + note + "\n"
+	}
+
+	if _, _, _, err := s.cli.UpdateMessage(s.channelID, threadTS,
+		slack.MsgOptionAttachments(message),
+	); err != nil {
+		l.Error("Error updating thread-starting message in slack",
+			zap.Error(err),
+			zap.String("slack_channel_id", s.channelID),
+			zap.String("slack_thread_ts", threadTS),
+		)
+	}
+}
+
+// slackResolvedNote returns the line with the time of resolution (and with
+// the duration of the alert, if it's known).
+func slackResolvedNote(alert *types.AlertmanagerAlert) string {
+	endsAt, err := time.Parse(time.RFC3339, alert.EndsAt)
+	if err != nil || endsAt.Year() <= 1 {
+		endsAt = time.Now()
+	}
+
+	note := fmt.Sprintf("Resolved at: `%s`", endsAt.UTC().Format(time.RFC3339))
+	if startsAt, err := time.Parse(time.RFC3339, alert.StartsAt); err == nil && endsAt.After(startsAt) {
+		note += fmt.Sprintf(" (after `%s`)", endsAt.Sub(startsAt).Round(time.Second))
+	}
+
+	return note
+}
+
 func (s *slackChannel) updateReaction(
 	ctx context.Context,
-	alert *types.AlertmanagerAlert,
+	status string,
 	threadTS string,
 ) {
 	l := logutils.LoggerFromContext(ctx)
 
 	var ra, rr string
-	switch alert.Status {
+	switch status {
 	case "firing":
 		ra = "rotating_light"
 		rr = "white_check_mark"
@@ -0,0 +1,119 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	slackMaxButtonText       = 75
+	slackMaxHeaderText       = 150
+	slackMaxSectionFields    = 10
+	slackMaxSectionText      = 3000
+	slackMaxSectionFieldText = 2000
+)
+
+// newBlocksMessage renders the alert as block kit blocks.  The blocks are
+// still wrapped into an attachment, so that the colour bar is retained.
+func (s *slackChannel) newBlocksMessage(
+	ctx context.Context,
+	data *slackTemplateData,
+	acked bool,
+) slack.Attachment {
+	blocks := make([]slack.Block, 0, 8)
+
+	// header
+	title := strings.TrimSpace(renderSlackTemplate(ctx,
+		s.templates.title, slackDefaultTemplates.title, data,
+	))
+	if title != "" {
+		blocks = append(blocks, slack.NewHeaderBlock(
+			slack.NewTextBlockObject(slack.PlainTextType, truncate(title, slackMaxHeaderText), true, false),
+		))
+	}
+
+	// text
+	text := strings.TrimSpace(renderSlackTemplate(ctx,
+		s.templates.text, slackDefaultBlocksText, data,
+	))
+	if text != "" {
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, truncate(text, slackMaxSectionText), false, false),
+			nil, nil,
+		))
+	}
+
+	// labels
+	names := make([]string, 0, len(data.Labels))
+	for name := range data.Labels {
+		if name == "alertname" {
+			continue // it's already in the header
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for chunk := range slices.Chunk(names, slackMaxSectionFields) {
+		fields := make([]*slack.TextBlockObject, 0, len(chunk))
+		for _, name := range chunk {
+			fields = append(fields, slack.NewTextBlockObject(slack.MarkdownType,
+				truncate(fmt.Sprintf("*%s*\n`%s`", name, data.Labels[name]), slackMaxSectionFieldText),
+				false, false,
+			))
+		}
+		blocks = append(blocks, slack.NewSectionBlock(nil, fields, nil))
+	}
+
+	// timestamps
+	if data.StartsAt != "" {
+		blocks = append(blocks, slack.NewContextBlock("",
+			slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("Started at: `%s`", data.StartsAt), false, false),
+		))
+	}
+
+	// links
+	if len(data.Links) > 0 {
+		buttons := make([]slack.BlockElement, 0, len(data.Links))
+		for idx, link := range data.Links {
+			button := slack.NewButtonBlockElement(
+				fmt.Sprintf("link-%d", idx),
+				"",
+				slack.NewTextBlockObject(slack.PlainTextType, truncate(link.Text, slackMaxButtonText), true, false),
+			)
+			button.URL = link.Href
+			buttons = append(buttons, button)
+		}
+		blocks = append(blocks, slack.NewActionBlock("", buttons...))
+	}
+
+	// interactive buttons
+	if s.interactive && data.Status == "firing" {
+		if actions := s.newActionsBlock(data.Data, acked); actions != nil {
+			blocks = append(blocks, actions)
+		}
+	}
+
+	return slack.Attachment{
+		Color: strings.TrimSpace(renderSlackTemplate(ctx,
+			s.templates.color, slackDefaultTemplates.color, data,
+		)),
+		Fallback: title,
+		Blocks:   slack.Blocks{BlockSet: blocks},
+	}
+}
+
+// appendSlackFooterBlock returns the copy of the blocks with the footer added
+// as the trailing context block.
+func appendSlackFooterBlock(blocks slack.Blocks, footer string) slack.Blocks {
+	if footer == "" {
+		return blocks
+	}
+	return slack.Blocks{BlockSet: append(slices.Clone(blocks.BlockSet),
+		slack.NewContextBlock("",
+			slack.NewTextBlockObject(slack.MarkdownType, footer, false, false),
+		),
+	)}
+}
@@ -0,0 +1,288 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/logutils"
+	"github.com/flashbots/amp-alerts-sink/template"
+	"github.com/flashbots/amp-alerts-sink/types"
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+)
+
+const (
+	slackMaxGroupInstances = 30
+
+	// slackGroupLockAttempts and slackGroupLockWait define for how long to
+	// wait for another instance to finish updating the group
+	slackGroupLockAttempts = 10
+	slackGroupLockWait     = 200 * time.Millisecond
+
+	// timeoutSlackGroupedMessageLock outlasts the wait for the group lock and
+	// the updates of the group that follow, so that the lock of the message
+	// does not expire while the alert is still being published
+	timeoutSlackGroupedMessageLock = slackGroupLockAttempts*slackGroupLockWait + timeoutSlackGroupLock
+)
+
+// slackGroup is the state of the group of alerts that is kept in the db.
+type slackGroup struct {
+	// ThreadTS is the timestamp of group's summary message
+	ThreadTS string `json:"ts"`
+
+	// Firing maps incident keys of group's firing alerts onto their
+	// descriptions
+	Firing map[string]string `json:"firing"`
+}
+
+// groupLabels returns the labels that define the group of the alert (or nil,
+// if grouping is disabled or the alert does not belong to any group).
+func (s *slackChannel) groupLabels(alert *types.AlertmanagerAlert) map[string]string {
+	if !s.group {
+		return nil
+	}
+	if len(s.groupBy) == 0 {
+		return alert.GroupLabels
+	}
+
+	res := make(map[string]string, len(s.groupBy))
+	for _, name := range s.groupBy {
+		if value, ok := alert.Labels[name]; ok {
+			res[name] = value
+		}
+	}
+	return res
+}
+
+// updateGroup records the alert in the state of its group and posts (or
+// updates) the group's summary message.  The group that has no summary yet
+// does not get one until it has firing alerts (so its thread timestamp stays
+// empty till then).
+//
+// The group stays locked until the returned func is called with the outcome
+// of publishing the alert itself: the updated state is only saved once the
+// alert is published (otherwise just the timestamp of the new summary is
+// kept, so that the retry threads under it).
+func (s *slackChannel) updateGroup(
+	ctx context.Context,
+	source string,
+	groupLabels map[string]string,
+	alert *types.AlertmanagerAlert,
+) (*slackGroup, func(published bool), error) {
+	l := logutils.LoggerFromContext(ctx)
+
+	// the fingerprint of an alert with just the group labels is the
+	// fingerprint of the group
+	groupKey := types.AlertmanagerAlert{Labels: groupLabels}.IncidentDedupKey()
+	dbKeyGroup := source + "/" + s.channelID + "/group/" + groupKey
+
+	// the state is read, modified, and written back, so concurrent updates
+	// (from other alerts of the group, or from HA instances) must wait
+	unlock, err := s.lockGroup(ctx, dbKeyGroup)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	raw, err := s.db.Get(ctx, dbKeyGroup)
+	if err != nil {
+		unlock()
+		return nil, nil, err
+	}
+
+	prev := &slackGroup{}
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), prev); err != nil {
+			l.Warn("Failed to parse the state of slack group, starting over",
+				zap.Error(err),
+				zap.String("group_key", groupKey),
+			)
+			prev = &slackGroup{}
+		}
+	}
+
+	group := &slackGroup{
+		ThreadTS: prev.ThreadTS,
+		Firing:   maps.Clone(prev.Firing),
+	}
+	if group.Firing == nil {
+		group.Firing = make(map[string]string)
+	}
+
+	switch alert.Status {
+	case "firing":
+		group.Firing[alert.IncidentDedupKey()] = describeGroupInstance(alert, groupLabels)
+	case "resolved":
+		delete(group.Firing, alert.IncidentDedupKey())
+	}
+
+	finish := func(published bool) {
+		defer unlock()
+
+		// once all alerts of the group are resolved, the next one starts anew
+		state := group
+		switch {
+		case !published:
+			state = &slackGroup{ThreadTS: group.ThreadTS, Firing: prev.Firing}
+		case len(group.Firing) == 0:
+			state = &slackGroup{}
+		}
+		if value, err := json.Marshal(state); err == nil {
+			_ = s.db.Set(ctx, dbKeyGroup, timeoutThreadExpiry, string(value))
+		}
+	}
+
+	if group.ThreadTS == "" && len(group.Firing) == 0 {
+		// the group never fired (e.g. its alerts fired before the grouping
+		// was enabled), so there's nothing to summarise
+		l.Debug("Skipped summary of slack group without firing alerts",
+			zap.String("group_key", groupKey),
+		)
+		return group, finish, nil
+	}
+
+	summary := s.newGroupMessage(ctx, source, groupLabels, group)
+	if group.ThreadTS == "" {
+		opts := []slack.MsgOption{
+			slack.MsgOptionAttachments(summary),
+		}
+		if mentions := s.mentionsFor(alert, ""); mentions != "" {
+			opts = append(opts,
+				slack.MsgOptionText(mentions, false),
+			)
+		}
+		_, ts, err := s.cli.PostMessage(s.channelID, opts...)
+		if err != nil {
+			unlock()
+			return nil, nil, err
+		}
+		group.ThreadTS = ts
+	} else if _, _, _, err := s.cli.UpdateMessage(s.channelID, group.ThreadTS,
+		slack.MsgOptionAttachments(summary),
+	); err != nil {
+		l.Error("Error updating group summary message in slack",
+			zap.Error(err),
+			zap.String("slack_channel_id", s.channelID),
+			zap.String("slack_thread_ts", group.ThreadTS),
+		)
+	}
+
+	return group, finish, nil
+}
+
+// lockGroup locks the state of the group (waiting for a while, if it's locked
+// by someone else already), and returns the func that releases the lock.
+func (s *slackChannel) lockGroup(ctx context.Context, dbKeyGroup string) (func(), error) {
+	dbKeyLock := dbKeyGroup + "/lock"
+
+	for attempt := 1; ; attempt++ {
+		didLock, err := s.db.Lock(ctx, dbKeyLock, timeoutSlackGroupLock)
+		if err != nil {
+			return nil, err
+		}
+		if didLock {
+			return func() { releaseLock(ctx, s.db, dbKeyLock) }, nil
+		}
+		if attempt == slackGroupLockAttempts {
+			return nil, ErrAlreadyLocked
+		}
+		if err := sleep(ctx, slackGroupLockWait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (g *slackGroup) status() string {
+	if len(g.Firing) > 0 {
+		return "firing"
+	}
+	return "resolved"
+}
+
+// newGroupMessage renders the summary message of the group (with the same
+// templates and in the same format as the alerts of the channel).
+func (s *slackChannel) newGroupMessage(
+	ctx context.Context,
+	source string,
+	groupLabels map[string]string,
+	group *slackGroup,
+) slack.Attachment {
+	data := &slackTemplateData{
+		Data: template.NewData(source, &types.AlertmanagerAlert{
+			Status: group.status(),
+			Labels: groupLabels,
+		}),
+		Group: &slackTemplateGroup{
+			Name:   formatLabels(groupLabels),
+			Firing: slices.Sorted(maps.Values(group.Firing)),
+		},
+	}
+
+	title := strings.TrimSpace(renderSlackTemplate(ctx,
+		s.templates.title, slackDefaultTemplates.title, data,
+	))
+	color := strings.TrimSpace(renderSlackTemplate(ctx,
+		s.templates.color, slackDefaultTemplates.color, data,
+	))
+
+	text := ""
+	for idx, instance := range data.Group.Firing {
+		if idx == slackMaxGroupInstances {
+			text += fmt.Sprintf("…and %d more\n", len(data.Group.Firing)-idx)
+			break
+		}
+		text += fmt.Sprintf("• `%s`\n", instance)
+	}
+
+	if s.format != config.SlackFormatBlocks {
+		return slack.Attachment{
+			Color: color,
+			Title: title,
+			Text:  text,
+		}
+	}
+
+	blocks := make([]slack.Block, 0, 2)
+	if title != "" {
+		blocks = append(blocks, slack.NewHeaderBlock(
+			slack.NewTextBlockObject(slack.PlainTextType, truncate(title, slackMaxHeaderText), true, false),
+		))
+	}
+	if text != "" {
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, truncate(text, slackMaxSectionText), false, false),
+			nil, nil,
+		))
+	}
+	return slack.Attachment{
+		Color:    color,
+		Fallback: title,
+		Blocks:   slack.Blocks{BlockSet: blocks},
+	}
+}
+
+// describeGroupInstance returns the labels that distinguish the alert among
+// the others in its group.
+func describeGroupInstance(alert *types.AlertmanagerAlert, groupLabels map[string]string) string {
+	labels := make(map[string]string, len(alert.Labels))
+	for name, value := range alert.Labels {
+		if _, grouped := groupLabels[name]; !grouped {
+			labels[name] = value
+		}
+	}
+	return formatLabels(labels)
+}
+
+// formatLabels returns the labels as sorted `name=value` pairs.
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, name+"="+labels[name])
+	}
+	return strings.Join(pairs, ", ")
+}
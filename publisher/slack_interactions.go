@@ -0,0 +1,513 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/db"
+	"github.com/flashbots/amp-alerts-sink/logutils"
+	"github.com/flashbots/amp-alerts-sink/template"
+	"github.com/slack-go/slack"
+	"go.uber.org/zap"
+)
+
+const (
+	slackActionAck       = "ack"
+	slackActionSilence1h = "silence-1h"
+	slackActionSilence4h = "silence-4h"
+	slackActionRunbook   = "runbook"
+
+	// slackActionsID identifies the interactive buttons of amp-alerts-sink
+	// (block id of the actions block, or callback id of the attachment).
+	slackActionsID = "amp-alerts-sink"
+
+	slackMaxActionValue = 2000
+
+	// slackAckSuffix is appended to the db key of alert's thread to get the
+	// key of its acknowledgement (the value is the id of slack user).
+	slackAckSuffix = "/ack"
+
+	maxInteractionBodySize = 1 << 20
+
+	timeoutInteraction = 30 * time.Second
+	timeoutSilence     = 10 * time.Second
+)
+
+var (
+	ErrSlackInteractionUnknownChannel = errors.New("slack interaction came from unknown channel")
+	ErrSlackSilenceFailed             = errors.New("failed to create the silence")
+	ErrSlackSilenceNoAlertmanager     = errors.New("alertmanager url is unknown")
+)
+
+// slackActionValue is what the interactive buttons carry.
+type slackActionValue struct {
+	// Key is the db key of alert's thread
+	Key string `json:"k"`
+
+	ExternalURL string            `json:"u,omitempty"`
+	Labels      map[string]string `json:"l"`
+}
+
+// SlackInteractions handles the requests that slack sends when the users click
+// the interactive buttons of the alerts.
+type SlackInteractions struct {
+	alertmanagerURL string
+	signingSecret   string
+
+	channels map[string]slackApi
+	client   httpClient
+	db       db.DB
+
+	wg sync.WaitGroup // the interactions that are being handled
+}
+
+// alertmanagerSilence is the silence of alertmanager's v2 API.
+type alertmanagerSilence struct {
+	Matchers  []alertmanagerMatcher `json:"matchers"`
+	StartsAt  string                `json:"startsAt"`
+	EndsAt    string                `json:"endsAt"`
+	CreatedBy string                `json:"createdBy"`
+	Comment   string                `json:"comment"`
+}
+
+type alertmanagerMatcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+	IsEqual bool   `json:"isEqual"`
+}
+
+func NewSlackInteractions(cfg *config.Slack, db db.DB) *SlackInteractions {
+	channels := make(map[string]slackApi)
+	for _, ch := range cfg.EnabledChannels() {
+		channels[ch.ID] = slack.New(cfg.ForChannel(ch).Token)
+	}
+
+	return &SlackInteractions{
+		alertmanagerURL: cfg.AlertmanagerURL,
+		signingSecret:   cfg.SigningSecret,
+
+		channels: channels,
+		client:   &http.Client{Timeout: timeoutSilence},
+		db:       db,
+	}
+}
+
+// newActionValue returns the value for the interactive buttons of the alert
+// (or empty string, if it does not fit into slack's limits).
+func (s *slackChannel) newActionValue(data *template.Data) string {
+	value, err := json.Marshal(&slackActionValue{
+		Key:         data.Source + "/" + s.channelID + "/" + data.IncidentDedupKey(),
+		ExternalURL: data.ExternalURL,
+		Labels:      data.Labels,
+	})
+	if err != nil || len(value) > slackMaxActionValue {
+		return ""
+	}
+	return string(value)
+}
+
+// ackedBy returns the id of slack user who acknowledged the alert's thread
+// (or empty string, if nobody did).
+func (s *slackChannel) ackedBy(ctx context.Context, dbKeyThreadTS string) string {
+	if !s.interactive {
+		return ""
+	}
+
+	userID, err := s.db.Get(ctx, dbKeyThreadTS+slackAckSuffix)
+	if err != nil {
+		logutils.LoggerFromContext(ctx).Warn("Failed to check if the alert was acknowledged",
+			zap.Error(err),
+		)
+		return ""
+	}
+	return userID
+}
+
+// slackAckNote returns the line that tells who acknowledged the alert.
+func slackAckNote(userID string) string {
+	return fmt.Sprintf("Acknowledged by <@%s>", userID)
+}
+
+// addAttachmentActions adds interactive buttons to the legacy attachment (the
+// acknowledge button is left out if the alert is already acknowledged).
+func (s *slackChannel) addAttachmentActions(msg *slack.Attachment, data *template.Data, acked bool) {
+	if value := s.newActionValue(data); value != "" {
+		msg.CallbackID = slackActionsID
+		if !acked {
+			msg.Actions = append(msg.Actions,
+				slack.AttachmentAction{Name: slackActionAck, Text: "Acknowledge", Type: "button", Value: value, Style: "primary"},
+			)
+		}
+		msg.Actions = append(msg.Actions,
+			slack.AttachmentAction{Name: slackActionSilence1h, Text: "Silence 1h", Type: "button", Value: value},
+			slack.AttachmentAction{Name: slackActionSilence4h, Text: "Silence 4h", Type: "button", Value: value},
+		)
+	}
+	if runbook := data.Annotations["runbook_url"]; runbook != "" {
+		msg.Actions = append(msg.Actions,
+			slack.AttachmentAction{Name: slackActionRunbook, Text: "Open runbook", Type: "button", URL: runbook},
+		)
+	}
+}
+
+// newActionsBlock returns the block with interactive buttons (or nil, if
+// there's no room for them).  The runbook link is already among the buttons
+// of the links block, and the acknowledge button is left out if the alert is
+// already acknowledged.
+func (s *slackChannel) newActionsBlock(data *template.Data, acked bool) slack.Block {
+	value := s.newActionValue(data)
+	if value == "" {
+		return nil
+	}
+
+	button := func(actionID, text string) *slack.ButtonBlockElement {
+		return slack.NewButtonBlockElement(actionID, value,
+			slack.NewTextBlockObject(slack.PlainTextType, text, false, false),
+		)
+	}
+
+	buttons := make([]slack.BlockElement, 0, 3)
+	if !acked {
+		ack := button(slackActionAck, "Acknowledge")
+		ack.Style = slack.StylePrimary
+		buttons = append(buttons, ack)
+	}
+	buttons = append(buttons,
+		button(slackActionSilence1h, "Silence 1h"),
+		button(slackActionSilence4h, "Silence 4h"),
+	)
+
+	return slack.NewActionBlock(slackActionsID, buttons...)
+}
+
+func (s *SlackInteractions) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := logutils.LoggerFromContext(ctx)
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxInteractionBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	verifier, err := slack.NewSecretsVerifier(r.Header, s.signingSecret)
+	if err == nil {
+		_, _ = verifier.Write(body)
+		err = verifier.Ensure()
+	}
+	if err != nil {
+		l.Warn("Rejected slack interaction with invalid signature",
+			zap.Error(err),
+		)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	callback := &slack.InteractionCallback{}
+	if err := json.Unmarshal([]byte(form.Get("payload")), callback); err != nil {
+		l.Error("Error un-marshalling slack interaction",
+			zap.Error(err),
+		)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	l = l.With(
+		zap.String("slack_channel_id", callback.Channel.ID),
+		zap.String("slack_user_id", callback.User.ID),
+	)
+	ctx = logutils.ContextWithLogger(ctx, l)
+
+	// slack expects the response within 3 seconds (and retries the action
+	// otherwise), while e.g. alertmanager might take longer than that to
+	// create the silence.  so the actions are handled in the background, and
+	// the failures are reported back to the user via response url.
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeoutInteraction)
+		defer cancel()
+
+		if err := s.handle(ctx, callback); err != nil {
+			l.Error("Failed to handle slack interaction",
+				zap.Error(err),
+			)
+			s.reportFailure(ctx, callback, err)
+		}
+	}()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Shutdown waits for the interactions that are still being handled in the
+// background (or until the context is done).
+func (s *SlackInteractions) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reportFailure tells the user who clicked the button that the action has
+// failed (with the message that only they can see).
+func (s *SlackInteractions) reportFailure(ctx context.Context, callback *slack.InteractionCallback, failure error) {
+	if callback.ResponseURL == "" {
+		return
+	}
+
+	err := postJSON(ctx, s.client, "slack response url", callback.ResponseURL, nil, &slack.WebhookMessage{
+		Text:            fmt.Sprintf(":warning: Failed to handle the action: %s", failure),
+		ResponseType:    slack.ResponseTypeEphemeral,
+		ReplaceOriginal: false,
+	}, nil)
+	if err != nil {
+		logutils.LoggerFromContext(ctx).Error("Failed to report slack interaction failure",
+			zap.Error(err),
+		)
+	}
+}
+
+func (s *SlackInteractions) handle(ctx context.Context, callback *slack.InteractionCallback) error {
+	errs := []error{}
+
+	// block kit buttons
+	for _, action := range callback.ActionCallback.BlockActions {
+		if action.BlockID != slackActionsID {
+			continue
+		}
+		if err := s.handleAction(ctx, callback, action.ActionID, action.Value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// legacy attachment buttons
+	if callback.CallbackID == slackActionsID {
+		for _, action := range callback.ActionCallback.AttachmentActions {
+			if err := s.handleAction(ctx, callback, action.Name, action.Value); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *SlackInteractions) handleAction(
+	ctx context.Context,
+	callback *slack.InteractionCallback,
+	action, rawValue string,
+) error {
+	l := logutils.LoggerFromContext(ctx)
+
+	cli, known := s.channels[callback.Channel.ID]
+	if !known {
+		return fmt.Errorf("%w: %s",
+			ErrSlackInteractionUnknownChannel, callback.Channel.ID,
+		)
+	}
+
+	value := &slackActionValue{}
+	if err := json.Unmarshal([]byte(rawValue), value); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	user := callback.User.Name
+	if user == "" {
+		user = callback.User.ID
+	}
+
+	var note string
+	switch action {
+	case slackActionAck:
+		db := s.db.WithNamespace("slack-" + callback.Channel.ID)
+		ackedBy, err := db.Get(ctx, value.Key+slackAckSuffix)
+		if err != nil {
+			return err
+		}
+		if ackedBy != "" {
+			// e.g. via another message of the same thread
+			note = slackAckNote(ackedBy)
+			l.Info("Skipped repeated acknowledgement of the alert via slack",
+				zap.String("key", value.Key),
+				zap.String("slack_acked_by", ackedBy),
+			)
+			break
+		}
+		if err := db.Set(ctx, value.Key+slackAckSuffix, timeoutThreadExpiry, callback.User.ID); err != nil {
+			return err
+		}
+		note = slackAckNote(callback.User.ID) + fmt.Sprintf(" at `%s`",
+			now.Format(time.RFC3339),
+		)
+		l.Info("Acknowledged the alert via slack",
+			zap.String("key", value.Key),
+		)
+
+	case slackActionSilence1h, slackActionSilence4h:
+		duration := time.Hour
+		if action == slackActionSilence4h {
+			duration = 4 * time.Hour
+		}
+		silenceID, err := s.createSilence(ctx, value, now, duration, user)
+		if err != nil {
+			return err
+		}
+		note = fmt.Sprintf("Silenced for %dh by <@%s> (silence `%s`)",
+			int(duration.Hours()), callback.User.ID, silenceID,
+		)
+		l.Info("Silenced the alert via slack",
+			zap.String("key", value.Key),
+			zap.String("silence_id", silenceID),
+		)
+
+	default:
+		return nil
+	}
+
+	return s.updateMessage(cli, callback, action, note)
+}
+
+// updateMessage adds the note to the message that the buttons were clicked
+// on, and removes the clicked button.
+func (s *SlackInteractions) updateMessage(
+	cli slackApi,
+	callback *slack.InteractionCallback,
+	action, note string,
+) error {
+	message := callback.Message
+	if len(message.Attachments) == 0 {
+		message = callback.OriginalMessage
+	}
+	ts := callback.Container.MessageTs
+	if ts == "" {
+		ts = callback.MessageTs
+	}
+	if len(message.Attachments) == 0 || ts == "" {
+		return nil
+	}
+
+	attachments := slices.Clone(message.Attachments)
+	msg := &attachments[0]
+	if len(msg.Blocks.BlockSet) > 0 {
+		blocks := make([]slack.Block, 0, len(msg.Blocks.BlockSet)+1)
+		for _, block := range msg.Blocks.BlockSet {
+			if actions, ok := block.(*slack.ActionBlock); ok && actions.BlockID == slackActionsID {
+				actions.Elements.ElementSet = slices.DeleteFunc(slices.Clone(actions.Elements.ElementSet), func(e slack.BlockElement) bool {
+					button, ok := e.(*slack.ButtonBlockElement)
+					return ok && button.ActionID == action
+				})
+			}
+			blocks = append(blocks, block)
+		}
+		msg.Blocks = appendSlackFooterBlock(slack.Blocks{BlockSet: blocks}, note)
+	} else {
+		msg.Actions = slices.DeleteFunc(slices.Clone(msg.Actions), func(a slack.AttachmentAction) bool {
+			return a.Name == action
+		})
+		msg.Text += "\n" + note
+	}
+
+	_, _, _, err := cli.UpdateMessage(callback.Channel.ID, ts,
+		slack.MsgOptionAttachments(attachments...),
+	)
+	return err
+}
+
+// createSilence creates the silence for all labels of the alert through
+// alertmanager's v2 API, and returns its ID.
+func (s *SlackInteractions) createSilence(
+	ctx context.Context,
+	value *slackActionValue,
+	now time.Time,
+	duration time.Duration,
+	user string,
+) (string, error) {
+	alertmanagerURL := value.ExternalURL
+	if alertmanagerURL == "" {
+		alertmanagerURL = s.alertmanagerURL
+	}
+	if alertmanagerURL == "" {
+		return "", ErrSlackSilenceNoAlertmanager
+	}
+
+	names := make([]string, 0, len(value.Labels))
+	for name := range value.Labels {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	silence := &alertmanagerSilence{
+		Matchers:  make([]alertmanagerMatcher, 0, len(names)),
+		StartsAt:  now.Format(time.RFC3339),
+		EndsAt:    now.Add(duration).Format(time.RFC3339),
+		CreatedBy: user,
+		Comment:   "Silenced from slack",
+	}
+	for _, name := range names {
+		silence.Matchers = append(silence.Matchers, alertmanagerMatcher{
+			Name:    name,
+			Value:   value.Labels[name],
+			IsEqual: true,
+		})
+	}
+
+	body, err := json.Marshal(silence)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeoutSilence)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimSuffix(alertmanagerURL, "/")+"/api/v2/silences",
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	respBody, err := sendRequest(ctx, s.client, "alertmanager", req, nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w",
+			ErrSlackSilenceFailed, err,
+		)
+	}
+
+	res := struct {
+		SilenceID string `json:"silenceID"`
+	}{}
+	if err := json.Unmarshal(respBody, &res); err != nil {
+		return "", fmt.Errorf("failed to decode alertmanager response: %w", err)
+	}
+
+	return res.SilenceID, nil
+}
@@ -0,0 +1,318 @@
+package publisher
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	mock_db "github.com/flashbots/amp-alerts-sink/mock/db"
+	mock_publisher "github.com/flashbots/amp-alerts-sink/mock/publisher"
+	"github.com/flashbots/amp-alerts-sink/template"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func newSlackInteractionRequest(t *testing.T, secret string, callback map[string]any) *http.Request {
+	payload, err := json.Marshal(callback)
+	assert.NoError(t, err)
+	body := url.Values{"payload": {string(payload)}}.Encode()
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":" + body))
+
+	req := httptest.NewRequest(http.MethodPost, "/slack/interactions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func newSlackInteractionCallback(actionID, value string) map[string]any {
+	return map[string]any{
+		"type":      "block_actions",
+		"channel":   map[string]any{"id": "testChannelID"},
+		"user":      map[string]any{"id": "U123", "name": "alice"},
+		"container": map[string]any{"message_ts": "testThreadTS"},
+		"message": map[string]any{
+			"attachments": []any{map[string]any{
+				"color": "danger",
+				"blocks": []any{map[string]any{
+					"type":     "actions",
+					"block_id": slackActionsID,
+					"elements": []any{
+						map[string]any{"type": "button", "action_id": slackActionAck, "value": value, "text": map[string]any{"type": "plain_text", "text": "Acknowledge"}},
+						map[string]any{"type": "button", "action_id": slackActionSilence1h, "value": value, "text": map[string]any{"type": "plain_text", "text": "Silence 1h"}},
+					},
+				}},
+			}},
+		},
+		"actions": []any{map[string]any{
+			"type":      "button",
+			"block_id":  slackActionsID,
+			"action_id": actionID,
+			"value":     value,
+		}},
+	}
+}
+
+func TestSlackInteractionsInvalidSignature(t *testing.T) {
+	s := &SlackInteractions{signingSecret: "testSecret"}
+
+	req := newSlackInteractionRequest(t, "wrongSecret", newSlackInteractionCallback(slackActionAck, "{}"))
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestSlackInteractionsAck(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock_db.NewMockDB(ctrl)
+	slack := mock_publisher.NewMock_slackApi(ctrl)
+
+	s := &SlackInteractions{
+		signingSecret: "testSecret",
+		channels:      map[string]slackApi{"testChannelID": slack},
+		db:            db,
+	}
+
+	ch := &slackChannel{channelID: "testChannelID"}
+	value := ch.newActionValue(template.NewData("testSource", alertFiring))
+
+	db.EXPECT().WithNamespace("slack-testChannelID").Return(db)
+	db.EXPECT().
+		Get(gomock.Any(), "testSource/testChannelID/"+alertFiring.IncidentDedupKey()+"/ack").
+		Return("", nil)
+	db.EXPECT().
+		Set(gomock.Any(), "testSource/testChannelID/"+alertFiring.IncidentDedupKey()+"/ack", timeoutThreadExpiry, "U123").
+		Return(nil)
+
+	slack.EXPECT().
+		UpdateMessage("testChannelID", "testThreadTS", gomock.Any()).
+		Return("", "", "", nil)
+
+	req := newSlackInteractionRequest(t, "testSecret", newSlackInteractionCallback(slackActionAck, value))
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	s.wg.Wait()
+
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
+
+func TestSlackInteractionsRepeatedAck(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock_db.NewMockDB(ctrl)
+	slack := mock_publisher.NewMock_slackApi(ctrl)
+
+	s := &SlackInteractions{
+		signingSecret: "testSecret",
+		channels:      map[string]slackApi{"testChannelID": slack},
+		db:            db,
+	}
+
+	ch := &slackChannel{channelID: "testChannelID"}
+	value := ch.newActionValue(template.NewData("testSource", alertFiring))
+
+	// acknowledged already, so the record must stay intact (no Set)
+	db.EXPECT().WithNamespace("slack-testChannelID").Return(db)
+	db.EXPECT().
+		Get(gomock.Any(), "testSource/testChannelID/"+alertFiring.IncidentDedupKey()+"/ack").
+		Return("U456", nil)
+
+	slack.EXPECT().
+		UpdateMessage("testChannelID", "testThreadTS", gomock.Any()).
+		Return("", "", "", nil)
+
+	req := newSlackInteractionRequest(t, "testSecret", newSlackInteractionCallback(slackActionAck, value))
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	s.wg.Wait()
+
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
+
+func TestSlackInteractionsSilence(t *testing.T) {
+	var silence alertmanagerSilence
+	am := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/silences", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&silence))
+		_, _ = w.Write([]byte(`{"silenceID":"testSilenceID"}`))
+	}))
+	defer am.Close()
+
+	ctrl := gomock.NewController(t)
+	slack := mock_publisher.NewMock_slackApi(ctrl)
+
+	s := &SlackInteractions{
+		alertmanagerURL: am.URL,
+		signingSecret:   "testSecret",
+		channels:        map[string]slackApi{"testChannelID": slack},
+		client:          http.DefaultClient,
+	}
+
+	ch := &slackChannel{channelID: "testChannelID"}
+	value := ch.newActionValue(template.NewData("testSource", alertFiring))
+
+	slack.EXPECT().
+		UpdateMessage("testChannelID", "testThreadTS", gomock.Any()).
+		Return("", "", "", nil)
+
+	req := newSlackInteractionRequest(t, "testSecret", newSlackInteractionCallback(slackActionSilence1h, value))
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	s.wg.Wait()
+
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "alice", silence.CreatedBy)
+	assert.Equal(t, []alertmanagerMatcher{
+		{Name: "alertname", Value: "TestAlert", IsEqual: true},
+		{Name: "instance", Value: "Grafana", IsEqual: true},
+		{Name: "severity", Value: "critical", IsEqual: true},
+	}, silence.Matchers)
+
+	startsAt, _ := time.Parse(time.RFC3339, silence.StartsAt)
+	endsAt, _ := time.Parse(time.RFC3339, silence.EndsAt)
+	assert.Equal(t, time.Hour, endsAt.Sub(startsAt))
+}
+
+func TestSlackInteractionsSlowSilence(t *testing.T) {
+	release := make(chan struct{})
+	am := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer am.Close()
+
+	var response map[string]any
+	responseURL := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&response))
+	}))
+	defer responseURL.Close()
+
+	ctrl := gomock.NewController(t)
+	slack := mock_publisher.NewMock_slackApi(ctrl)
+
+	s := &SlackInteractions{
+		alertmanagerURL: am.URL,
+		signingSecret:   "testSecret",
+		channels:        map[string]slackApi{"testChannelID": slack},
+		client:          http.DefaultClient,
+	}
+
+	ch := &slackChannel{channelID: "testChannelID"}
+	value := ch.newActionValue(template.NewData("testSource", alertFiring))
+
+	callback := newSlackInteractionCallback(slackActionSilence1h, value)
+	callback["response_url"] = responseURL.URL
+
+	// slack gets its response before alertmanager is done with the silence
+	req := newSlackInteractionRequest(t, "testSecret", callback)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// the message is not updated, and the failure is reported to the user
+	close(release)
+	s.wg.Wait()
+
+	assert.Equal(t, "ephemeral", response["response_type"])
+	assert.Equal(t, false, response["replace_original"])
+	assert.Contains(t, response["text"], ErrSlackSilenceFailed.Error())
+}
+
+func TestSlackInteractionsShutdown(t *testing.T) {
+	release := make(chan struct{})
+	am := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		_, _ = w.Write([]byte(`{"silenceID":"testSilenceID"}`))
+	}))
+	defer am.Close()
+
+	ctrl := gomock.NewController(t)
+	slack := mock_publisher.NewMock_slackApi(ctrl)
+
+	s := &SlackInteractions{
+		alertmanagerURL: am.URL,
+		signingSecret:   "testSecret",
+		channels:        map[string]slackApi{"testChannelID": slack},
+		client:          http.DefaultClient,
+	}
+
+	ch := &slackChannel{channelID: "testChannelID"}
+	value := ch.newActionValue(template.NewData("testSource", alertFiring))
+
+	slack.EXPECT().
+		UpdateMessage("testChannelID", "testThreadTS", gomock.Any()).
+		Return("", "", "", nil)
+
+	req := newSlackInteractionRequest(t, "testSecret", newSlackInteractionCallback(slackActionSilence1h, value))
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// the silence is still being created
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Shutdown(ctx), context.DeadlineExceeded)
+
+	// and it's done (with the message updated) once shutdown returns
+	close(release)
+	assert.NoError(t, s.Shutdown(t.Context()))
+}
+
+func TestSlackChannelInteractiveButtons(t *testing.T) {
+	templates, err := newSlackTemplates(nil, "")
+	assert.NoError(t, err)
+
+	s := &slackChannel{channelID: "testChannelID", interactive: true, templates: templates}
+
+	msg := s.newMessage(t.Context(), template.NewData("testSource", alertFiring), "")
+	assert.Equal(t, slackActionsID, msg.CallbackID)
+	assert.Len(t, msg.Actions, 3)
+
+	msg = s.newMessage(t.Context(), template.NewData("testSource", alertResolved), "")
+	assert.Empty(t, msg.Actions)
+}
+
+func TestSlackChannelAcknowledgedMessage(t *testing.T) {
+	templates, err := newSlackTemplates(nil, "")
+	assert.NoError(t, err)
+
+	s := &slackChannel{channelID: "testChannelID", interactive: true, templates: templates}
+
+	// no acknowledge button once acknowledged, but the rest are still there
+	msg := s.newMessage(t.Context(), template.NewData("testSource", alertFiring), "U123")
+	assert.Contains(t, msg.Text, "Acknowledged by <@U123>")
+	assert.Len(t, msg.Actions, 2)
+	for _, action := range msg.Actions {
+		assert.NotEqual(t, slackActionAck, action.Name)
+	}
+
+	msg = s.newMessage(t.Context(), template.NewData("testSource", alertResolved), "U123")
+	assert.Contains(t, msg.Text, "Acknowledged by <@U123>")
+	assert.Empty(t, msg.Actions)
+}
+
+func TestSlackChannelAckedBy(t *testing.T) {
+	db := newMemoryDB(t)
+	s := &slackChannel{channelID: "testChannelID", db: db}
+	ctx := t.Context()
+
+	assert.NoError(t, db.Set(ctx, "testThread/ack", timeoutThreadExpiry, "U123"))
+	assert.Empty(t, s.ackedBy(ctx, "testThread"), "acks are ignored in non-interactive mode")
+
+	s.interactive = true
+	assert.Equal(t, "U123", s.ackedBy(ctx, "testThread"))
+	assert.Empty(t, s.ackedBy(ctx, "otherThread"))
+}
@@ -0,0 +1,72 @@
+package publisher
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/matcher"
+	"github.com/flashbots/amp-alerts-sink/types"
+)
+
+type slackMention struct {
+	matchLabels matcher.Matchers
+	severity    string
+	mentions    []string
+}
+
+func newSlackMentions(cfg []*config.SlackMention) ([]*slackMention, error) {
+	res := make([]*slackMention, 0, len(cfg))
+	for _, m := range cfg {
+		matchLabels, err := m.MatchLabelsMatchers()
+		if err != nil {
+			return nil, err
+		}
+
+		mentions := make([]string, 0, len(m.UserGroups)+len(m.Users)+2)
+		if m.Channel {
+			mentions = append(mentions, "<!channel>")
+		}
+		if m.Here {
+			mentions = append(mentions, "<!here>")
+		}
+		for _, group := range m.UserGroups {
+			mentions = append(mentions, "<!subteam^"+group+">")
+		}
+		for _, user := range m.Users {
+			mentions = append(mentions, "<@"+user+">")
+		}
+
+		res = append(res, &slackMention{
+			matchLabels: matchLabels,
+			severity:    m.Severity,
+			mentions:    mentions,
+		})
+	}
+	return res, nil
+}
+
+// mentionsFor returns the mentions that must accompany the alert (or empty
+// string, if there are none).  Only the alerts that start firing get
+// mentioned, never the resolved ones or the follow-ups.
+func (s *slackChannel) mentionsFor(alert *types.AlertmanagerAlert, threadTS string) string {
+	if alert.Status != "firing" || threadTS != "" {
+		return ""
+	}
+
+	res := []string{}
+	for _, m := range s.mentions {
+		if m.severity != "" && alert.Labels["severity"] != m.severity {
+			continue
+		}
+		if !m.matchLabels.Matches(alert.Labels) {
+			continue
+		}
+		for _, mention := range m.mentions {
+			if !slices.Contains(res, mention) {
+				res = append(res, mention)
+			}
+		}
+	}
+	return strings.Join(res, " ")
+}
@@ -0,0 +1,143 @@
+package publisher
+
+import (
+	"context"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/logutils"
+	"github.com/flashbots/amp-alerts-sink/template"
+	"go.uber.org/zap"
+)
+
+// slackTemplateData is what slack templates are executed against.
+type slackTemplateData struct {
+	*template.Data
+
+	// FollowUp is true when the message is posted into existing thread.
+	FollowUp bool
+
+	// FollowUpTo is the time when thread-starting message was published
+	// (empty if unknown).
+	FollowUpTo string
+
+	// Group is set when the summary of the group of alerts is rendered (the
+	// labels are then the labels of the group).
+	Group *slackTemplateGroup
+}
+
+// slackTemplateGroup is what the templates get to know about the group.
+type slackTemplateGroup struct {
+	// Name is the group labels as sorted `name=value` pairs.
+	Name string
+
+	// Firing are the descriptions of group's firing alerts.
+	Firing []string
+}
+
+type slackTemplates struct {
+	color  *template.Template
+	footer *template.Template
+	text   *template.Template
+	title  *template.Template
+}
+
+var slackDefaultTemplates = &slackTemplates{
+	color: template.Must(template.New("slack-color",
+		`{{ if eq .Status "firing" }}`+
+			`{{ if eq .Labels.severity "critical" }}danger`+
+			`{{ else if eq .Labels.severity "warning" }}warning`+
+			`{{ else if .Group }}danger`+
+			`{{ else }}good{{ end }}`+
+			`{{ else }}good{{ end }}`,
+	)),
+
+	footer: template.Must(template.New("slack-footer",
+		`{{ if .FollowUp }}`+
+			`{{ with .FollowUpTo }}(follow-up to the alert published at {{ . }})`+
+			`{{ else }}(follow-up){{ end }}`+
+			`{{ end }}`,
+	)),
+
+	text: template.Must(template.New("slack-text",
+		"{{ with .Labels.severity }}Severity: `{{ . }}`\n{{ end }}"+
+			"{{ with .Annotations.summary }}Summary: `{{ . }}`\n{{ end }}"+
+			"{{ with .Annotations.description }}\n{{ . }}\n\n{{ end }}"+
+			"{{ with .Annotations.message }}\n{{ . }}\n\n{{ end }}"+
+			"{{ with .StartsAt }}Started at: `{{ . }}`\n{{ end }}"+
+			"{{ with .Labels.aws_account }}AWS account: `{{ . }}`\n{{ end }}"+
+			"{{ with .Labels.cluster }}Kubernetes cluster: `{{ . }}`\n{{ end }}"+
+			"{{ with .Labels.namespace }}Kubernetes namespace: `{{ . }}`\n{{ end }}"+
+			"{{ with .Links }}\n"+
+			"{{ range $i, $link := . }}{{ if $i }} | {{ end }}<{{ $link.Href }}|{{ $link.Text }}>{{ end }}\n"+
+			"{{ end }}",
+	)),
+
+	title: template.Must(template.New("slack-title",
+		`{{ .Status | toUpper }}: `+
+			`{{ with .Group }}{{ .Name }}{{ with .Firing }} ({{ len . }}){{ end }}`+
+			`{{ else }}{{ .Labels.alertname }}{{ end }}`,
+	)),
+}
+
+// slackDefaultBlocksText is the default text of block kit messages (labels,
+// timestamps and links have their own blocks there).
+var slackDefaultBlocksText = template.Must(template.New("slack-text",
+	"{{ with .Annotations.summary }}*{{ . }}*\n{{ end }}"+
+		"{{ with .Annotations.description }}\n{{ . }}\n{{ end }}"+
+		"{{ with .Annotations.message }}\n{{ . }}\n{{ end }}",
+))
+
+// newSlackTemplates parses the templates from the config, falling back to the
+// default ones for those that are not configured.
+func newSlackTemplates(cfg *config.SlackTemplates, format string) (*slackTemplates, error) {
+	res := *slackDefaultTemplates
+	if format == config.SlackFormatBlocks {
+		res.text = slackDefaultBlocksText
+	}
+	if cfg == nil {
+		return &res, nil
+	}
+
+	for _, t := range []struct {
+		name string
+		text string
+		dst  **template.Template
+	}{
+		{"slack-color", cfg.Color, &res.color},
+		{"slack-footer", cfg.Footer, &res.footer},
+		{"slack-text", cfg.Text, &res.text},
+		{"slack-title", cfg.Title, &res.title},
+	} {
+		if t.text == "" {
+			continue
+		}
+		tmpl, err := template.New(t.name, t.text)
+		if err != nil {
+			return nil, err
+		}
+		*t.dst = tmpl
+	}
+
+	return &res, nil
+}
+
+// renderSlackTemplate executes the template, and falls back to the default one if that
+// fails (so that the alert is published no matter what).
+func renderSlackTemplate(
+	ctx context.Context,
+	tmpl, fallback *template.Template,
+	data *slackTemplateData,
+) string {
+	res, err := tmpl.Execute(data)
+	if err == nil {
+		return res
+	}
+
+	l := logutils.LoggerFromContext(ctx)
+	l.Error("Failed to render slack template, falling back to the default one",
+		zap.Error(err),
+	)
+
+	res, _ = fallback.Execute(data)
+	return res
+}
@@ -2,10 +2,14 @@ package publisher
 
 import (
 	"context"
+	"encoding/json"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/template"
 	"github.com/flashbots/amp-alerts-sink/types"
 
 	mock_db "github.com/flashbots/amp-alerts-sink/mock/db"
@@ -238,3 +242,580 @@ func TestSlackDuplicateAlert(t *testing.T) {
 		Return("testMessageTX", nil) // duplicate alert
 
 }
+
+func TestSlackChannelMatchLabels(t *testing.T) {
+	for name, tc := range map[string]struct {
+		matchLabels []string
+		matches     bool
+	}{
+		"mismatch":             {[]string{"team=~infra|platform"}, false},
+		"match":                {[]string{"severity=~critical|warning"}, true},
+		"all matchers match":   {[]string{"severity=critical", "instance!=Prometheus"}, true},
+		"one matcher mismatch": {[]string{"severity=critical", "instance=Prometheus"}, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			db := mock_db.NewMockDB(ctrl)
+
+			p, err := NewSlackChannel(&config.Slack{
+				Token: "testToken",
+				Channel: &config.SlackChannel{
+					ID:          "testChannelID",
+					MatchLabels: tc.matchLabels,
+				},
+			}, db)
+			assert.NoError(t, err)
+
+			// no db or slack calls are expected for the mismatching alerts,
+			// the matching ones get to the (duplicate) check
+			if tc.matches {
+				db.EXPECT().
+					Get(gomock.Any(), "testSource/testChannelID/"+alertFiring.MessageDedupKey()).
+					Return("testMessageTX", nil)
+			}
+
+			err = p.Publish(context.Background(), "testSource", alertFiring)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestSlackChannelDefaultTemplates(t *testing.T) {
+	templates, err := newSlackTemplates(nil, "")
+	assert.NoError(t, err)
+
+	s := &slackChannel{templates: templates}
+
+	alert := &types.AlertmanagerAlert{
+		StartsAt:     "2023-07-15T21:37:23.977957594Z",
+		Status:       "firing",
+		GeneratorURL: "https://grafana/expr",
+
+		Annotations: map[string]string{
+			"description": "Something is wrong",
+			"runbook_url": "https://runbook",
+			"summary":     "Notification test",
+		},
+
+		Labels: map[string]string{
+			"alertname": "TestAlert",
+			"cluster":   "testCluster",
+			"severity":  "warning",
+		},
+	}
+
+	msg := s.newMessage(context.Background(), template.NewData("testSource", alert), "")
+	assert.Equal(t, "warning", msg.Color)
+	assert.Equal(t, "FIRING: TestAlert", msg.Title)
+	assert.Equal(t, ""+
+		"Severity: `warning`\n"+
+		"Summary: `Notification test`\n"+
+		"\nSomething is wrong\n\n"+
+		"Started at: `2023-07-15T21:37:23.977957594Z`\n"+
+		"Kubernetes cluster: `testCluster`\n"+
+		"\n<https://runbook|📕 Runbook> | <https://grafana/expr|📈 Expr>\n",
+		msg.Text,
+	)
+
+	msg = s.newMessage(context.Background(), template.NewData("testSource", alertResolved), "")
+	assert.Equal(t, "good", msg.Color)
+	assert.Equal(t, "RESOLVED: TestAlert", msg.Title)
+}
+
+func TestSlackChannelCustomTemplates(t *testing.T) {
+	templates, err := newSlackTemplates(&config.SlackTemplates{
+		Color: `{{ if eq .Status "firing" }}#ff0000{{ else }}#00ff00{{ end }}`,
+		Text:  `{{ .Annotations.summary | reReplaceAll "test" "check" }} ({{ join ", " (stringSlice .Labels.instance .Source) }})`,
+		Title: `[{{ .Labels.severity | toUpper }}] {{ .Labels.alertname }}`,
+	}, config.SlackFormatAttachment)
+	assert.NoError(t, err)
+
+	s := &slackChannel{templates: templates}
+
+	msg := s.newMessage(context.Background(), template.NewData("testSource", alertFiring), "")
+	assert.Equal(t, "#ff0000", msg.Color)
+	assert.Equal(t, "[CRITICAL] TestAlert", msg.Title)
+	assert.Equal(t, "Notification check (Grafana, testSource)", msg.Text)
+
+	_, err = newSlackTemplates(&config.SlackTemplates{
+		Title: `{{ .Status `,
+	}, config.SlackFormatAttachment)
+	assert.Error(t, err)
+}
+
+func TestSlackChannelBlocks(t *testing.T) {
+	templates, err := newSlackTemplates(nil, config.SlackFormatBlocks)
+	assert.NoError(t, err)
+
+	s := &slackChannel{format: config.SlackFormatBlocks, templates: templates}
+
+	alert := &types.AlertmanagerAlert{
+		StartsAt:     "2023-07-15T21:37:23.977957594Z",
+		Status:       "firing",
+		GeneratorURL: "https://grafana/expr",
+		SilenceURL:   "https://grafana/silence",
+
+		Annotations: map[string]string{
+			"summary": "Notification test",
+		},
+
+		Labels: map[string]string{
+			"alertname": "TestAlert",
+			"instance":  "Grafana",
+			"severity":  "critical",
+		},
+	}
+
+	msg := s.newMessage(context.Background(), template.NewData("testSource", alert), "")
+	assert.Equal(t, "danger", msg.Color)
+	assert.Empty(t, msg.Text)
+
+	blocks := msg.Blocks.BlockSet
+	if assert.Len(t, blocks, 5) {
+		header := blocks[0].(*slack_api.HeaderBlock)
+		assert.Equal(t, "FIRING: TestAlert", header.Text.Text)
+
+		text := blocks[1].(*slack_api.SectionBlock)
+		assert.Equal(t, "*Notification test*", text.Text.Text)
+
+		labels := blocks[2].(*slack_api.SectionBlock)
+		if assert.Len(t, labels.Fields, 2) {
+			assert.Equal(t, "*instance*\n`Grafana`", labels.Fields[0].Text)
+			assert.Equal(t, "*severity*\n`critical`", labels.Fields[1].Text)
+		}
+
+		assert.IsType(t, &slack_api.ContextBlock{}, blocks[3])
+
+		actions := blocks[4].(*slack_api.ActionBlock)
+		if assert.Len(t, actions.Elements.ElementSet, 2) {
+			assert.Equal(t, "https://grafana/expr", actions.Elements.ElementSet[0].(*slack_api.ButtonBlockElement).URL)
+			assert.Equal(t, "https://grafana/silence", actions.Elements.ElementSet[1].(*slack_api.ButtonBlockElement).URL)
+		}
+	}
+
+	// follow-up footer must not leak into the original message
+	withFooter := appendSlackFooterBlock(msg.Blocks, "(follow-up)")
+	assert.Len(t, withFooter.BlockSet, 6)
+	assert.Len(t, msg.Blocks.BlockSet, 5)
+}
+
+func TestSlackResolvingAlertUpdatesThread(t *testing.T) {
+	p, db, slack := setupSlackPublisher(t)
+	p.(*slackChannel).updateOnResolve = true
+	ctx := context.Background()
+	alert := alertResolved
+
+	db.EXPECT().
+		Get(ctx, "testSource/testChannelID/"+alert.MessageDedupKey()).
+		Return("", nil)
+
+	db.EXPECT().
+		Lock(ctx, "testSource/testChannelID/"+alert.MessageDedupKey(), timeoutLock).
+		Return(true, nil)
+
+	db.EXPECT().
+		Get(ctx, "testSource/testChannelID/"+alert.IncidentDedupKey()).
+		Return("testThreadTS", nil) // thread exists
+
+	slack.EXPECT().
+		PostMessage("testChannelID", gomock.Any()).
+		Return("", "testMessageTS", nil)
+
+	db.EXPECT().
+		Set(ctx, "testSource/testChannelID/"+alert.MessageDedupKey(), timeoutThreadExpiry, "testMessageTS")
+
+	slack.EXPECT().
+		RemoveReaction("rotating_light", gomock.Any()).
+		Return(nil)
+
+	slack.EXPECT().
+		AddReaction("white_check_mark", gomock.Any()).
+		Return(nil)
+
+	slack.EXPECT().
+		UpdateMessage("testChannelID", "testThreadTS", gomock.Any()).
+		DoAndReturn(func(_, _ string, options ...slack_api.MsgOption) (string, string, string, error) {
+			assert.Equal(t, 1, len(options))
+			return "", "testThreadTS", "", nil
+		})
+
+	err := p.Publish(ctx, "testSource", alert)
+	assert.NoError(t, err)
+}
+
+func TestSlackResolvedNote(t *testing.T) {
+	note := slackResolvedNote(&types.AlertmanagerAlert{
+		StartsAt: "2023-07-15T21:37:23Z",
+		EndsAt:   "2023-07-15T22:40:00.5Z",
+	})
+	assert.Equal(t, "Resolved at: `2023-07-15T22:40:00Z` (after `1h2m38s`)", note)
+}
+
+func TestSlackChannelGroup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	slackApi := mock_publisher.NewMock_slackApi(ctrl)
+
+	p, err := NewSlackChannel(&config.Slack{
+		Token:   "testToken",
+		Group:   true,
+		GroupBy: []string{"alertname"},
+		Channel: &config.SlackChannel{
+			ID: "testChannelID",
+		},
+	}, newMemoryDB(t))
+	assert.NoError(t, err)
+	p.(*slackChannel).cli = slackApi
+
+	ctx := context.Background()
+	newAlert := func(status, instance string) *types.AlertmanagerAlert {
+		return &types.AlertmanagerAlert{
+			StartsAt: "2023-07-15T21:37:23Z",
+			Status:   status,
+			Labels: map[string]string{
+				"alertname": "TestAlert",
+				"instance":  instance,
+			},
+		}
+	}
+
+	summaries := []slack_api.Attachment{}
+	captureSummary := func(options ...slack_api.MsgOption) {
+		_, values, err := slack_api.UnsafeApplyMsgOptions("", "", "", options...)
+		assert.NoError(t, err)
+		attachments := []slack_api.Attachment{}
+		assert.NoError(t, json.Unmarshal([]byte(values.Get("attachments")), &attachments))
+		summaries = append(summaries, attachments...)
+	}
+
+	// the first alert posts the summary, and then threads under it
+	gomock.InOrder(
+		slackApi.EXPECT().
+			PostMessage("testChannelID", gomock.Any()).
+			DoAndReturn(func(_ string, options ...slack_api.MsgOption) (string, string, error) {
+				assert.Len(t, options, 1)
+				captureSummary(options...)
+				return "", "summaryTS", nil
+			}),
+		slackApi.EXPECT().
+			PostMessage("testChannelID", gomock.Any()).
+			DoAndReturn(func(_ string, options ...slack_api.MsgOption) (string, string, error) {
+				assert.Len(t, options, 2) // threaded
+				return "", "message1TS", nil
+			}),
+	)
+	slackApi.EXPECT().AddReaction("rotating_light", slack_api.ItemRef{Channel: "testChannelID", Timestamp: "summaryTS"}).Return(nil).Times(2)
+	slackApi.EXPECT().RemoveReaction("white_check_mark", gomock.Any()).Return(nil).Times(2)
+	assert.NoError(t, p.Publish(ctx, "testSource", newAlert("firing", "a")))
+
+	// the second one updates the summary
+	slackApi.EXPECT().
+		UpdateMessage("testChannelID", "summaryTS", gomock.Any()).
+		DoAndReturn(func(_, _ string, options ...slack_api.MsgOption) (string, string, string, error) {
+			captureSummary(options...)
+			return "", "", "", nil
+		}).
+		Times(3)
+	slackApi.EXPECT().
+		PostMessage("testChannelID", gomock.Any()).
+		Return("", "message2TS", nil)
+	assert.NoError(t, p.Publish(ctx, "testSource", newAlert("firing", "b")))
+
+	// resolving all of them resolves the group
+	slackApi.EXPECT().
+		PostMessage("testChannelID", gomock.Any()).
+		Return("", "message3TS", nil).
+		Times(2)
+	slackApi.EXPECT().AddReaction("rotating_light", gomock.Any()).Return(nil)
+	slackApi.EXPECT().RemoveReaction("white_check_mark", gomock.Any()).Return(nil)
+	slackApi.EXPECT().AddReaction("white_check_mark", gomock.Any()).Return(nil)
+	slackApi.EXPECT().RemoveReaction("rotating_light", gomock.Any()).Return(nil)
+	assert.NoError(t, p.Publish(ctx, "testSource", newAlert("resolved", "a")))
+	assert.NoError(t, p.Publish(ctx, "testSource", newAlert("resolved", "b")))
+
+	if assert.Len(t, summaries, 4) {
+		assert.Equal(t, "FIRING: alertname=TestAlert (1)", summaries[0].Title)
+		assert.Equal(t, "FIRING: alertname=TestAlert (2)", summaries[1].Title)
+		assert.Equal(t, "• `instance=a`\n• `instance=b`\n", summaries[1].Text)
+		assert.Equal(t, "FIRING: alertname=TestAlert (1)", summaries[2].Title)
+		assert.Equal(t, "RESOLVED: alertname=TestAlert", summaries[3].Title)
+	}
+}
+
+func TestSlackChannelGroupResolvedFirst(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	slackApi := mock_publisher.NewMock_slackApi(ctrl)
+
+	p, err := NewSlackChannel(&config.Slack{
+		Token:   "testToken",
+		Group:   true,
+		GroupBy: []string{"alertname"},
+		Channel: &config.SlackChannel{
+			ID: "testChannelID",
+		},
+	}, newMemoryDB(t))
+	assert.NoError(t, err)
+	p.(*slackChannel).cli = slackApi
+
+	// no summary (nor reactions on it) for the group that never fired, just
+	// the alert itself
+	slackApi.EXPECT().
+		PostMessage("testChannelID", gomock.Any()).
+		DoAndReturn(func(_ string, options ...slack_api.MsgOption) (string, string, error) {
+			_, values, err := slack_api.UnsafeApplyMsgOptions("", "", "", options...)
+			assert.NoError(t, err)
+			assert.Empty(t, values.Get("thread_ts"))
+			assert.NotContains(t, values.Get("attachments"), "RESOLVED: alertname=TestAlert")
+			return "", "messageTS", nil
+		})
+
+	assert.NoError(t, p.Publish(context.Background(), "testSource", &types.AlertmanagerAlert{
+		StartsAt: "2023-07-15T21:37:23Z",
+		EndsAt:   "2023-07-15T22:37:23Z",
+		Status:   "resolved",
+		Labels: map[string]string{
+			"alertname": "TestAlert",
+			"instance":  "a",
+		},
+	}))
+}
+
+func TestSlackChannelGroupBlocks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	slackApi := mock_publisher.NewMock_slackApi(ctrl)
+
+	p, err := NewSlackChannel(&config.Slack{
+		Token:   "testToken",
+		Format:  config.SlackFormatBlocks,
+		Group:   true,
+		GroupBy: []string{"alertname"},
+		Channel: &config.SlackChannel{
+			ID: "testChannelID",
+		},
+		Templates: &config.SlackTemplates{
+			Color: `{{ if eq .Status "firing" }}#d40e0d{{ else }}#2eb886{{ end }}`,
+		},
+	}, newMemoryDB(t))
+	assert.NoError(t, err)
+	p.(*slackChannel).cli = slackApi
+
+	// the summary is rendered the same way as the alerts of the channel
+	gomock.InOrder(
+		slackApi.EXPECT().
+			PostMessage("testChannelID", gomock.Any()).
+			DoAndReturn(func(_ string, options ...slack_api.MsgOption) (string, string, error) {
+				_, values, err := slack_api.UnsafeApplyMsgOptions("", "", "", options...)
+				assert.NoError(t, err)
+				attachments := []slack_api.Attachment{}
+				assert.NoError(t, json.Unmarshal([]byte(values.Get("attachments")), &attachments))
+				if assert.Len(t, attachments, 1) {
+					assert.Equal(t, "#d40e0d", attachments[0].Color)
+					assert.Empty(t, attachments[0].Title)
+					if assert.Len(t, attachments[0].Blocks.BlockSet, 2) {
+						header := attachments[0].Blocks.BlockSet[0].(*slack_api.HeaderBlock)
+						assert.Equal(t, "FIRING: alertname=TestAlert (1)", header.Text.Text)
+						section := attachments[0].Blocks.BlockSet[1].(*slack_api.SectionBlock)
+						assert.Equal(t, "• `instance=a`\n", section.Text.Text)
+					}
+				}
+				return "", "summaryTS", nil
+			}),
+		slackApi.EXPECT().
+			PostMessage("testChannelID", gomock.Any()).
+			Return("", "messageTS", nil),
+	)
+	slackApi.EXPECT().AddReaction(gomock.Any(), gomock.Any()).Return(nil)
+	slackApi.EXPECT().RemoveReaction(gomock.Any(), gomock.Any()).Return(nil)
+
+	assert.NoError(t, p.Publish(context.Background(), "testSource", &types.AlertmanagerAlert{
+		StartsAt: "2023-07-15T21:37:23Z",
+		Status:   "firing",
+		Labels: map[string]string{
+			"alertname": "TestAlert",
+			"instance":  "a",
+		},
+	}))
+}
+
+func TestSlackChannelGroupFailedPublish(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	slackApi := mock_publisher.NewMock_slackApi(ctrl)
+
+	p, err := NewSlackChannel(&config.Slack{
+		Token:   "testToken",
+		Group:   true,
+		GroupBy: []string{"alertname"},
+		Channel: &config.SlackChannel{
+			ID: "testChannelID",
+		},
+	}, newMemoryDB(t))
+	assert.NoError(t, err)
+	p.(*slackChannel).cli = slackApi
+
+	ctx := context.Background()
+	newAlert := func(instance string) *types.AlertmanagerAlert {
+		return &types.AlertmanagerAlert{
+			StartsAt: "2023-07-15T21:37:23Z",
+			Status:   "firing",
+			Labels: map[string]string{
+				"alertname": "TestAlert",
+				"instance":  instance,
+			},
+		}
+	}
+
+	summaries := []slack_api.Attachment{}
+	captureSummary := func(options ...slack_api.MsgOption) {
+		_, values, err := slack_api.UnsafeApplyMsgOptions("", "", "", options...)
+		assert.NoError(t, err)
+		attachments := []slack_api.Attachment{}
+		assert.NoError(t, json.Unmarshal([]byte(values.Get("attachments")), &attachments))
+		summaries = append(summaries, attachments...)
+	}
+
+	// the summary is posted, but the alert itself is not
+	gomock.InOrder(
+		slackApi.EXPECT().
+			PostMessage("testChannelID", gomock.Any()).
+			DoAndReturn(func(_ string, options ...slack_api.MsgOption) (string, string, error) {
+				captureSummary(options...)
+				return "", "summaryTS", nil
+			}),
+		slackApi.EXPECT().
+			PostMessage("testChannelID", gomock.Any()).
+			Return("", "", assert.AnError).
+			Times(2), // including the emergency-publish
+	)
+	assert.Error(t, p.Publish(ctx, "testSource", newAlert("a")))
+
+	// only the timestamp of the summary is kept
+	groupKey := types.AlertmanagerAlert{Labels: map[string]string{"alertname": "TestAlert"}}.IncidentDedupKey()
+	state, err := p.(*slackChannel).db.Get(ctx, "testSource/testChannelID/group/"+groupKey)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"ts":"summaryTS","firing":null}`, state)
+
+	// the retry threads under the same summary, and the other alert of the
+	// group is not mistaken for the second one that fires
+	slackApi.EXPECT().
+		UpdateMessage("testChannelID", "summaryTS", gomock.Any()).
+		DoAndReturn(func(_, _ string, options ...slack_api.MsgOption) (string, string, string, error) {
+			captureSummary(options...)
+			return "", "", "", nil
+		}).
+		Times(2)
+	slackApi.EXPECT().
+		PostMessage("testChannelID", gomock.Any()).
+		Return("", "messageTS", nil).
+		Times(2)
+	slackApi.EXPECT().AddReaction(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+	slackApi.EXPECT().RemoveReaction(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+	assert.NoError(t, p.Publish(ctx, "testSource", newAlert("a")))
+	assert.NoError(t, p.Publish(ctx, "testSource", newAlert("b")))
+
+	if assert.Len(t, summaries, 3) {
+		assert.Equal(t, "FIRING: alertname=TestAlert (1)", summaries[0].Title)
+		assert.Equal(t, "FIRING: alertname=TestAlert (1)", summaries[1].Title)
+		assert.Equal(t, "FIRING: alertname=TestAlert (2)", summaries[2].Title)
+	}
+}
+
+func TestSlackChannelGroupConcurrentUpdates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	slackApi := mock_publisher.NewMock_slackApi(ctrl)
+
+	p, err := NewSlackChannel(&config.Slack{
+		Token:   "testToken",
+		Group:   true,
+		GroupBy: []string{"alertname"},
+		Channel: &config.SlackChannel{
+			ID: "testChannelID",
+		},
+	}, newMemoryDB(t))
+	assert.NoError(t, err)
+	p.(*slackChannel).cli = slackApi
+
+	mx := sync.Mutex{}
+	summaries := 0
+	lastSummary := slack_api.Attachment{}
+	captureSummary := func(options ...slack_api.MsgOption) {
+		_, values, err := slack_api.UnsafeApplyMsgOptions("", "", "", options...)
+		assert.NoError(t, err)
+		attachments := []slack_api.Attachment{}
+		assert.NoError(t, json.Unmarshal([]byte(values.Get("attachments")), &attachments))
+		mx.Lock()
+		lastSummary = attachments[0]
+		mx.Unlock()
+	}
+
+	slackApi.EXPECT().
+		PostMessage("testChannelID", gomock.Any()).
+		DoAndReturn(func(_ string, options ...slack_api.MsgOption) (string, string, error) {
+			if len(options) == 1 { // not threaded, so it's the summary
+				// give the others the chance to interfere
+				time.Sleep(20 * time.Millisecond)
+				mx.Lock()
+				summaries++
+				mx.Unlock()
+				captureSummary(options...)
+				return "", "summaryTS", nil
+			}
+			return "", "messageTS", nil
+		}).
+		AnyTimes()
+	slackApi.EXPECT().
+		UpdateMessage("testChannelID", "summaryTS", gomock.Any()).
+		DoAndReturn(func(_, _ string, options ...slack_api.MsgOption) (string, string, string, error) {
+			captureSummary(options...)
+			return "", "", "", nil
+		}).
+		AnyTimes()
+	slackApi.EXPECT().AddReaction(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	slackApi.EXPECT().RemoveReaction(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	wg := sync.WaitGroup{}
+	for _, instance := range []string{"a", "b", "c", "d"} {
+		wg.Go(func() {
+			assert.NoError(t, p.Publish(context.Background(), "testSource", &types.AlertmanagerAlert{
+				StartsAt: "2023-07-15T21:37:23Z",
+				Status:   "firing",
+				Labels: map[string]string{
+					"alertname": "TestAlert",
+					"instance":  instance,
+				},
+			}))
+		})
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, summaries, "the summary must be posted only once")
+	assert.Equal(t, "FIRING: alertname=TestAlert (4)", lastSummary.Title)
+}
+
+func TestSlackChannelMentions(t *testing.T) {
+	cfg := &config.Slack{
+		Token: "testToken",
+		Mentions: []*config.SlackMention{
+			{Severity: "critical", Here: true, UserGroups: []string{"S0ONCALL"}},
+			{MatchLabels: []string{`instance=~"Graf.*"`}, Users: []string{"U0ALICE"}, UserGroups: []string{"S0ONCALL"}},
+			{MatchLabels: []string{`team="infra"`}, Users: []string{"U0BOB"}},
+		},
+		Channel: &config.SlackChannel{
+			ID: "testChannelID",
+		},
+	}
+
+	p, err := NewSlackChannel(cfg, nil)
+	assert.NoError(t, err)
+	s := p.(*slackChannel)
+
+	assert.Equal(t, "<!here> <!subteam^S0ONCALL> <@U0ALICE>", s.mentionsFor(alertFiring, ""))
+	assert.Empty(t, s.mentionsFor(alertFiring, "testThreadTS"), "follow-ups must not mention")
+	assert.Empty(t, s.mentionsFor(alertResolved, ""), "resolved alerts must not mention")
+
+	// per-channel override
+	p, err = NewSlackChannel(cfg.ForChannel(&config.SlackChannel{
+		ID:       "otherChannelID",
+		Mentions: []*config.SlackMention{{Channel: true}},
+	}), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "<!channel>", p.(*slackChannel).mentionsFor(alertFiring, ""))
+}
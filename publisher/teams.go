@@ -0,0 +1,195 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/db"
+	"github.com/flashbots/amp-alerts-sink/logutils"
+	"github.com/flashbots/amp-alerts-sink/template"
+	"github.com/flashbots/amp-alerts-sink/types"
+	"go.uber.org/zap"
+)
+
+const (
+	teamsAdaptiveCardContentType = "application/vnd.microsoft.card.adaptive"
+	teamsAdaptiveCardSchema      = "http://adaptivecards.io/schemas/adaptive-card.json"
+	teamsAdaptiveCardVersion     = "1.4"
+)
+
+type teams struct {
+	url string
+
+	client httpClient
+	db     db.DB
+}
+
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string            `json:"contentType"`
+	Content     teamsAdaptiveCard `json:"content"`
+}
+
+type teamsAdaptiveCard struct {
+	Schema  string               `json:"$schema"`
+	Type    string               `json:"type"`
+	Version string               `json:"version"`
+	Body    []teamsCardElement   `json:"body"`
+	Actions []teamsCardAction    `json:"actions,omitempty"`
+	MSTeams *teamsCardProperties `json:"msteams,omitempty"`
+}
+
+type teamsCardElement struct {
+	Type   string `json:"type"`
+	Text   string `json:"text,omitempty"`
+	Color  string `json:"color,omitempty"`
+	Size   string `json:"size,omitempty"`
+	Weight string `json:"weight,omitempty"`
+	Wrap   bool   `json:"wrap,omitempty"`
+}
+
+type teamsCardAction struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+type teamsCardProperties struct {
+	Width string `json:"width,omitempty"`
+}
+
+func NewTeams(cfg *config.Teams, db db.DB) Publisher {
+	return &teams{
+		url: cfg.URL,
+
+		client: http.DefaultClient,
+		db:     db,
+	}
+}
+
+func (t *teams) Publish(
+	ctx context.Context,
+	source string,
+	alert *types.AlertmanagerAlert,
+) error {
+	l := logutils.LoggerFromContext(ctx)
+
+	return publishOnce(ctx, t.db, alert.MessageDedupKey(), timeoutTeamsExpiry, "teams", func() error {
+		if err := t.post(ctx, newTeamsMessage(ctx, template.NewData(source, alert))); err != nil {
+			return err
+		}
+		l.Info("Published alert to teams",
+			zap.Any("alert", alert),
+		)
+		return nil
+	})
+}
+
+// teamsTitle, teamsColor and teamsText render the card from the same data
+// (and with the same fields) as the messages of the other chat publishers
+// (the links are the actions of the card).
+var (
+	teamsTitle = template.Must(template.New("teams-title", chatTitle))
+
+	teamsColor = template.Must(template.New("teams-color",
+		`{{ if eq .Status "firing" }}`+
+			`{{ if eq .Labels.severity "critical" }}attention`+
+			`{{ else if eq .Labels.severity "warning" }}warning`+
+			`{{ else }}good{{ end }}`+
+			`{{ else }}good{{ end }}`,
+	))
+
+	teamsText = template.Must(template.New("teams-text", chatMarkdownDetails))
+)
+
+// newTeamsMessage builds the adaptive card of the alert.
+func newTeamsMessage(ctx context.Context, data *template.Data) *teamsMessage {
+	body := []teamsCardElement{{
+		Type:   "TextBlock",
+		Text:   strings.TrimSpace(renderChatText(ctx, teamsTitle, data)),
+		Color:  strings.TrimSpace(renderChatText(ctx, teamsColor, data)),
+		Size:   "large",
+		Weight: "bolder",
+		Wrap:   true,
+	}}
+
+	// cards do not respect single line breaks, so each line is a block
+	for _, line := range strings.Split(renderChatText(ctx, teamsText, data), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		body = append(body, teamsCardElement{
+			Type: "TextBlock",
+			Text: line,
+			Wrap: true,
+		})
+	}
+
+	actions := make([]teamsCardAction, 0, len(data.Links))
+	for _, link := range data.Links {
+		actions = append(actions, teamsCardAction{
+			Type:  "Action.OpenUrl",
+			Title: link.Text,
+			URL:   link.Href,
+		})
+	}
+
+	return &teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: teamsAdaptiveCardContentType,
+			Content: teamsAdaptiveCard{
+				Schema:  teamsAdaptiveCardSchema,
+				Type:    "AdaptiveCard",
+				Version: teamsAdaptiveCardVersion,
+				Body:    body,
+				Actions: actions,
+				MSTeams: &teamsCardProperties{Width: "Full"},
+			},
+		}},
+	}
+}
+
+// post sends the message (incoming webhooks respond with 200, workflows -
+// with 202).  The legacy connectors report failures with 200 as well, so for
+// them anything but "1" in the response body means the message was not
+// delivered (e.g. "Webhook message delivery failed with error: ...").
+func (t *teams) post(ctx context.Context, message *teamsMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal teams payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create teams request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var statusCode int
+	respBody, err := sendRequest(ctx, t.client, "teams", req, func(code int) bool {
+		statusCode = code
+		return code >= 200 && code < 300
+	})
+	if err != nil {
+		return err
+	}
+
+	if res := strings.TrimSpace(string(respBody)); statusCode == http.StatusOK && res != "" && res != "1" {
+		return &apiError{
+			name:       "teams",
+			statusCode: statusCode,
+			body:       res,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTeams(t *testing.T, status int) (Publisher, *[]teamsMessage) {
+	return setupTeamsWithResponse(t, status, "")
+}
+
+func setupTeamsWithResponse(t *testing.T, status int, response string) (Publisher, *[]teamsMessage) {
+	messages := []teamsMessage{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg := teamsMessage{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+		messages = append(messages, msg)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(srv.Close)
+
+	return NewTeams(&config.Teams{URL: srv.URL}, newMemoryDB(t)), &messages
+}
+
+func TestTeamsAdaptiveCard(t *testing.T) {
+	p, messages := setupTeams(t, http.StatusAccepted)
+
+	alert := &types.AlertmanagerAlert{
+		Status:       "firing",
+		StartsAt:     "2023-07-15T21:37:23Z",
+		GeneratorURL: "https://grafana/expr",
+		SilenceURL:   "https://grafana/silence",
+		Labels: map[string]string{
+			"alertname": "TestAlert",
+			"severity":  "critical",
+		},
+		Annotations: map[string]string{
+			"summary":     "Notification test",
+			"description": "Something is wrong",
+			"runbook_url": "https://runbook",
+		},
+	}
+	assert.NoError(t, p.Publish(context.Background(), "testSource", alert))
+
+	if !assert.Len(t, *messages, 1) || !assert.Len(t, (*messages)[0].Attachments, 1) {
+		return
+	}
+	attachment := (*messages)[0].Attachments[0]
+	assert.Equal(t, teamsAdaptiveCardContentType, attachment.ContentType)
+
+	card := attachment.Content
+	assert.Equal(t, "AdaptiveCard", card.Type)
+	texts := make([]string, 0, len(card.Body))
+	for _, element := range card.Body {
+		texts = append(texts, element.Text)
+	}
+	assert.Equal(t, []string{
+		"FIRING: TestAlert",
+		"Severity: `critical`",
+		"Summary: `Notification test`",
+		"Something is wrong",
+		"Started at: `2023-07-15T21:37:23Z`",
+	}, texts)
+	assert.Equal(t, "attention", card.Body[0].Color)
+	assert.Equal(t, []teamsCardAction{
+		{Type: "Action.OpenUrl", Title: "📕 Runbook", URL: "https://runbook"},
+		{Type: "Action.OpenUrl", Title: "📈 Expr", URL: "https://grafana/expr"},
+		{Type: "Action.OpenUrl", Title: "🔕 Silence", URL: "https://grafana/silence"},
+	}, card.Actions)
+}
+
+func TestTeamsDuplicate(t *testing.T) {
+	p, messages := setupTeams(t, http.StatusOK)
+	ctx := context.Background()
+
+	// another HA replica delivers the same alert
+	assert.NoError(t, p.Publish(ctx, "testSource", alertResolved))
+	assert.NoError(t, p.Publish(ctx, "anotherSource", alertResolved))
+	if assert.Len(t, *messages, 1) {
+		assert.Equal(t, "good", (*messages)[0].Attachments[0].Content.Body[0].Color)
+	}
+}
+
+func TestTeamsError(t *testing.T) {
+	p, _ := setupTeams(t, http.StatusBadRequest)
+
+	assert.Error(t, p.Publish(context.Background(), "testSource", alertFiring))
+}
+
+func TestTeamsConnectorResponse(t *testing.T) {
+	p, messages := setupTeamsWithResponse(t, http.StatusOK, "1")
+	assert.NoError(t, p.Publish(context.Background(), "testSource", alertFiring))
+	assert.Len(t, *messages, 1)
+
+	// legacy connectors report the failures with 200
+	p, messages = setupTeamsWithResponse(t, http.StatusOK,
+		"Webhook message delivery failed with error: Microsoft Teams endpoint returned HTTP error 413",
+	)
+	err := p.Publish(context.Background(), "testSource", alertFiring)
+	assert.ErrorContains(t, err, "Webhook message delivery failed")
+	assert.Len(t, *messages, 1)
+
+	// the failed alert is not marked as sent
+	assert.Error(t, p.Publish(context.Background(), "testSource", alertFiring))
+	assert.Len(t, *messages, 2)
+}
@@ -0,0 +1,111 @@
+package publisher
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/db"
+	"github.com/flashbots/amp-alerts-sink/template"
+)
+
+const (
+	telegramAPIURL = "https://api.telegram.org"
+
+	// telegramMessageLimit is the max length of message text as per telegram
+	// bot API docs
+	telegramMessageLimit = 4096
+)
+
+type telegram struct {
+	apiURL   string
+	botToken string
+	chatID   string
+
+	client httpClient
+}
+
+type telegramSendMessage struct {
+	ChatID                   string `json:"chat_id"`
+	Text                     string `json:"text"`
+	ParseMode                string `json:"parse_mode"`
+	DisableWebPagePreview    bool   `json:"disable_web_page_preview"`
+	ReplyToMessageID         int64  `json:"reply_to_message_id,omitempty"`
+	AllowSendingWithoutReply bool   `json:"allow_sending_without_reply,omitempty"`
+}
+
+type telegramResponse struct {
+	OK     bool `json:"ok"`
+	Result struct {
+		MessageID int64 `json:"message_id"`
+	} `json:"result"`
+}
+
+// telegramText is the text of telegram messages (in telegram's flavour of
+// html, hence all the escaping).
+var telegramText = template.Must(template.New("telegram-text",
+	`{{ if eq .Status "firing" }}🔥{{ else }}✅{{ end }} `+
+		"<b>{{ .Status | toUpper }}: {{ .Labels.alertname | html }}</b>\n"+
+		"{{ with .Labels.severity }}Severity: <code>{{ . | html }}</code>\n{{ end }}"+
+		"{{ with .Annotations.summary }}Summary: <code>{{ . | html }}</code>\n{{ end }}"+
+		"{{ with .Annotations.description }}\n{{ . | html }}\n\n{{ end }}"+
+		"{{ with .Annotations.message }}\n{{ . | html }}\n\n{{ end }}"+
+		"{{ with .StartsAt }}Started at: <code>{{ . | html }}</code>\n{{ end }}"+
+		"{{ with .Links }}\n"+
+		`{{ range $i, $link := . }}{{ if $i }} | {{ end }}<a href="{{ $link.Href | html }}">{{ $link.Text | html }}</a>{{ end }}`+"\n"+
+		"{{ end }}",
+))
+
+func NewTelegram(cfg *config.Telegram, db db.DB) Publisher {
+	apiURL := cfg.APIURL
+	if apiURL == "" {
+		apiURL = telegramAPIURL
+	}
+
+	return &chat{
+		name:      "telegram",
+		channelID: cfg.ChatID,
+
+		poster: &telegram{
+			apiURL:   strings.TrimSuffix(apiURL, "/"),
+			botToken: cfg.BotToken,
+			chatID:   cfg.ChatID,
+
+			client: http.DefaultClient,
+		},
+		db: db,
+	}
+}
+
+func (t *telegram) post(
+	ctx context.Context,
+	data *template.Data,
+	threadID string,
+) (string, error) {
+	msg := telegramSendMessage{
+		ChatID:                t.chatID,
+		Text:                  renderChatText(ctx, telegramText, data),
+		ParseMode:             "HTML",
+		DisableWebPagePreview: true,
+	}
+	if len(msg.Text) > telegramMessageLimit {
+		// cutting html in the middle would make telegram reject the message
+		msg.Text = data.Status + ": " + data.Labels["alertname"]
+		msg.ParseMode = ""
+	}
+	if replyTo, err := strconv.ParseInt(threadID, 10, 64); err == nil {
+		msg.ReplyToMessageID = replyTo
+		msg.AllowSendingWithoutReply = true
+	}
+
+	res := &telegramResponse{}
+	if err := postJSON(ctx, t.client, "telegram",
+		t.apiURL+"/bot"+t.botToken+"/sendMessage", nil, msg, res,
+	); err != nil {
+		return "", err
+	}
+
+	return strconv.FormatInt(res.Result.MessageID, 10), nil
+}
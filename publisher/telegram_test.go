@@ -0,0 +1,68 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/template"
+	"github.com/flashbots/amp-alerts-sink/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTelegramReplies(t *testing.T) {
+	messages := []telegramSendMessage{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/bottheToken/sendMessage", r.URL.Path)
+		msg := telegramSendMessage{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+		messages = append(messages, msg)
+		fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d}}`, 100+len(messages))
+	}))
+	defer srv.Close()
+
+	p := NewTelegram(&config.Telegram{
+		APIURL:   srv.URL,
+		BotToken: "theToken",
+		ChatID:   "-1001",
+	}, newMemoryDB(t))
+
+	ctx := context.Background()
+	assert.NoError(t, p.Publish(ctx, "testSource", alertFiring))
+	assert.NoError(t, p.Publish(ctx, "testSource", alertFiring))
+
+	resolved := *alertFiring
+	resolved.Status = "resolved"
+	assert.NoError(t, p.Publish(ctx, "testSource", &resolved))
+
+	if !assert.Len(t, messages, 2) {
+		return
+	}
+	assert.Equal(t, "-1001", messages[0].ChatID)
+	assert.Equal(t, "HTML", messages[0].ParseMode)
+	assert.Contains(t, messages[0].Text, "<b>FIRING: TestAlert</b>")
+	assert.Zero(t, messages[0].ReplyToMessageID)
+	assert.Contains(t, messages[1].Text, "<b>RESOLVED: TestAlert</b>")
+	assert.Equal(t, int64(101), messages[1].ReplyToMessageID)
+}
+
+func TestTelegramEscaping(t *testing.T) {
+	text, err := telegramText.Execute(template.NewData("testSource", &types.AlertmanagerAlert{
+		Status:      "firing",
+		Labels:      map[string]string{"alertname": "<script>"},
+		Annotations: map[string]string{"summary": "a & b"},
+	}))
+	assert.NoError(t, err)
+	assert.Contains(t, text, "<b>FIRING: &lt;script&gt;</b>")
+	assert.Contains(t, text, "<code>a &amp; b</code>")
+
+	data := template.NewData("testSource", &types.AlertmanagerAlert{Status: "firing"})
+	data.Links = []template.Link{{Href: "https://example.com/?a=1&b=2", Text: "<R&D>"}}
+	text, err = telegramText.Execute(data)
+	assert.NoError(t, err)
+	assert.Contains(t, text, `<a href="https://example.com/?a=1&amp;b=2">&lt;R&amp;D&gt;</a>`)
+}
@@ -0,0 +1,11 @@
+package publisher
+
+// truncate makes sure the text fits into the limit (in runes) of destination,
+// and marks the cut with ellipsis.
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
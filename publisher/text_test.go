@@ -0,0 +1,24 @@
+package publisher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncate(t *testing.T) {
+	for _, tc := range []struct {
+		text     string
+		limit    int
+		expected string
+	}{
+		{"short", 10, "short"},
+		{"exactly10!", 10, "exactly10!"},
+		{"a bit too long", 10, "a bit too…"},
+		{"ünïcödé ✅✅✅", 8, "ünïcödé…"},
+	} {
+		res := truncate(tc.text, tc.limit)
+		assert.Equal(t, tc.expected, res)
+		assert.LessOrEqual(t, len([]rune(res)), tc.limit)
+	}
+}
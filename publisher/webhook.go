@@ -5,23 +5,51 @@ package publisher
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/flashbots/amp-alerts-sink/config"
 	"github.com/flashbots/amp-alerts-sink/db"
 	"github.com/flashbots/amp-alerts-sink/logutils"
+	"github.com/flashbots/amp-alerts-sink/matcher"
+	"github.com/flashbots/amp-alerts-sink/template"
 	"github.com/flashbots/amp-alerts-sink/types"
 	"go.uber.org/zap"
 )
 
 type webhook struct {
+	name     string
 	url      string
 	method   string
 	sendBody bool
 
+	matchLabels     matcher.Matchers
+	timeout         time.Duration
+	successStatuses []int
+
+	contentType string
+	format      string
+	template    *template.Template
+
+	headers           http.Header
+	basicAuthUsername string
+	basicAuthPassword string
+	bearerToken       string
+	signingSecret     []byte
+
 	client httpClient
 	db     db.DB
 }
@@ -30,20 +58,104 @@ type httpClient interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
-func NewWebhook(cfg *config.Webhook, db db.DB) Publisher {
+// cloudEvent is CloudEvents 1.0 envelope in structured (json) mode.
+type cloudEvent struct {
+	SpecVersion     string                   `json:"specversion"`
+	ID              string                   `json:"id"`
+	Source          string                   `json:"source"`
+	Type            string                   `json:"type"`
+	Subject         string                   `json:"subject,omitempty"`
+	Time            string                   `json:"time,omitempty"`
+	DataContentType string                   `json:"datacontenttype"`
+	Data            *types.AlertmanagerAlert `json:"data"`
+}
+
+const (
+	cloudEventContentType = "application/cloudevents+json"
+	cloudEventTypePrefix  = "net.flashbots.amp-alerts-sink.alert."
+
+	// webhookSignatureHeader carries hex-encoded HMAC-SHA256 of the request
+	// (timestamp, dot, and the body) signed with the signing secret
+	webhookSignatureHeader = "X-Amp-Alerts-Sink-Signature"
+	webhookTimestampHeader = "X-Amp-Alerts-Sink-Timestamp"
+)
+
+func NewWebhook(cfg *config.Webhook, db db.DB) (Publisher, error) {
 	method := cfg.Method
 	if method == "" {
 		method = "POST"
 	}
 
+	matchLabels, err := cfg.MatchLabelsMatchers()
+	if err != nil {
+		return nil, err
+	}
+
+	format := cfg.Format
+	if format == "" {
+		format = config.WebhookFormatAlertmanager
+	}
+
+	var tmpl *template.Template
+	if format == config.WebhookFormatTemplate {
+		var err error
+		if tmpl, err = template.New("webhook", cfg.Template); err != nil {
+			return nil, err
+		}
+	}
+
+	contentType := cfg.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	if format == config.WebhookFormatCloudEvents {
+		contentType = cloudEventContentType
+	}
+
+	headers := http.Header{}
+	for k, v := range cfg.Headers {
+		headers.Set(k, v)
+	}
+
+	client := http.DefaultClient
+	if cfg.ClientCert != "" || cfg.CACert != "" {
+		tlsConfig, err := newWebhookTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		client = &http.Client{Transport: transport}
+	}
+
+	var signingSecret []byte
+	if cfg.SigningSecret != "" {
+		signingSecret = []byte(cfg.SigningSecret)
+	}
+
 	return &webhook{
+		name:     cfg.PublisherName(),
 		url:      cfg.URL,
 		method:   method,
 		sendBody: cfg.SendBody,
 
-		client: http.DefaultClient,
+		matchLabels:     matchLabels,
+		timeout:         cfg.Timeout,
+		successStatuses: cfg.SuccessStatuses,
+
+		contentType: contentType,
+		format:      format,
+		template:    tmpl,
+
+		headers:           headers,
+		basicAuthUsername: cfg.BasicAuthUsername,
+		basicAuthPassword: cfg.BasicAuthPassword,
+		bearerToken:       cfg.BearerToken,
+		signingSecret:     signingSecret,
+
+		client: client,
 		db:     db,
-	}
+	}, nil
 }
 
 func (w *webhook) Publish(
@@ -52,71 +164,56 @@ func (w *webhook) Publish(
 	alert *types.AlertmanagerAlert,
 ) error {
 	l := logutils.LoggerFromContext(ctx)
-	l.Info("Publishing alert", zap.Any("alert", alert))
 
-	isDup, err := w.checkDupAndLock(ctx, alert)
-	if isDup {
-		// Enter this branch even with non-nil err;
-		// Only for ErrAlreadyLocked, so that lambda execution will be restarted
-		l.Info("Duplicate alert detected", zap.Error(err))
-		return err
-	}
-	// not a duplicate
-	if err != nil {
-		l.Error("Failed to check for duplicate alert, sending webhook", zap.Error(err))
+	// skip alerts that are not meant for this webhook
+	if mismatch := w.matchLabels.Mismatch(alert.Labels); mismatch != nil {
+		l.Debug("Skipped the alert due to webhook's label mismatch",
+			zap.String("webhook", w.name),
+			zap.String("matcher", mismatch.String()),
+		)
+		return nil
 	}
 
-	err = w.sendWebhook(ctx, source, alert)
-	if err != nil {
-		l.Error("Failed to send alert", zap.Error(err))
-	} else {
-		// sent correctly, prevent other instances from sending
-		_ = w.db.Set(ctx, alert.MessageDedupKey(), timeoutWebhookExpiry, "1")
-	}
-	return err
-}
+	l.Info("Publishing alert", zap.Any("alert", alert))
 
-func (w *webhook) checkDupAndLock(ctx context.Context, alert *types.AlertmanagerAlert) (isDup bool, err error) {
-	v, err := w.db.Get(ctx, alert.MessageDedupKey())
-	if err != nil {
-		return false, fmt.Errorf("failed to check for duplicate alert: %w", err)
-	}
-	if v != "" {
-		return true, nil
+	var body []byte
+	if w.sendBody {
+		var err error
+		if body, err = w.encodeAlert(ctx, source, alert); err != nil {
+			l.Error("Failed to encode alert", zap.Error(err))
+			return err
+		}
 	}
 
-	didLock, err := w.db.Lock(ctx, alert.MessageDedupKey(), timeoutLock)
-	if err != nil {
-		return false, fmt.Errorf("failed to lock alert: %w", err)
-	}
-	if !didLock {
-		// another instance is about to publish
-		return true, ErrAlreadyLocked
+	// raw message carries all of its alerts, so it's sent only once
+	dedupKey := alert.MessageDedupKey()
+	if w.sendBody && w.format == config.WebhookFormatRaw {
+		bodyHash := sha256.Sum256(body)
+		dedupKey = hex.EncodeToString(bodyHash[:])
 	}
 
-	return false, nil
+	return publishOnce(ctx, w.db, dedupKey, timeoutWebhookExpiry, "webhook", func() error {
+		return w.sendWebhook(ctx, alert, body)
+	})
 }
 
 func (w *webhook) sendWebhook(
 	ctx context.Context,
-	source string,
 	alert *types.AlertmanagerAlert,
+	body []byte,
 ) error {
 	l := logutils.LoggerFromContext(ctx)
 
 	var reqBody io.Reader
-	var contentType string
-
 	if w.sendBody {
-		buf, err := w.encodeAlert(source, alert)
-		if err != nil {
-			l.Error("Failed to encode alert", zap.Error(err))
-			return err
-		}
-		l.Debug("Webhook payload", zap.ByteString("body", buf.Bytes()))
+		l.Debug("Webhook payload", zap.ByteString("body", body))
+		reqBody = bytes.NewReader(body)
+	}
 
-		reqBody = buf
-		contentType = "application/json"
+	if w.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.timeout)
+		defer cancel()
 	}
 
 	req, err := http.NewRequestWithContext(ctx, w.method, w.url, reqBody)
@@ -125,35 +222,36 @@ func (w *webhook) sendWebhook(
 		return fmt.Errorf("failed to create webhook request: %w", err)
 	}
 
-	if contentType != "" {
-		req.Header.Set("Content-Type", contentType)
+	for k, vs := range w.headers {
+		req.Header[k] = vs
+	}
+	if w.sendBody {
+		req.Header.Set("Content-Type", w.contentType)
+	}
+	switch {
+	case w.bearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+w.bearerToken)
+	case w.basicAuthUsername != "" || w.basicAuthPassword != "":
+		req.SetBasicAuth(w.basicAuthUsername, w.basicAuthPassword)
+	}
+	if w.signingSecret != nil {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(webhookTimestampHeader, timestamp)
+		req.Header.Set(webhookSignatureHeader, "sha256="+signWebhook(w.signingSecret, timestamp, body))
 	}
 
 	l.Info("Sending webhook request",
+		zap.String("webhook", w.name),
 		zap.String("url", w.url),
 		zap.String("method", w.method),
 		zap.Bool("send_body", w.sendBody),
+		zap.String("format", w.format),
 		zap.String("alert_fingerprint", alert.MessageDedupKey()),
 	)
 
-	resp, err := w.client.Do(req)
+	respBody, err := sendRequest(ctx, w.client, "webhook", req, w.isSuccess)
 	if err != nil {
-		l.Error("Webhook request failed", zap.Error(err))
-		return fmt.Errorf("webhook request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, readErr := io.ReadAll(resp.Body)
-	if readErr != nil {
-		l.Warn("Failed to read webhook response body", zap.Error(readErr))
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		l.Error("Webhook returned non-200 status",
-			zap.Int("status_code", resp.StatusCode),
-			zap.String("response_body", string(respBody)),
-		)
-		return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, resp.Status)
+		return err
 	}
 
 	l.Info("Successfully published alert to webhook",
@@ -162,22 +260,145 @@ func (w *webhook) sendWebhook(
 	return nil
 }
 
-func (w *webhook) encodeAlert(source string, alert *types.AlertmanagerAlert) (*bytes.Buffer, error) {
-	body := types.AlertmanagerWebhook{
-		Version:  "4",
-		GroupKey: alert.MessageDedupKey(),
+// isSuccess tells whether the response status means the alert was delivered.
+func (w *webhook) isSuccess(statusCode int) bool {
+	if len(w.successStatuses) == 0 {
+		return statusCode >= 200 && statusCode < 300
+	}
+	return slices.Contains(w.successStatuses, statusCode)
+}
 
-		AlertmanagerMessage: types.AlertmanagerMessage{
+// encodeAlert returns the request body in the configured format.
+func (w *webhook) encodeAlert(
+	ctx context.Context,
+	source string,
+	alert *types.AlertmanagerAlert,
+) ([]byte, error) {
+	switch w.format {
+	case config.WebhookFormatCloudEvents:
+		return w.encodeCloudEvent(source, alert)
+	case config.WebhookFormatRaw:
+		if raw := rawMessageFromContext(ctx); raw != nil {
+			return raw, nil
+		}
+		// no original message (e.g. when replayed from dead-letter sink)
+		return marshalWebhookPayload(types.AlertmanagerMessage{
 			Receiver: source,
 			Status:   alert.Status,
 			Alerts:   []types.AlertmanagerAlert{*alert},
-		},
+		})
+	case config.WebhookFormatTemplate:
+		body, err := w.template.Execute(template.NewData(source, alert))
+		if err != nil {
+			return nil, err
+		}
+		return []byte(body), nil
+	default:
+		return marshalWebhookPayload(types.AlertmanagerWebhook{
+			Version:  "4",
+			GroupKey: alert.MessageDedupKey(),
+
+			AlertmanagerMessage: types.AlertmanagerMessage{
+				Receiver: source,
+				Status:   alert.Status,
+				Alerts:   []types.AlertmanagerAlert{*alert},
+			},
+		})
+	}
+}
+
+func (w *webhook) encodeCloudEvent(source string, alert *types.AlertmanagerAlert) ([]byte, error) {
+	event := cloudEvent{
+		SpecVersion:     "1.0",
+		ID:              alert.MessageDedupKey(),
+		Source:          source,
+		Type:            cloudEventTypePrefix + alert.Status,
+		Subject:         alert.Labels["alertname"],
+		DataContentType: "application/json",
+		Data:            alert,
+	}
+	if alert.Status == "resolved" && alert.EndsAt != "" {
+		event.Time = alert.EndsAt
+	} else if alert.StartsAt != "" {
+		event.Time = alert.StartsAt
 	}
 
+	return marshalWebhookPayload(event)
+}
+
+func marshalWebhookPayload(payload any) ([]byte, error) {
 	buf := &bytes.Buffer{}
-	if err := json.NewEncoder(buf).Encode(body); err != nil {
+	if err := json.NewEncoder(buf).Encode(payload); err != nil {
 		return nil, fmt.Errorf("failed to marshal webhook payload: %w", err)
 	}
+	return buf.Bytes(), nil
+}
+
+// signWebhook returns hex-encoded HMAC-SHA256 of the timestamp and the body.
+func signWebhook(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newWebhookTLSConfig returns tls config with the client certificate and
+// custom CAs (if configured).
+func newWebhookTLSConfig(cfg *config.Webhook) (*tls.Config, error) {
+	res := &tls.Config{}
+
+	if cfg.ClientCert != "" {
+		certPEM, err := loadPEM(cfg.ClientCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load webhook client certificate: %w", err)
+		}
+		keyPEM, err := loadPEM(cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load webhook client key: %w", err)
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook client certificate: %w", err)
+		}
+		res.Certificates = []tls.Certificate{cert}
+	}
 
-	return buf, nil
+	if cfg.CACert != "" {
+		caPEM, err := loadPEM(cfg.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load webhook ca certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("invalid webhook ca certificate")
+		}
+		res.RootCAs = pool
+	}
+
+	return res, nil
+}
+
+// loadPEM returns the value as-is if it's PEM-encoded already, or reads it
+// from the file otherwise.
+func loadPEM(value string) ([]byte, error) {
+	if strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") {
+		return []byte(value), nil
+	}
+	return os.ReadFile(value)
+}
+
+// parseRetryAfter parses the value of http Retry-After header (which is either
+// the number of seconds, or http date).
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		return time.Until(t)
+	}
+	return 0
 }
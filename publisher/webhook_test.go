@@ -3,10 +3,25 @@ package publisher
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"io"
+	"math/big"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/flashbots/amp-alerts-sink/config"
 	mock_db "github.com/flashbots/amp-alerts-sink/mock/db"
@@ -23,11 +38,12 @@ func setupWebhookPublisher(t *testing.T) (
 	db := mock_db.NewMockDB(ctrl)
 	httpClient := mock_publisher.NewMock_httpClient(ctrl)
 
-	wh := NewWebhook(&config.Webhook{
+	wh, err := NewWebhook(&config.Webhook{
 		URL:      "https://example.com/webhook",
 		Method:   "POST",
 		SendBody: true,
 	}, db)
+	assert.NoError(t, err)
 
 	_webhook := wh.(*webhook)
 	_webhook.client = httpClient
@@ -91,11 +107,12 @@ func TestWebhookWithoutBody(t *testing.T) {
 	db := mock_db.NewMockDB(ctrl)
 	httpClient := mock_publisher.NewMock_httpClient(ctrl)
 
-	wh := NewWebhook(&config.Webhook{
+	wh, err := NewWebhook(&config.Webhook{
 		URL:      "https://example.com/webhook",
 		Method:   "GET",
 		SendBody: false,
 	}, db)
+	assert.NoError(t, err)
 
 	_webhook := wh.(*webhook)
 	_webhook.client = httpClient
@@ -139,7 +156,7 @@ func TestWebhookWithoutBody(t *testing.T) {
 		Set(ctx, alert.MessageDedupKey(), timeoutWebhookExpiry, "1").
 		Return(nil)
 
-	err := wh.Publish(ctx, "testSource", alert)
+	err = wh.Publish(ctx, "testSource", alert)
 	assert.NoError(t, err)
 }
 
@@ -199,6 +216,11 @@ func TestWebhookHTTPError(t *testing.T) {
 			Body:       io.NopCloser(bytes.NewBufferString(`{"error": "internal server error"}`)),
 		}, nil)
 
+	// Release the lock, so that the alert can be retried
+	db.EXPECT().
+		Unlock(ctx, alert.MessageDedupKey()).
+		Return(nil)
+
 	err := p.Publish(ctx, "testSource", alert)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "webhook returned status 500")
@@ -224,6 +246,11 @@ func TestWebhookNetworkError(t *testing.T) {
 		Do(gomock.Any()).
 		Return(nil, assert.AnError)
 
+	// Release the lock, so that the alert can be retried
+	db.EXPECT().
+		Unlock(ctx, alert.MessageDedupKey()).
+		Return(nil)
+
 	err := p.Publish(ctx, "testSource", alert)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "webhook request failed")
@@ -243,11 +270,12 @@ func TestWebhookDifferentMethods(t *testing.T) {
 			db := mock_db.NewMockDB(ctrl)
 			httpClient := mock_publisher.NewMock_httpClient(ctrl)
 
-			wh := NewWebhook(&config.Webhook{
+			wh, err := NewWebhook(&config.Webhook{
 				URL:      "https://example.com/webhook",
 				Method:   tc,
 				SendBody: true,
 			}, db)
+			assert.NoError(t, err)
 
 			_webhook := wh.(*webhook)
 			_webhook.client = httpClient
@@ -281,7 +309,7 @@ func TestWebhookDifferentMethods(t *testing.T) {
 				Set(ctx, alert.MessageDedupKey(), timeoutWebhookExpiry, "1").
 				Return(nil)
 
-			err := wh.Publish(ctx, "testSource", alert)
+			err = wh.Publish(ctx, "testSource", alert)
 			assert.NoError(t, err)
 		})
 	}
@@ -292,10 +320,11 @@ func TestWebhookDefaultMethod(t *testing.T) {
 	db := mock_db.NewMockDB(ctrl)
 
 	// Create webhook without specifying method
-	wh := NewWebhook(&config.Webhook{
+	wh, err := NewWebhook(&config.Webhook{
 		URL:      "https://example.com/webhook",
 		SendBody: true,
 	}, db)
+	assert.NoError(t, err)
 
 	_webhook := wh.(*webhook)
 	assert.Equal(t, "POST", _webhook.method)
@@ -372,3 +401,292 @@ func TestWebhookDBErrorContinuesWithSend(t *testing.T) {
 	err := p.Publish(ctx, "testSource", alert)
 	assert.NoError(t, err)
 }
+
+func TestWebhookRetryAfter(t *testing.T) {
+	p, db, httpClient := setupWebhookPublisher(t)
+	ctx := context.Background()
+	alert := alertFiring
+
+	db.EXPECT().
+		Get(ctx, alert.MessageDedupKey()).
+		Return("", nil)
+
+	db.EXPECT().
+		Lock(ctx, alert.MessageDedupKey(), timeoutLock).
+		Return(true, nil)
+
+	httpClient.EXPECT().
+		Do(gomock.Any()).
+		Return(&http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Header:     http.Header{"Retry-After": []string{"42"}},
+			Body:       io.NopCloser(bytes.NewBufferString("")),
+		}, nil)
+
+	// Release the lock, so that the alert can be retried
+	db.EXPECT().
+		Unlock(ctx, alert.MessageDedupKey()).
+		Return(nil)
+
+	err := p.Publish(ctx, "testSource", alert)
+	assert.Error(t, err)
+	assert.Equal(t, 42*time.Second, retryAfter(err))
+}
+
+func setupWebhookFormat(t *testing.T, cfg *config.Webhook) (Publisher, *[]*http.Request, *[][]byte) {
+	ctrl := gomock.NewController(t)
+	httpClient := mock_publisher.NewMock_httpClient(ctrl)
+
+	cfg.URL = "https://example.com/webhook"
+	cfg.SendBody = true
+	wh, err := NewWebhook(cfg, newMemoryDB(t))
+	assert.NoError(t, err)
+	wh.(*webhook).client = httpClient
+
+	requests := []*http.Request{}
+	bodies := [][]byte{}
+	httpClient.EXPECT().
+		Do(gomock.Any()).
+		DoAndReturn(func(req *http.Request) (*http.Response, error) {
+			body, err := io.ReadAll(req.Body)
+			assert.NoError(t, err)
+			requests = append(requests, req)
+			bodies = append(bodies, body)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString("")),
+			}, nil
+		}).
+		AnyTimes()
+
+	return wh, &requests, &bodies
+}
+
+func TestWebhookCloudEvents(t *testing.T) {
+	p, requests, bodies := setupWebhookFormat(t, &config.Webhook{
+		Format: config.WebhookFormatCloudEvents,
+	})
+
+	assert.NoError(t, p.Publish(context.Background(), "testSource", alertFiring))
+	if !assert.Len(t, *bodies, 1) {
+		return
+	}
+	assert.Equal(t, "application/cloudevents+json", (*requests)[0].Header.Get("Content-Type"))
+
+	event := map[string]any{}
+	assert.NoError(t, json.Unmarshal((*bodies)[0], &event))
+	assert.Equal(t, "1.0", event["specversion"])
+	assert.Equal(t, alertFiring.MessageDedupKey(), event["id"])
+	assert.Equal(t, "testSource", event["source"])
+	assert.Equal(t, "net.flashbots.amp-alerts-sink.alert.firing", event["type"])
+	assert.Equal(t, "TestAlert", event["subject"])
+	assert.Equal(t, alertFiring.StartsAt, event["time"])
+	assert.Equal(t, "firing", event["data"].(map[string]any)["status"])
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	p, requests, bodies := setupWebhookFormat(t, &config.Webhook{
+		Format:      config.WebhookFormatTemplate,
+		Template:    `{"title": {{ printf "%s: %s" .Labels.alertname .Annotations.summary | toJson }}, "source": {{ .Source | toJson }}}`,
+		ContentType: "application/vnd.ticket+json",
+	})
+
+	assert.NoError(t, p.Publish(context.Background(), "testSource", alertFiring))
+	if !assert.Len(t, *bodies, 1) {
+		return
+	}
+	assert.Equal(t, "application/vnd.ticket+json", (*requests)[0].Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"title": "TestAlert: Notification test", "source": "testSource"}`, string((*bodies)[0]))
+}
+
+func TestWebhookRawMessage(t *testing.T) {
+	p, _, bodies := setupWebhookFormat(t, &config.Webhook{
+		Format: config.WebhookFormatRaw,
+	})
+
+	raw := json.RawMessage(`{"receiver":"sns","status":"firing","alerts":[{},{}]}`)
+	ctx := ContextWithRawMessage(context.Background(), raw)
+
+	// both alerts of the message are forwarded in one request
+	assert.NoError(t, p.Publish(ctx, "testSource", alertFiring))
+	assert.NoError(t, p.Publish(ctx, "testSource", alertResolved))
+	if assert.Len(t, *bodies, 1) {
+		assert.Equal(t, string(raw), string((*bodies)[0]))
+	}
+
+	// without the original message, the alert is wrapped into one
+	assert.NoError(t, p.Publish(context.Background(), "testSource", alertResolved))
+	if assert.Len(t, *bodies, 2) {
+		message := types.AlertmanagerMessage{}
+		assert.NoError(t, json.Unmarshal((*bodies)[1], &message))
+		assert.Equal(t, "resolved", message.Status)
+		assert.Len(t, message.Alerts, 1)
+	}
+}
+
+func TestWebhookHeadersAndSignature(t *testing.T) {
+	p, requests, bodies := setupWebhookFormat(t, &config.Webhook{
+		Headers:       map[string]string{"X-Team": "infra"},
+		BearerToken:   "theToken",
+		SigningSecret: "theSecret",
+	})
+
+	assert.NoError(t, p.Publish(context.Background(), "testSource", alertFiring))
+	if !assert.Len(t, *requests, 1) {
+		return
+	}
+	req := (*requests)[0]
+	assert.Equal(t, "infra", req.Header.Get("X-Team"))
+	assert.Equal(t, "Bearer theToken", req.Header.Get("Authorization"))
+
+	timestamp := req.Header.Get(webhookTimestampHeader)
+	assert.NotEmpty(t, timestamp)
+
+	mac := hmac.New(sha256.New, []byte("theSecret"))
+	mac.Write([]byte(timestamp + "." + string((*bodies)[0])))
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), req.Header.Get(webhookSignatureHeader))
+}
+
+func TestWebhookBasicAuth(t *testing.T) {
+	p, requests, _ := setupWebhookFormat(t, &config.Webhook{
+		BasicAuthUsername: "user",
+		BasicAuthPassword: "pass",
+	})
+
+	assert.NoError(t, p.Publish(context.Background(), "testSource", alertFiring))
+	if assert.Len(t, *requests, 1) {
+		username, password, ok := (*requests)[0].BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "user", username)
+		assert.Equal(t, "pass", password)
+		assert.Empty(t, (*requests)[0].Header.Get(webhookSignatureHeader))
+	}
+}
+
+func TestWebhookClientCertificate(t *testing.T) {
+	clientCert, clientKey := newTestCertificate(t)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if assert.Len(t, r.TLS.PeerCertificates, 1) {
+			assert.Equal(t, "amp-alerts-sink", r.TLS.PeerCertificates[0].Subject.CommonName)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	assert.NoError(t, os.WriteFile(caFile, caCert, 0o600))
+
+	p, err := NewWebhook(&config.Webhook{
+		URL:        srv.URL,
+		SendBody:   true,
+		CACert:     caFile,
+		ClientCert: string(clientCert),
+		ClientKey:  string(clientKey),
+	}, newMemoryDB(t))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, p.Publish(context.Background(), "testSource", alertFiring))
+}
+
+func TestWebhookSuccessStatuses(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		successStatuses []int
+		statusCode      int
+		success         bool
+	}{
+		{"default accepts 200", nil, http.StatusOK, true},
+		{"default accepts 202", nil, http.StatusAccepted, true},
+		{"default accepts 204", nil, http.StatusNoContent, true},
+		{"default rejects 302", nil, http.StatusFound, false},
+		{"custom accepts 409", []int{http.StatusOK, http.StatusConflict}, http.StatusConflict, true},
+		{"custom rejects 201", []int{http.StatusOK}, http.StatusCreated, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.statusCode)
+			}))
+			defer srv.Close()
+
+			p, err := NewWebhook(&config.Webhook{
+				URL:             srv.URL,
+				SuccessStatuses: tc.successStatuses,
+			}, newMemoryDB(t))
+			assert.NoError(t, err)
+
+			err = p.Publish(context.Background(), "testSource", alertFiring)
+			if tc.success {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, "webhook returned status")
+			}
+		})
+	}
+}
+
+func TestWebhookMatchLabels(t *testing.T) {
+	p, requests, _ := setupWebhookFormat(t, &config.Webhook{
+		MatchLabels: []string{"severity=~critical|error"},
+	})
+
+	assert.NoError(t, p.Publish(context.Background(), "testSource", alertFiring))
+	assert.NoError(t, p.Publish(context.Background(), "testSource", &types.AlertmanagerAlert{
+		Status: "firing",
+		Labels: map[string]string{"alertname": "Noise", "severity": "info"},
+	}))
+	assert.Len(t, *requests, 1)
+}
+
+func TestWebhookTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	p, err := NewWebhook(&config.Webhook{
+		URL:     srv.URL,
+		Timeout: 50 * time.Millisecond,
+	}, newMemoryDB(t))
+	assert.NoError(t, err)
+
+	start := time.Now()
+	err = p.Publish(context.Background(), "testSource", alertFiring)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+// newTestCertificate returns PEM-encoded self-signed certificate and its key.
+func newTestCertificate(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "amp-alerts-sink"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
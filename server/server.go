@@ -0,0 +1,315 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/logutils"
+	"github.com/flashbots/amp-alerts-sink/types"
+	"go.uber.org/zap"
+)
+
+const (
+	maxRequestBodySize = 4 << 20
+
+	timeoutRead      = 30 * time.Second
+	timeoutShutdown  = 30 * time.Second
+	timeoutSubscribe = 10 * time.Second
+)
+
+var (
+	ErrSnsInvalidSubscribeURL = errors.New("invalid sns subscribe url")
+	ErrSnsSubscriptionFailed  = errors.New("failed to confirm sns subscription")
+	ErrSnsTopicNotAllowed     = errors.New("sns topic is not allowed")
+	ErrUnauthorized           = errors.New("unauthorized")
+)
+
+type Server struct {
+	cfg *config.Server
+
+	client            *http.Client
+	processor         alertsProcessor
+	slackInteractions http.Handler
+
+	snsCerts sync.Map // signing certificate url -> *x509.Certificate
+}
+
+type alertsProcessor interface {
+	ProcessAlertmanagerWebhook(ctx context.Context, source string, message *types.AlertmanagerWebhook, raw []byte) error
+	ProcessSnsMessage(ctx context.Context, topicArn, message string) error
+}
+
+// shutdowner is implemented by the handlers that keep working on the requests
+// in the background after having responded to them.
+type shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// snsMessage is the notification that SNS posts to HTTP subscribers.
+type snsMessage struct {
+	Type         string `json:"Type"`
+	MessageID    string `json:"MessageId"`
+	TopicArn     string `json:"TopicArn"`
+	Subject      string `json:"Subject,omitempty"`
+	Message      string `json:"Message"`
+	Timestamp    string `json:"Timestamp"`
+	Token        string `json:"Token,omitempty"`
+	SubscribeURL string `json:"SubscribeURL,omitempty"`
+
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+}
+
+// New returns the server.  The handler of slack interactions is optional
+// (nil means the interactions endpoint is not served).
+func New(cfg *config.Server, processor alertsProcessor, slackInteractions http.Handler) *Server {
+	return &Server{
+		cfg: cfg,
+
+		client:            &http.Client{Timeout: timeoutSubscribe},
+		processor:         processor,
+		slackInteractions: slackInteractions,
+	}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /healthz", s.handleHealthcheck)
+	mux.HandleFunc("POST /alertmanager", s.handleAlertmanager)
+	mux.HandleFunc("POST /sns", s.handleSns)
+	if s.slackInteractions != nil {
+		mux.Handle("POST /slack/interactions", s.slackInteractions)
+	}
+
+	return mux
+}
+
+// Run serves the requests until the context is cancelled.
+func (s *Server) Run(ctx context.Context) error {
+	l := logutils.LoggerFromContext(ctx)
+
+	srv := &http.Server{
+		Addr:              s.cfg.ListenAddress,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: timeoutRead,
+		ReadTimeout:       timeoutRead,
+		BaseContext: func(net.Listener) context.Context {
+			return logutils.ContextWithLogger(context.Background(), l)
+		},
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		l.Info("Starting the server",
+			zap.String("listen_address", s.cfg.ListenAddress),
+		)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errs <- err
+		}
+		close(errs)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	l.Info("Shutting down the server")
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutShutdown)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	// slack got its responses already, so the interactions that are still
+	// being handled must not be dropped
+	if sh, ok := s.slackInteractions.(shutdowner); ok {
+		return sh.Shutdown(ctx)
+	}
+
+	return nil
+}
+
+func (s *Server) handleHealthcheck(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleAlertmanager(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := logutils.LoggerFromContext(ctx)
+
+	if !s.isAlertmanagerAuthorized(r) {
+		l.Warn("Rejected unauthorized alertmanager webhook")
+		if s.cfg.AlertmanagerBearerToken == "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="amp-alerts-sink"`)
+		}
+		http.Error(w, ErrUnauthorized.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	if err != nil {
+		l.Error("Error reading alertmanager webhook",
+			zap.Error(err),
+		)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	message := &types.AlertmanagerWebhook{}
+	if err := json.Unmarshal(raw, message); err != nil {
+		l.Error("Error un-marshalling alertmanager webhook",
+			zap.Error(err),
+		)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// all HA replicas of alertmanager share the same receiver name, so that
+	// the alerts published by them will be deduplicated
+	source := "alertmanager/" + message.Receiver
+
+	if err := s.processor.ProcessAlertmanagerWebhook(ctx, source, message, raw); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleSns(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := logutils.LoggerFromContext(ctx)
+
+	message := &snsMessage{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize)).Decode(message); err != nil {
+		l.Error("Error un-marshalling sns notification",
+			zap.Error(err),
+		)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	l = l.With(
+		zap.String("sns_message_id", message.MessageID),
+		zap.String("sns_topic_arn", message.TopicArn),
+		zap.String("sns_message_type", message.Type),
+	)
+	ctx = logutils.ContextWithLogger(ctx, l)
+
+	// check the topic first, so that the messages of foreign topics do not
+	// even make us fetch the signing certificates
+	if !slices.Contains(s.cfg.SnsTopicArns, message.TopicArn) {
+		l.Warn("Rejected sns message from not allowed topic")
+		http.Error(w, ErrSnsTopicNotAllowed.Error(), http.StatusForbidden)
+		return
+	}
+
+	if err := s.verifySnsSignature(ctx, message); err != nil {
+		l.Warn("Rejected sns message with invalid signature",
+			zap.Error(err),
+		)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	switch message.Type {
+	case "SubscriptionConfirmation":
+		if err := s.confirmSnsSubscription(ctx, message.SubscribeURL); err != nil {
+			l.Error("Failed to confirm sns subscription",
+				zap.Error(err),
+			)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		l.Info("Confirmed sns subscription")
+
+	case "Notification":
+		if err := s.processor.ProcessSnsMessage(ctx, message.TopicArn, message.Message); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+	default:
+		l.Info("Ignored sns message")
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// isAlertmanagerAuthorized checks the credentials of alertmanager's request
+// (if the server is configured to require them).
+func (s *Server) isAlertmanagerAuthorized(r *http.Request) bool {
+	switch {
+	case s.cfg.AlertmanagerBearerToken != "":
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		return ok && secureEqual(token, s.cfg.AlertmanagerBearerToken)
+
+	case s.cfg.AlertmanagerBasicAuthUsername != "" || s.cfg.AlertmanagerBasicAuthPassword != "":
+		username, password, ok := r.BasicAuth()
+		// evaluate both, so that the timing does not tell which one is wrong
+		usernameOk := secureEqual(username, s.cfg.AlertmanagerBasicAuthUsername)
+		passwordOk := secureEqual(password, s.cfg.AlertmanagerBasicAuthPassword)
+		return ok && usernameOk && passwordOk
+
+	default:
+		return true
+	}
+}
+
+// secureEqual compares the strings in constant time.
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func (s *Server) confirmSnsSubscription(ctx context.Context, subscribeURL string) error {
+	// only ever follow the links that point to aws
+	u, err := url.Parse(subscribeURL)
+	if err != nil {
+		return fmt.Errorf("%w: %w",
+			ErrSnsInvalidSubscribeURL, err,
+		)
+	}
+	host := u.Hostname()
+	if u.Scheme != "https" || !(strings.HasSuffix(host, ".amazonaws.com") || strings.HasSuffix(host, ".amazonaws.com.cn")) {
+		return fmt.Errorf("%w: %s",
+			ErrSnsInvalidSubscribeURL, subscribeURL,
+		)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, subscribeURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s",
+			ErrSnsSubscriptionFailed, resp.Status,
+		)
+	}
+
+	return nil
+}
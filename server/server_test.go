@@ -0,0 +1,318 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flashbots/amp-alerts-sink/config"
+	"github.com/flashbots/amp-alerts-sink/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeProcessor struct {
+	sources  []string
+	messages []string
+	err      error
+}
+
+func (f *fakeProcessor) ProcessAlertmanagerWebhook(
+	_ context.Context,
+	source string,
+	message *types.AlertmanagerWebhook,
+	_ []byte,
+) error {
+	f.sources = append(f.sources, source)
+	f.messages = append(f.messages, message.Alerts[0].Labels["alertname"])
+	return f.err
+}
+
+func (f *fakeProcessor) ProcessSnsMessage(_ context.Context, topicArn, message string) error {
+	f.sources = append(f.sources, topicArn)
+	f.messages = append(f.messages, message)
+	return f.err
+}
+
+func TestServerAlertmanager(t *testing.T) {
+	p := &fakeProcessor{}
+	h := New(&config.Server{}, p, nil).Handler()
+
+	req := httptest.NewRequest(http.MethodPost, "/alertmanager", strings.NewReader(`{
+		"version": "4",
+		"receiver": "sink",
+		"status": "firing",
+		"alerts": [{"status": "firing", "labels": {"alertname": "TestAlert"}}]
+	}`))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"alertmanager/sink"}, p.sources)
+	assert.Equal(t, []string{"TestAlert"}, p.messages)
+}
+
+func TestServerAlertmanagerInvalidPayload(t *testing.T) {
+	p := &fakeProcessor{}
+	h := New(&config.Server{}, p, nil).Handler()
+
+	req := httptest.NewRequest(http.MethodPost, "/alertmanager", strings.NewReader(`{`))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, p.sources)
+}
+
+// snsSigner signs the messages the way sns does (with self-signed
+// certificate served from sns-like url).
+type snsSigner struct {
+	key     *rsa.PrivateKey
+	certPEM []byte
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+const (
+	testSigningCertURL = "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-test.pem"
+	testSubscribeURL   = "https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription&Token=token"
+	testTopicArn       = "arn:aws:sns:us-east-1:123456789012:alerts"
+	testForeignArn     = "arn:aws:sns:us-east-1:210987654321:alerts"
+)
+
+func newSnsSigner(t *testing.T) *snsSigner {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &snsSigner{
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// newSnsTestServer returns the server that accepts the messages of the test
+// topic, and that fetches signing certificate from the signer (instead of
+// from sns).  The subscriptions it confirms are recorded in confirmed.
+func newSnsTestServer(t *testing.T, p alertsProcessor) (h http.Handler, signer *snsSigner, confirmed *[]string) {
+	signer = newSnsSigner(t)
+	confirmed = &[]string{}
+
+	s := New(&config.Server{SnsTopicArns: []string{testTopicArn}}, p, nil)
+	s.client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.String() {
+		case testSigningCertURL:
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader(signer.certPEM)),
+			}, nil
+		case testSubscribeURL:
+			*confirmed = append(*confirmed, req.URL.String())
+			return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Body: http.NoBody}, nil
+		default:
+			return &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found", Body: http.NoBody}, nil
+		}
+	})}
+
+	return s.Handler(), signer, confirmed
+}
+
+// sign signs the message (with signature version 2), and returns its json.
+func (s *snsSigner) sign(t *testing.T, m *snsMessage) string {
+	m.SignatureVersion = "2"
+	if m.SigningCertURL == "" {
+		m.SigningCertURL = testSigningCertURL
+	}
+	digest := sha256.Sum256([]byte(m.stringToSign()))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Signature = base64.StdEncoding.EncodeToString(signature)
+
+	res, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(res)
+}
+
+func TestServerSnsNotification(t *testing.T) {
+	p := &fakeProcessor{}
+	h, signer, _ := newSnsTestServer(t, p)
+
+	req := httptest.NewRequest(http.MethodPost, "/sns", strings.NewReader(signer.sign(t, &snsMessage{
+		Type:      "Notification",
+		MessageID: "id",
+		TopicArn:  testTopicArn,
+		Message:   `{"alerts": []}`,
+		Timestamp: "2023-07-15T21:37:23.977Z",
+	})))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{testTopicArn}, p.sources)
+	assert.Equal(t, []string{`{"alerts": []}`}, p.messages)
+}
+
+func TestServerSnsNotificationFailure(t *testing.T) {
+	p := &fakeProcessor{err: assert.AnError}
+	h, signer, _ := newSnsTestServer(t, p)
+
+	req := httptest.NewRequest(http.MethodPost, "/sns", strings.NewReader(signer.sign(t, &snsMessage{
+		Type:     "Notification",
+		TopicArn: testTopicArn,
+		Message:  "{}",
+	})))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
+
+func TestServerSnsRejectsInvalidSignature(t *testing.T) {
+	p := &fakeProcessor{}
+	h, signer, _ := newSnsTestServer(t, p)
+
+	signed := signer.sign(t, &snsMessage{
+		Type:     "Notification",
+		TopicArn: testTopicArn,
+		Message:  `{"alerts": []}`,
+	})
+	tampered := strings.Replace(signed, `{\"alerts\": []}`, `{\"alerts\": [{}]}`, 1)
+	assert.NotEqual(t, signed, tampered)
+
+	for name, body := range map[string]string{
+		"tampered":    tampered,
+		"unsigned":    `{"Type": "Notification", "TopicArn": "` + testTopicArn + `", "Message": "{}"}`,
+		"foreignCert": signer.sign(t, &snsMessage{Type: "Notification", TopicArn: testTopicArn, SigningCertURL: "https://example.com/cert.pem"}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/sns", strings.NewReader(body))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		})
+	}
+	assert.Empty(t, p.sources)
+}
+
+func TestServerSnsSubscriptionRejectsForeignURL(t *testing.T) {
+	p := &fakeProcessor{}
+	h, signer, _ := newSnsTestServer(t, p)
+
+	req := httptest.NewRequest(http.MethodPost, "/sns", strings.NewReader(signer.sign(t, &snsMessage{
+		Type:         "SubscriptionConfirmation",
+		TopicArn:     testTopicArn,
+		SubscribeURL: "https://example.com/confirm",
+	})))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), ErrSnsInvalidSubscribeURL.Error())
+}
+
+func TestServerSnsSubscription(t *testing.T) {
+	p := &fakeProcessor{}
+	h, signer, confirmed := newSnsTestServer(t, p)
+
+	req := httptest.NewRequest(http.MethodPost, "/sns", strings.NewReader(signer.sign(t, &snsMessage{
+		Type:         "SubscriptionConfirmation",
+		TopicArn:     testTopicArn,
+		SubscribeURL: testSubscribeURL,
+	})))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{testSubscribeURL}, *confirmed)
+}
+
+func TestServerSnsRejectsForeignTopic(t *testing.T) {
+	p := &fakeProcessor{}
+	h, signer, confirmed := newSnsTestServer(t, p)
+
+	for name, message := range map[string]*snsMessage{
+		"subscription": {Type: "SubscriptionConfirmation", TopicArn: testForeignArn, SubscribeURL: testSubscribeURL},
+		"notification": {Type: "Notification", TopicArn: testForeignArn, Message: `{"alerts": []}`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/sns", strings.NewReader(signer.sign(t, message)))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+			assert.Contains(t, rec.Body.String(), ErrSnsTopicNotAllowed.Error())
+		})
+	}
+	assert.Empty(t, *confirmed)
+	assert.Empty(t, p.sources)
+}
+
+func TestServerAlertmanagerAuth(t *testing.T) {
+	body := `{"receiver": "sink", "alerts": [{"status": "firing", "labels": {"alertname": "TestAlert"}}]}`
+
+	for _, tc := range []struct {
+		name     string
+		cfg      *config.Server
+		setAuth  func(r *http.Request)
+		expected int
+	}{
+		{"bearer ok", &config.Server{AlertmanagerBearerToken: "theToken"},
+			func(r *http.Request) { r.Header.Set("Authorization", "Bearer theToken") }, http.StatusOK},
+		{"bearer wrong", &config.Server{AlertmanagerBearerToken: "theToken"},
+			func(r *http.Request) { r.Header.Set("Authorization", "Bearer other") }, http.StatusUnauthorized},
+		{"bearer missing", &config.Server{AlertmanagerBearerToken: "theToken"},
+			func(r *http.Request) {}, http.StatusUnauthorized},
+		{"basic ok", &config.Server{AlertmanagerBasicAuthUsername: "user", AlertmanagerBasicAuthPassword: "pass"},
+			func(r *http.Request) { r.SetBasicAuth("user", "pass") }, http.StatusOK},
+		{"basic wrong", &config.Server{AlertmanagerBasicAuthUsername: "user", AlertmanagerBasicAuthPassword: "pass"},
+			func(r *http.Request) { r.SetBasicAuth("user", "other") }, http.StatusUnauthorized},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &fakeProcessor{}
+			h := New(tc.cfg, p, nil).Handler()
+
+			req := httptest.NewRequest(http.MethodPost, "/alertmanager", strings.NewReader(body))
+			tc.setAuth(req)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expected, rec.Code)
+			if tc.expected != http.StatusOK {
+				assert.Empty(t, p.sources)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	_ "crypto/sha1" // for sns signature version 1
+	_ "crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxSnsSigningCertSize = 64 << 10
+)
+
+var (
+	ErrSnsInvalidSignature      = errors.New("invalid sns message signature")
+	ErrSnsInvalidSigningCertURL = errors.New("invalid sns signing certificate url")
+)
+
+// snsSigningCertHost matches the hosts that sns signing certificates are
+// served from (e.g. sns.us-east-1.amazonaws.com)
+var snsSigningCertHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// verifySnsSignature checks that the message was signed by SNS (see
+// https://docs.aws.amazon.com/sns/latest/dg/sns-verify-signature-of-message.html)
+func (s *Server) verifySnsSignature(ctx context.Context, message *snsMessage) error {
+	var hash crypto.Hash
+	switch message.SignatureVersion {
+	case "1":
+		hash = crypto.SHA1
+	case "2":
+		hash = crypto.SHA256
+	default:
+		return fmt.Errorf("%w: unsupported signature version '%s'",
+			ErrSnsInvalidSignature, message.SignatureVersion,
+		)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(message.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %w",
+			ErrSnsInvalidSignature, err,
+		)
+	}
+
+	cert, err := s.snsSigningCert(ctx, message.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("%w: unexpected type of signing certificate key",
+			ErrSnsInvalidSignature,
+		)
+	}
+
+	h := hash.New()
+	h.Write([]byte(message.stringToSign()))
+	if err := rsa.VerifyPKCS1v15(publicKey, hash, h.Sum(nil), signature); err != nil {
+		return fmt.Errorf("%w: %w",
+			ErrSnsInvalidSignature, err,
+		)
+	}
+
+	return nil
+}
+
+// snsSigningCert fetches the certificate that sns messages are signed with
+// (and caches it, as the same one is used for all messages of the region).
+func (s *Server) snsSigningCert(ctx context.Context, certURL string) (*x509.Certificate, error) {
+	if cert, ok := s.snsCerts.Load(certURL); ok {
+		return cert.(*x509.Certificate), nil
+	}
+
+	// only ever fetch the certificates from sns
+	u, err := url.Parse(certURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w",
+			ErrSnsInvalidSigningCertURL, err,
+		)
+	}
+	if u.Scheme != "https" || !snsSigningCertHost.MatchString(u.Hostname()) || !strings.HasSuffix(u.Path, ".pem") {
+		return nil, fmt.Errorf("%w: %s",
+			ErrSnsInvalidSigningCertURL, certURL,
+		)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, certURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sns signing certificate: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch sns signing certificate: %s", resp.Status)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxSnsSigningCertSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sns signing certificate: %w", err)
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no pem data in signing certificate",
+			ErrSnsInvalidSignature,
+		)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w",
+			ErrSnsInvalidSignature, err,
+		)
+	}
+
+	s.snsCerts.Store(certURL, cert)
+	return cert, nil
+}
+
+// stringToSign returns the string that sns signs (which depends on the type
+// of the message).
+func (m *snsMessage) stringToSign() string {
+	var fields [][2]string
+	switch m.Type {
+	case "Notification":
+		fields = [][2]string{
+			{"Message", m.Message},
+			{"MessageId", m.MessageID},
+			{"Subject", m.Subject},
+			{"Timestamp", m.Timestamp},
+			{"TopicArn", m.TopicArn},
+			{"Type", m.Type},
+		}
+	default:
+		fields = [][2]string{
+			{"Message", m.Message},
+			{"MessageId", m.MessageID},
+			{"SubscribeURL", m.SubscribeURL},
+			{"Timestamp", m.Timestamp},
+			{"Token", m.Token},
+			{"TopicArn", m.TopicArn},
+			{"Type", m.Type},
+		}
+	}
+
+	res := &strings.Builder{}
+	for _, f := range fields {
+		if f[0] == "Subject" && f[1] == "" {
+			// subject is only signed when present
+			continue
+		}
+		res.WriteString(f[0] + "\n" + f[1] + "\n")
+	}
+	return res.String()
+}
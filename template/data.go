@@ -0,0 +1,39 @@
+package template
+
+import (
+	"github.com/flashbots/amp-alerts-sink/types"
+)
+
+// Data is what the templates are executed against.  It exposes all fields of
+// the alert (e.g. `.Status`, `.Labels.alertname`, `.Annotations.summary`).
+type Data struct {
+	*types.AlertmanagerAlert
+
+	Source string
+	Links  []Link
+}
+
+type Link struct {
+	Href string
+	Text string
+}
+
+func NewData(source string, alert *types.AlertmanagerAlert) *Data {
+	links := make([]Link, 0, 3)
+	addLink := func(href, text string) {
+		if href == "" {
+			return
+		}
+		links = append(links, Link{Href: href, Text: text})
+	}
+	addLink(alert.Annotations["runbook_url"], "📕 Runbook")
+	addLink(alert.GeneratorURL, "📈 Expr")
+	addLink(alert.SilenceURL, "🔕 Silence")
+
+	return &Data{
+		AlertmanagerAlert: alert,
+
+		Source: source,
+		Links:  links,
+	}
+}
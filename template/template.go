@@ -0,0 +1,88 @@
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// Template is go text/template with alertmanager-compatible helper functions.
+type Template struct {
+	tmpl *template.Template
+}
+
+func New(name, text string) (*Template, error) {
+	tmpl, err := template.New(name).
+		Option("missingkey=zero").
+		Funcs(FuncMap()).
+		Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+	return &Template{tmpl: tmpl}, nil
+}
+
+// Must is a helper that panics if the template could not be parsed.  It is
+// meant for the built-in (default) templates.
+func Must(t *Template, err error) *Template {
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func (t *Template) Execute(data any) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := t.tmpl.Execute(buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute template %s: %w", t.tmpl.Name(), err)
+	}
+	return buf.String(), nil
+}
+
+// FuncMap returns the same helper functions that alertmanager templates have
+// (plus toJson, for the templates of json payloads).
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"toUpper":   strings.ToUpper,
+		"toLower":   strings.ToLower,
+		"title":     title,
+		"trimSpace": strings.TrimSpace,
+		"join": func(sep string, s []string) string {
+			return strings.Join(s, sep)
+		},
+		"match": regexp.MatchString,
+		"safeHtml": func(text string) string {
+			return text
+		},
+		"reReplaceAll": func(pattern, repl, text string) (string, error) {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return "", err
+			}
+			return re.ReplaceAllString(text, repl), nil
+		},
+		"stringSlice": func(s ...string) []string {
+			return s
+		},
+		"toJson": func(v any) (string, error) {
+			res, err := json.Marshal(v)
+			return string(res), err
+		},
+	}
+}
+
+// title upper-cases the first letter of each word.
+func title(s string) string {
+	prev := ' '
+	return strings.Map(func(r rune) rune {
+		defer func() { prev = r }()
+		if unicode.IsSpace(prev) || unicode.IsPunct(prev) {
+			return unicode.ToTitle(r)
+		}
+		return r
+	}, s)
+}
@@ -31,9 +31,14 @@ type AlertmanagerWebhook struct {
 type AlertmanagerAlert struct {
 	Status   string `json:"status"`
 	StartsAt string `json:"startsAt"`
+	EndsAt   string `json:"endsAt,omitempty"`
 
 	GeneratorURL string `json:"generatorURL"`
 
+	// ExternalURL is the URL of alertmanager that sent the alert (it's copied
+	// over from the message, unless already set).
+	ExternalURL string `json:"externalURL,omitempty"`
+
 	// SilenceURL is a field included by default in Grafana Alertmanager alerts.
 	// However, it can be included in any Alertmanager notification template,
 	// and will get added to rendered alerts from amp-alerts-sink.
@@ -42,6 +47,10 @@ type AlertmanagerAlert struct {
 
 	Labels      map[string]string `json:"labels"`
 	Annotations map[string]string `json:"annotations"`
+
+	// GroupLabels are the labels by which alertmanager grouped the alert (they
+	// are copied over from the message).
+	GroupLabels map[string]string `json:"-"`
 }
 
 // IncidentDedupKey computes the hash of alert's labels only.